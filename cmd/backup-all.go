@@ -6,15 +6,13 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/tiyfiy/BackItUp/internal/config"
-	"github.com/tiyfiy/BackItUp/internal/mongodb"
-	"github.com/tiyfiy/BackItUp/internal/mysql"
-	"github.com/tiyfiy/BackItUp/internal/postgresql"
+	"github.com/tiyfiy/BackItUp/internal/sqlite"
 )
 
 var backupAllCmd = &cobra.Command{
 	Use:   "backup-all",
 	Short: "Backup all configured databases at once",
-	Long: `Run backups for all configured databases (MongoDB, MySQL, PostgreSQL) in sequence.
+	Long: `Run backups for all configured databases (MongoDB, MySQL, PostgreSQL, SQLite, Redis) in sequence.
 
 This command will:
 - Check which databases are configured
@@ -50,12 +48,13 @@ func runBackupAll() {
 	// Backup MongoDB
 	if cfg.MongoDB.URI != "" && cfg.MongoDB.URI != "mongodb://localhost:27017" {
 		fmt.Println("📦 Backing up MongoDB...")
-		client, err := mongodb.Connection(cfg.MongoDB.URI)
+		err := runBackup("mongodb", "", cfg.MongoDB.Hooks, func() (string, error) {
+			return backupMongoDBInstance(cfg.MongoDB)
+		})
 		if err != nil {
 			fmt.Printf("   ❌ Failed: %v\n\n", err)
 			failCount++
 		} else {
-			mongodb.Backup(client, cfg.MongoDB.URI)
 			successCount++
 			fmt.Println()
 		}
@@ -67,13 +66,13 @@ func runBackupAll() {
 	// Backup MySQL
 	if cfg.MySQL.Database != "" {
 		fmt.Println("📦 Backing up MySQL...")
-		db, err := mysql.Connection(cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.User, cfg.MySQL.Password, cfg.MySQL.Database)
+		err := runBackup("mysql", cfg.MySQL.Database, cfg.MySQL.Hooks, func() (string, error) {
+			return backupMySQLDatabase(cfg.MySQL)
+		})
 		if err != nil {
 			fmt.Printf("   ❌ Failed: %v\n\n", err)
 			failCount++
 		} else {
-			mysql.Backup(db, cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.User, cfg.MySQL.Password, cfg.MySQL.Database)
-			db.Close()
 			successCount++
 			fmt.Println()
 		}
@@ -83,15 +82,27 @@ func runBackupAll() {
 	}
 
 	// Backup PostgreSQL
-	if cfg.PostgreSQL.Database != "" {
+	if cfg.PostgreSQL.Physical {
+		fmt.Println("📦 Backing up PostgreSQL (physical)...")
+		err := runBackup("postgresql", "", cfg.PostgreSQL.Hooks, func() (string, error) {
+			return backupPostgreSQLPhysical(cfg.PostgreSQL)
+		})
+		if err != nil {
+			fmt.Printf("   ❌ Failed: %v\n\n", err)
+			failCount++
+		} else {
+			successCount++
+			fmt.Println()
+		}
+	} else if cfg.PostgreSQL.Database != "" || cfg.PostgreSQL.Cluster {
 		fmt.Println("📦 Backing up PostgreSQL...")
-		db, err := postgresql.Connection(cfg.PostgreSQL.Host, cfg.PostgreSQL.Port, cfg.PostgreSQL.User, cfg.PostgreSQL.Password, cfg.PostgreSQL.Database)
+		err := runBackup("postgresql", cfg.PostgreSQL.Database, cfg.PostgreSQL.Hooks, func() (string, error) {
+			return backupPostgreSQLDatabase(cfg.PostgreSQL)
+		})
 		if err != nil {
 			fmt.Printf("   ❌ Failed: %v\n\n", err)
 			failCount++
 		} else {
-			postgresql.Backup(db, cfg.PostgreSQL.Host, cfg.PostgreSQL.Port, cfg.PostgreSQL.User, cfg.PostgreSQL.Password, cfg.PostgreSQL.Database)
-			db.Close()
 			successCount++
 			fmt.Println()
 		}
@@ -100,6 +111,44 @@ func runBackupAll() {
 		skippedCount++
 	}
 
+	// Backup SQLite
+	if len(cfg.SQLite.Paths) > 0 {
+		for _, path := range cfg.SQLite.Paths {
+			fmt.Printf("📦 Backing up SQLite (%s)...\n", path)
+			err := runBackup("sqlite", sqlite.Name(path), cfg.SQLite.Hooks, func() (string, error) {
+				return backupSQLiteDatabase(cfg.SQLite, path)
+			})
+			if err != nil {
+				fmt.Printf("   ❌ Failed: %v\n\n", err)
+				failCount++
+			} else {
+				successCount++
+				fmt.Println()
+			}
+		}
+	} else {
+		fmt.Println("⏭️  Skipping SQLite (not configured)")
+		skippedCount++
+	}
+
+	// Backup Redis
+	if cfg.Redis.Addr != "localhost:6379" {
+		fmt.Println("📦 Backing up Redis...")
+		err := runBackup("redis", "", cfg.Redis.Hooks, func() (string, error) {
+			return backupRedisInstance(cfg.Redis)
+		})
+		if err != nil {
+			fmt.Printf("   ❌ Failed: %v\n\n", err)
+			failCount++
+		} else {
+			successCount++
+			fmt.Println()
+		}
+	} else {
+		fmt.Println("⏭️  Skipping Redis (not configured)")
+		skippedCount++
+	}
+
 	// Summary
 	duration := time.Since(startTime)
 	fmt.Println("═══════════════════════════════════════════════════════════════")
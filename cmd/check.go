@@ -0,0 +1,502 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/config"
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+	"github.com/tiyfiy/BackItUp/internal/mongodb"
+	"github.com/tiyfiy/BackItUp/internal/mysql"
+	"github.com/tiyfiy/BackItUp/internal/postgresql"
+	"github.com/tiyfiy/BackItUp/internal/preflight"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+	"github.com/tiyfiy/BackItUp/internal/redis"
+	"github.com/tiyfiy/BackItUp/internal/sqlite"
+	"github.com/tiyfiy/BackItUp/internal/tool"
+)
+
+// Operations preflight checks can be run for.
+const (
+	opBackup  = "backup"
+	opRestore = "restore"
+	// opSnapshot is the backup taken before a restore. Unlike a regular
+	// backup, the database doesn't have to exist yet.
+	opSnapshot = "snapshot"
+)
+
+// skipPreflight turns off the checks before backups and restores.
+var skipPreflight bool
+
+var checkCmd = &cobra.Command{
+	Use:   "check [mongodb|mysql|postgresql|sqlite|redis]",
+	Short: "Check tools, server versions and disk space",
+	Long: `Run the preflight checks that precede every backup and restore:
+
+  - the dump and restore tools are installed, and their versions
+  - the server is reachable, and its version
+  - the tools are new enough for the server (e.g. pg_dump can't dump a
+    newer PostgreSQL server)
+  - the backup directory has room for a backup the size of the previous one
+
+Without an argument, every configured database is checked. Exits non-zero
+if a check fails.
+
+Examples:
+  ./BackItUp check
+  ./BackItUp check postgresql`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"mongodb", "mysql", "postgresql", "sqlite", "redis"},
+	Run: func(cmd *cobra.Command, args []string) {
+		runCheck(args)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+func runCheck(args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Error loading configuration:", err)
+	}
+
+	engine := ""
+	if len(args) > 0 {
+		engine = args[0]
+	}
+
+	fmt.Println("🔍 BackItUp Preflight Check")
+	fmt.Println("═══════════════════════════════════════════════════════════════")
+
+	failed := false
+	checked := false
+
+	if engine == "mongodb" || (engine == "" && cfg.MongoDB.URI != "" && cfg.MongoDB.URI != "mongodb://localhost:27017") {
+		checked = true
+		fmt.Println("\n🍃 MongoDB")
+		mongoCfg := cfg.MongoDB
+		if closeTunnel, err := tunnelMongoDB(&mongoCfg); err != nil {
+			failed = printReport(tunnelFailed(err)) || failed
+		} else {
+			failed = printReport(preflightMongoDB(mongoCfg, opBackup, opRestore)) || failed
+			closeTunnel()
+		}
+	}
+
+	if engine == "mysql" || (engine == "" && cfg.MySQL.Database != "") {
+		checked = true
+		fmt.Println("\n🐬 MySQL")
+		mysqlCfg := cfg.MySQL
+		if closeTunnel, err := tunnelMySQL(&mysqlCfg); err != nil {
+			failed = printReport(tunnelFailed(err)) || failed
+		} else {
+			failed = printReport(preflightMySQL(mysqlCfg, opBackup, opRestore)) || failed
+			closeTunnel()
+		}
+	}
+
+	if engine == "postgresql" || (engine == "" && (cfg.PostgreSQL.Database != "" || cfg.PostgreSQL.Cluster || cfg.PostgreSQL.Physical)) {
+		checked = true
+		fmt.Println("\n🐘 PostgreSQL")
+		pgCfg := cfg.PostgreSQL
+		if closeTunnel, err := tunnelPostgreSQL(&pgCfg); err != nil {
+			failed = printReport(tunnelFailed(err)) || failed
+		} else {
+			failed = printReport(preflightPostgreSQL(pgCfg, opBackup, opRestore)) || failed
+			closeTunnel()
+		}
+	}
+
+	if engine == "sqlite" || (engine == "" && len(cfg.SQLite.Paths) > 0) {
+		checked = true
+		fmt.Println("\n🪶 SQLite")
+		failed = printReport(preflightSQLite(cfg.SQLite, opBackup, opRestore)) || failed
+	}
+
+	if engine == "redis" || (engine == "" && cfg.Redis.Addr != "localhost:6379") {
+		checked = true
+		fmt.Println("\n🟥 Redis")
+		redisCfg := cfg.Redis
+		if closeTunnel, err := tunnelRedis(&redisCfg); err != nil {
+			failed = printReport(tunnelFailed(err)) || failed
+		} else {
+			failed = printReport(preflightRedis(redisCfg, opBackup, opRestore)) || failed
+			closeTunnel()
+		}
+	}
+
+	if !checked {
+		if engine != "" {
+			fmt.Printf("\nUnknown database type: %s\n", engine)
+			fmt.Println("Supported types: mongodb, mysql, postgresql, sqlite, redis")
+		} else {
+			fmt.Println("\nNo databases configured.")
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	if failed {
+		fmt.Println("❌ Some checks failed.")
+		os.Exit(1)
+	}
+	fmt.Println("✅ Ready to back up.")
+}
+
+// printReport prints every result and reports whether any check failed.
+func printReport(report *preflight.Report) bool {
+	for _, result := range report.Results {
+		fmt.Printf("  %s %-14s %s\n", statusIcon(result.Status), result.Check, result.Detail)
+	}
+	return report.Err() != nil
+}
+
+func statusIcon(status preflight.Status) string {
+	switch status {
+	case preflight.Warn:
+		return "⚠️ "
+	case preflight.Fail:
+		return "❌"
+	default:
+		return "✅"
+	}
+}
+
+// runPreflight runs the checks before a backup or restore, unless
+// --skip-preflight was given. Only problems are printed; a failed check
+// stops the operation.
+func runPreflight(checks func() *preflight.Report) error {
+	if skipPreflight {
+		return nil
+	}
+
+	report := checks()
+	for _, result := range report.Results {
+		if result.Status != preflight.Pass {
+			fmt.Printf("   %s %s: %s\n", statusIcon(result.Status), result.Check, result.Detail)
+		}
+	}
+	return report.Err()
+}
+
+// hasOperation reports whether operation is among operations. A snapshot
+// counts as a backup.
+func hasOperation(operations []string, operation string) bool {
+	for _, op := range operations {
+		if op == operation || (op == opSnapshot && operation == opBackup) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkServer records whether the server could be reached and its version.
+func checkServer(report *preflight.Report, version string, err error) (preflight.Version, bool) {
+	if err != nil {
+		if hint := tool.Classify(err.Error()); hint != "" {
+			report.Fail("server", "%v (%s)", err, hint)
+		} else {
+			report.Fail("server", "%v", err)
+		}
+		return preflight.Version{}, false
+	}
+
+	parsed, ok := preflight.ParseVersion(version)
+	if !ok {
+		report.Warn("server", "connected, but the version %q couldn't be parsed", version)
+		return preflight.Version{}, false
+	}
+	report.Pass("server", "%s", version)
+	return parsed, true
+}
+
+// previousBackupSize returns the size of the newest backup like the one
+// about to be taken, falling back to the newest backup in the directory.
+func previousBackupSize(dbDir, engine, database, kind string) int64 {
+	dir := filepath.Join("BACKUP", dbDir)
+	if latest := manifest.Latest(dir, engine, database, kind); latest != "" {
+		return progress.SizeOf(latest)
+	}
+	if backups := engineBackups(dbDir); len(backups) > 0 {
+		return backups[0].Size
+	}
+	return 0
+}
+
+// tunnelFailed reports an SSH tunnel that couldn't be opened, which keeps
+// every other check from running.
+func tunnelFailed(err error) *preflight.Report {
+	report := &preflight.Report{}
+	report.Fail("ssh", "%v", err)
+	return report
+}
+
+func preflightMySQL(mysqlCfg config.MySQLConfig, operations ...string) *preflight.Report {
+	report := &preflight.Report{}
+
+	// A restore may create the database, so only a backup needs it to exist
+	database := mysqlCfg.Database
+	if !hasOperation(operations, opBackup) || hasOperation(operations, opSnapshot) {
+		database = ""
+	}
+
+	// The server is asked first, as MariaDB servers get the mariadb tools
+	var serverVersion string
+	db, err := mysql.Connection(mysqlCfg.Host, mysqlCfg.Port, mysqlCfg.User, mysqlCfg.Password, database, mysqlConn(mysqlCfg))
+	if err == nil {
+		serverVersion, err = mysql.ServerVersion(db)
+		db.Close()
+	}
+	runner := mysqlConn(mysqlCfg).Backend()
+	clientTools := mysql.ToolsFor(mysql.FlavorOf(serverVersion), runner)
+
+	// The native dumper needs no client tools
+	var tools []string
+	if hasOperation(operations, opBackup) && mysqlCfg.Dumper != mysql.DumperNative {
+		tools = append(tools, clientTools.Dump)
+	}
+	if hasOperation(operations, opRestore) {
+		tools = append(tools, clientTools.Client)
+	}
+
+	versions := map[string]preflight.Version{}
+	for _, name := range tools {
+		if version, ok := report.ToolIn(runner, name); ok && version != (preflight.Version{}) {
+			versions[name] = version
+		}
+	}
+
+	server, ok := checkServer(report, serverVersion, err)
+
+	// MariaDB numbers its versions differently, so they can't be compared
+	// with the MySQL client's
+	if ok && mysql.FlavorOf(serverVersion) == mysql.FlavorMySQL {
+		for _, name := range tools {
+			version, found := versions[name]
+			if !found {
+				continue
+			}
+			switch {
+			case version.Major < server.Major:
+				report.Warn("compatibility", "%s %s is older than the server (%s): install MySQL %d client tools", name, version, server, server.Major)
+			case name == "mysqldump" && version.Major >= 8 && server.Major < 8:
+				report.Warn("compatibility", "mysqldump %s against a MySQL %d server can fail on COLUMN_STATISTICS", version, server.Major)
+			}
+		}
+	}
+
+	if hasOperation(operations, opBackup) {
+		kind := backupKindOf(mysqlCfg.SchemaOnly, mysqlCfg.DataOnly)
+		report.Disk("BACKUP/mysql", previousBackupSize("mysql", "mysql", mysqlCfg.Database, kind))
+	}
+	return report
+}
+
+func preflightPostgreSQL(pgCfg config.PostgreSQLConfig, operations ...string) *preflight.Report {
+	report := &preflight.Report{}
+
+	// Tools older than the server can't read its catalogs
+	var required, recommended []string
+	if hasOperation(operations, opBackup) {
+		switch {
+		case pgCfg.Physical:
+			required = append(required, "pg_basebackup")
+		case pgCfg.Cluster:
+			required = append(required, "pg_dumpall", "pg_dump")
+		default:
+			required = append(required, "pg_dump")
+		}
+	}
+	if hasOperation(operations, opRestore) {
+		recommended = append(recommended, "psql", "pg_restore")
+	}
+
+	// Physical and directory backups are written by the tools themselves
+	runner := postgresqlConn(pgCfg).Backend()
+	if hasOperation(operations, opBackup) && !runner.Local() {
+		switch {
+		case pgCfg.Physical:
+			report.Fail("exec", "pg_basebackup can't run in %s, physical backups need local tools", runner)
+		case pgCfg.Format == postgresql.FormatDirectory:
+			report.Fail("exec", "pg_dump in %s can't write a directory dump here, use the custom format", runner)
+		}
+	}
+
+	versions := map[string]preflight.Version{}
+	for _, name := range append(required, recommended...) {
+		if version, ok := report.ToolIn(runner, name); ok && version != (preflight.Version{}) {
+			versions[name] = version
+		}
+	}
+
+	database := pgCfg.Database
+	if database == "" || !hasOperation(operations, opBackup) || hasOperation(operations, opSnapshot) {
+		database = "postgres"
+	}
+
+	var serverVersion string
+	db, err := postgresql.Connection(pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, database, postgresqlConn(pgCfg))
+	if err == nil {
+		serverVersion, err = postgresql.ServerVersion(db)
+		db.Close()
+	}
+	server, ok := checkServer(report, serverVersion, err)
+
+	if ok {
+		for _, name := range required {
+			if version, found := versions[name]; found && version.Major < server.Major {
+				report.Fail("compatibility", "%s %s is older than the server (%s): install PostgreSQL %d client tools", name, version, server, server.Major)
+			}
+		}
+		for _, name := range recommended {
+			if version, found := versions[name]; found && version.Major < server.Major {
+				report.Warn("compatibility", "%s %s is older than the server (%s) and may not read newer dumps", name, version, server)
+			}
+		}
+	}
+
+	if hasOperation(operations, opBackup) {
+		database, kind := pgCfg.Database, backupKindOf(pgCfg.SchemaOnly, pgCfg.DataOnly)
+		if pgCfg.Physical || pgCfg.Cluster {
+			database, kind = "", manifest.KindFull
+		}
+		report.Disk("BACKUP/postgresql", previousBackupSize("postgresql", "postgresql", database, kind))
+	}
+	return report
+}
+
+func preflightMongoDB(mongoCfg config.MongoDBConfig, operations ...string) *preflight.Report {
+	report := &preflight.Report{}
+
+	// The native dumper and restore need no database tools
+	var tools []string
+	if mongoCfg.Dumper != mongodb.DumperNative {
+		if hasOperation(operations, opBackup) {
+			tools = append(tools, "mongodump")
+		}
+		if hasOperation(operations, opRestore) {
+			tools = append(tools, "mongorestore")
+		}
+	}
+
+	runner := execBackend(mongoCfg.Exec)
+	versions := map[string]preflight.Version{}
+	for _, name := range tools {
+		if version, ok := report.ToolIn(runner, name); ok && version != (preflight.Version{}) {
+			versions[name] = version
+		}
+	}
+	if hasOperation(operations, opBackup) && mongoCfg.Dumper != mongodb.DumperNative && !runner.Local() && !mongoCfg.Archive {
+		report.Fail("exec", "mongodump in %s can't write a directory dump here, use --archive", runner)
+	}
+
+	var serverVersion string
+	client, err := mongodb.Connection(mongoCfg.URI)
+	if err == nil {
+		serverVersion, err = mongodb.ServerVersion(client)
+		client.Disconnect(context.Background())
+	}
+	server, ok := checkServer(report, serverVersion, err)
+
+	// The Database Tools are versioned separately from the server since 100.0
+	// and support MongoDB 4.2 and later
+	if ok && server.Less(preflight.Version{Major: 4, Minor: 2}) {
+		for _, name := range tools {
+			if version, found := versions[name]; found && version.Major >= 100 {
+				report.Warn("compatibility", "%s %s doesn't support MongoDB %s: use the tools shipped with the server", name, version, server)
+			}
+		}
+	}
+
+	if hasOperation(operations, opBackup) {
+		report.Disk("BACKUP/mongo", previousBackupSize("mongo", "mongodb", "", manifest.KindFull))
+	}
+	return report
+}
+
+// preflightPostgreSQLPhysical checks that a physical backup can be laid
+// out in dataDir and that walDir has WAL to recover with.
+func preflightPostgreSQLPhysical(backupPath, dataDir, walDir string) *preflight.Report {
+	report := &preflight.Report{}
+
+	if entries, err := os.ReadDir(dataDir); err == nil && len(entries) > 0 {
+		report.Fail("data directory", "%s is not empty", dataDir)
+	} else {
+		report.Pass("data directory", "%s", dataDir)
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(walDir, "[0-9A-F]*"))
+	switch {
+	case len(segments) > 0:
+		report.Pass("wal", "%d archived WAL files in %s", len(segments), walDir)
+	case restorePITR != "":
+		report.Fail("wal", "no archived WAL in %s to recover to %s, pass wal-archive's --dir as --wal-dir", walDir, restorePITR)
+	default:
+		report.Warn("wal", "no archived WAL in %s, recovery stops at the end of the backup", walDir)
+	}
+
+	report.Disk(dataDir, progress.SizeOf(backupPath))
+	return report
+}
+
+func preflightSQLite(sqliteCfg config.SQLiteConfig, operations ...string) *preflight.Report {
+	report := &preflight.Report{}
+
+	// VACUUM INTO needs 3.27; older shells fall back to .backup
+	if version, ok := report.Tool("sqlite3"); ok && version != (preflight.Version{}) &&
+		hasOperation(operations, opBackup) && version.Less(preflight.Version{Major: 3, Minor: 27}) {
+		report.Warn("compatibility", "sqlite3 %s has no VACUUM INTO, copies are taken with .backup and aren't compacted", version)
+	}
+
+	if !hasOperation(operations, opBackup) {
+		return report
+	}
+
+	// A restore may create the database, so only a backup needs it to exist
+	var previous int64
+	for _, path := range sqliteCfg.Paths {
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && info.IsDir():
+			report.Fail("database", "%s is a directory", path)
+		case err == nil:
+			report.Pass("database", "%s (%s)", path, progress.FormatBytes(info.Size()))
+		case !hasOperation(operations, opSnapshot):
+			report.Fail("database", "%v", err)
+		}
+
+		size := previousBackupSize("sqlite", "sqlite", sqlite.Name(path), manifest.KindFull)
+		if size == 0 && err == nil {
+			size = info.Size()
+		}
+		previous += size
+	}
+	report.Disk(sqlite.Dir, previous)
+	return report
+}
+
+func preflightRedis(redisCfg config.RedisConfig, operations ...string) *preflight.Report {
+	report := &preflight.Report{}
+
+	// Restores only stage a file, usually while the server is stopped
+	if !hasOperation(operations, opBackup) {
+		return report
+	}
+
+	var serverVersion string
+	conn, err := redis.Connection(redisCfg.Addr, redisCfg.User, redisCfg.Password)
+	if err == nil {
+		serverVersion, err = redis.ServerVersion(conn)
+		conn.Close()
+	}
+	checkServer(report, serverVersion, err)
+
+	report.Disk(redis.Dir, previousBackupSize("redis", "redis", "", manifest.KindFull))
+	return report
+}
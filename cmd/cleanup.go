@@ -4,20 +4,26 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/history"
+	"github.com/tiyfiy/BackItUp/internal/manifest"
 )
 
 var (
-	keepDays  int
-	keepCount int
-	dryRun    bool
+	keepDays     int
+	keepCount    int
+	dryRun       bool
+	minKeep      int
+	forceCleanup bool
 )
 
 var cleanupCmd = &cobra.Command{
-	Use:   "cleanup [mongodb|mysql|postgresql|all]",
+	Use:   "cleanup [mongodb|mysql|postgresql|sqlite|redis|all]",
 	Short: "Clean up old backups based on retention policy",
 	Long: `Remove old backups to save disk space.
 
@@ -26,6 +32,17 @@ You can specify retention by:
   --keep N    Keep the N most recent backups
   --dry-run   Show what would be deleted without actually deleting
 
+Retention and safety guards apply to each database's backups separately,
+and to full, schema-only and data-only backups separately. PostgreSQL
+cluster sets and base backups count as their own targets.
+
+Safety guards:
+  - The newest successful backup is never deleted
+  - At least --min-keep successful backups are always kept (default 1)
+  - With --days, nothing is deleted unless a backup newer than the cutoff
+    exists; use --force to override
+  - Pinned backups (see 'pin') are never deleted
+
 Examples:
   ./BackItUp cleanup mysql --days 30          # Keep last 30 days
   ./BackItUp cleanup postgresql --keep 5      # Keep 5 most recent
@@ -49,6 +66,8 @@ func init() {
 	cleanupCmd.Flags().IntVarP(&keepDays, "days", "d", 0, "Keep backups from last N days")
 	cleanupCmd.Flags().IntVarP(&keepCount, "keep", "k", 0, "Keep N most recent backups")
 	cleanupCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be deleted without deleting")
+	cleanupCmd.Flags().IntVar(&minKeep, "min-keep", 1, "Always keep at least N successful backups")
+	cleanupCmd.Flags().BoolVar(&forceCleanup, "force", false, "Delete even if no backup newer than the --days cutoff exists")
 }
 
 func cleanupBackups(target string) {
@@ -62,17 +81,27 @@ func cleanupBackups(target string) {
 
 	switch target {
 	case "mongodb":
-		deleted, size := cleanupDatabaseBackups("mongo", "MongoDB", true)
+		deleted, size := cleanupDatabaseBackups("mongo", "MongoDB")
 		totalDeleted += deleted
 		totalSize += size
 
 	case "mysql":
-		deleted, size := cleanupDatabaseBackups("mysql", "MySQL", false)
+		deleted, size := cleanupDatabaseBackups("mysql", "MySQL")
 		totalDeleted += deleted
 		totalSize += size
 
 	case "postgresql":
-		deleted, size := cleanupDatabaseBackups("postgresql", "PostgreSQL", false)
+		deleted, size := cleanupDatabaseBackups("postgresql", "PostgreSQL")
+		totalDeleted += deleted
+		totalSize += size
+
+	case "sqlite":
+		deleted, size := cleanupDatabaseBackups("sqlite", "SQLite")
+		totalDeleted += deleted
+		totalSize += size
+
+	case "redis":
+		deleted, size := cleanupDatabaseBackups("redis", "Redis")
 		totalDeleted += deleted
 		totalSize += size
 
@@ -80,21 +109,29 @@ func cleanupBackups(target string) {
 		fmt.Println("🧹 Cleaning up backups for all databases...")
 		fmt.Println()
 
-		deleted, size := cleanupDatabaseBackups("mongo", "MongoDB", true)
+		deleted, size := cleanupDatabaseBackups("mongo", "MongoDB")
+		totalDeleted += deleted
+		totalSize += size
+
+		deleted, size = cleanupDatabaseBackups("mysql", "MySQL")
 		totalDeleted += deleted
 		totalSize += size
 
-		deleted, size = cleanupDatabaseBackups("mysql", "MySQL", false)
+		deleted, size = cleanupDatabaseBackups("postgresql", "PostgreSQL")
 		totalDeleted += deleted
 		totalSize += size
 
-		deleted, size = cleanupDatabaseBackups("postgresql", "PostgreSQL", false)
+		deleted, size = cleanupDatabaseBackups("sqlite", "SQLite")
+		totalDeleted += deleted
+		totalSize += size
+
+		deleted, size = cleanupDatabaseBackups("redis", "Redis")
 		totalDeleted += deleted
 		totalSize += size
 
 	default:
 		fmt.Printf("Unknown target: %s\n", target)
-		fmt.Println("Supported targets: mongodb, mysql, postgresql, all")
+		fmt.Println("Supported targets: mongodb, mysql, postgresql, sqlite, redis, all")
 		return
 	}
 
@@ -112,7 +149,7 @@ func cleanupBackups(target string) {
 	}
 }
 
-func cleanupDatabaseBackups(dbDir, dbName string, isDirectory bool) (int, int64) {
+func cleanupDatabaseBackups(dbDir, dbName string) (int, int64) {
 	backupPath := filepath.Join("BACKUP", dbDir)
 
 	// Check if backup directory exists
@@ -120,13 +157,7 @@ func cleanupDatabaseBackups(dbDir, dbName string, isDirectory bool) (int, int64)
 		return 0, 0
 	}
 
-	var backups []BackupInfo
-
-	if isDirectory {
-		backups = listDirBackups(backupPath)
-	} else {
-		backups = listFileBackups(backupPath, ".sql")
-	}
+	backups := engineBackups(dbDir)
 
 	if len(backups) == 0 {
 		return 0, 0
@@ -135,11 +166,42 @@ func cleanupDatabaseBackups(dbDir, dbName string, isDirectory bool) (int, int64)
 	fmt.Printf("📦 %s Backups\n", dbName)
 	fmt.Println("──────────────────────────────────────────────────────────")
 
+	// Each database's full, schema-only and data-only backups get their own
+	// retention, so one database's backups never stand in for another's
+	var totalDeleted int
+	var totalFreed int64
+
+	groups := groupBackups(backups)
+	for _, group := range groups {
+		if len(groups) > 1 {
+			fmt.Printf("   [%s]\n", group.label())
+		}
+
+		deleted, freed := cleanupBackupGroup(group.backups)
+		totalDeleted += deleted
+		totalFreed += freed
+	}
+
+	return totalDeleted, totalFreed
+}
+
+// cleanupBackupGroup applies the retention policy and safety guards to
+// one group's backups, sorted newest first.
+func cleanupBackupGroup(backups []BackupInfo) (int, int64) {
 	// Determine which backups to delete
 	var toDelete []BackupInfo
+	succeeded := succeededBackups()
 
 	if keepDays > 0 {
 		cutoffDate := time.Now().AddDate(0, 0, -keepDays)
+
+		if !hasBackupSince(backups, cutoffDate, succeeded) && !forceCleanup {
+			fmt.Printf("   ⚠️  No successful backup in the last %d days - refusing to delete anything\n", keepDays)
+			fmt.Println("   Run a backup first, or use --force to delete anyway")
+			fmt.Println()
+			return 0, 0
+		}
+
 		for _, backup := range backups {
 			if backup.ModTime.Before(cutoffDate) {
 				toDelete = append(toDelete, backup)
@@ -151,6 +213,15 @@ func cleanupDatabaseBackups(dbDir, dbName string, isDirectory bool) (int, int64)
 		}
 	}
 
+	toDelete, kept := applySafetyGuards(backups, toDelete, loadPins(), succeeded)
+	for _, backup := range kept {
+		fmt.Printf("   Keeping: %-35s %10s  (%s)\n",
+			backup.Name,
+			formatSize(backup.Size),
+			backup.reason,
+		)
+	}
+
 	if len(toDelete) == 0 {
 		fmt.Printf("   No old backups to clean (keeping ")
 		if keepDays > 0 {
@@ -194,6 +265,10 @@ func cleanupDatabaseBackups(dbDir, dbName string, isDirectory bool) (int, int64)
 				fmt.Printf("      Error: %v\n", err)
 				continue
 			}
+
+			if err := manifest.Remove(backup.Path); err != nil {
+				fmt.Printf("      Error removing manifest: %v\n", err)
+			}
 		}
 
 		deletedCount++
@@ -205,6 +280,151 @@ func cleanupDatabaseBackups(dbDir, dbName string, isDirectory bool) (int, int64)
 	return deletedCount, freedSpace
 }
 
+type backupGroup struct {
+	target  string
+	kind    string
+	backups []BackupInfo
+}
+
+// label names the group in cleanup's output, e.g. "shop, schema-only".
+func (g backupGroup) label() string {
+	if g.kind == manifest.KindFull {
+		return g.target
+	}
+	return fmt.Sprintf("%s, %s-only", g.target, g.kind)
+}
+
+// groupBackups splits backups by target and kind, keeping their order.
+// Groups are sorted by target, with full backups first.
+func groupBackups(backups []BackupInfo) []backupGroup {
+	byTarget := make(map[string][]BackupInfo)
+	for _, backup := range backups {
+		target := backupTarget(backup)
+		byTarget[target] = append(byTarget[target], backup)
+	}
+
+	targets := make([]string, 0, len(byTarget))
+	for target := range byTarget {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	var groups []backupGroup
+	for _, target := range targets {
+		for _, kind := range []string{manifest.KindFull, manifest.KindSchema, manifest.KindData} {
+			group := backupGroup{target: target, kind: kind}
+			for _, backup := range byTarget[target] {
+				if backupKind(backup) == kind {
+					group.backups = append(group.backups, backup)
+				}
+			}
+			if len(group.backups) > 0 {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
+
+// backupTimestamp matches the timestamp in backup names.
+var backupTimestamp = regexp.MustCompile(`_\d{4}-\d{2}-\d{2}_\d{2}-\d{2}-\d{2}`)
+
+// backupTarget returns what a backup is of: the database its manifest
+// names, or else its name up to the timestamp, e.g. "cluster" for a
+// PostgreSQL cluster set or "base" for a base backup.
+func backupTarget(backup BackupInfo) string {
+	if m, err := manifest.Read(backup.Path); err == nil && m.Database != "" {
+		return m.Database
+	}
+	if loc := backupTimestamp.FindStringIndex(backup.Name); loc != nil {
+		return backup.Name[:loc[0]]
+	}
+	return backup.Name
+}
+
+// keptBackup is a retention candidate that a safety guard kept.
+type keptBackup struct {
+	BackupInfo
+	reason string
+}
+
+// isSuccessfulBackup reports whether a backup is known to be complete. A
+// failed dump can leave a truncated file behind, so the size proves
+// nothing: the backup needs a manifest, which is only written once the dump
+// succeeded, or a successful run in the history (PostgreSQL base backups
+// and cluster sets from older versions have no manifest).
+func isSuccessfulBackup(backup BackupInfo, succeeded map[string]bool) bool {
+	if backup.Size == 0 {
+		return false
+	}
+	if _, err := manifest.Read(backup.Path); err == nil {
+		return true
+	}
+	return succeeded[filepath.Clean(backup.Path)]
+}
+
+// succeededBackups returns the paths of the backups the run history
+// records as successful.
+func succeededBackups() map[string]bool {
+	succeeded := make(map[string]bool)
+	runs, err := history.Load()
+	if err != nil {
+		fmt.Printf("   Warning: failed to read the run history: %v\n", err)
+	}
+	for _, run := range runs {
+		if run.Operation == "backup" && run.Status == history.StatusSuccess && run.Path != "" {
+			succeeded[filepath.Clean(run.Path)] = true
+		}
+	}
+	return succeeded
+}
+
+// hasBackupSince reports whether a successful backup newer than cutoff exists.
+func hasBackupSince(backups []BackupInfo, cutoff time.Time, succeeded map[string]bool) bool {
+	for _, backup := range backups {
+		if isSuccessfulBackup(backup, succeeded) && backup.ModTime.After(cutoff) {
+			return true
+		}
+	}
+	return false
+}
+
+// applySafetyGuards removes pinned backups and the newest successful backups
+// (at least one, or --min-keep) from the deletion candidates. Backups must be
+// sorted newest first.
+func applySafetyGuards(backups, candidates []BackupInfo, pins, succeeded map[string]bool) ([]BackupInfo, []keptBackup) {
+	required := minKeep
+	if required < 1 {
+		required = 1
+	}
+
+	protected := make(map[string]string)
+	for _, backup := range backups {
+		if required == 0 {
+			break
+		}
+		if isSuccessfulBackup(backup, succeeded) {
+			protected[backup.Path] = "minimum retention"
+			required--
+		}
+	}
+
+	var toDelete []BackupInfo
+	var kept []keptBackup
+
+	for _, backup := range candidates {
+		if isPinned(pins, backup) {
+			kept = append(kept, keptBackup{backup, "pinned"})
+		} else if reason, ok := protected[backup.Path]; ok {
+			kept = append(kept, keptBackup{backup, reason})
+		} else {
+			toDelete = append(toDelete, backup)
+		}
+	}
+
+	return toDelete, kept
+}
+
 func formatAge(d time.Duration) string {
 	days := int(d.Hours() / 24)
 	if days == 0 {
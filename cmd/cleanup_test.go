@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+)
+
+// writeBackup creates a backup in dir with the given age, and a manifest
+// for database when good is set. Names without an extension are directory
+// backups.
+func writeBackup(t *testing.T, dir, name, database string, age time.Duration, good bool) BackupInfo {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	isDir := !strings.Contains(name, ".")
+	file := path
+	if isDir {
+		if err := os.Mkdir(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		file = filepath.Join(path, "base.tar")
+	}
+	if err := os.WriteFile(file, []byte("backup"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if good {
+		if err := manifest.Write(path, &manifest.Manifest{Engine: "postgresql", Database: database, CreatedAt: modTime}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return BackupInfo{Name: name, Path: path, Size: 6, ModTime: modTime, IsDir: isDir}
+}
+
+// setCleanupFlags sets the cleanup flags for one test.
+func setCleanupFlags(t *testing.T, days, keep, minimum int) {
+	t.Helper()
+
+	oldDays, oldKeep, oldMin, oldDryRun, oldForce := keepDays, keepCount, minKeep, dryRun, forceCleanup
+	t.Cleanup(func() {
+		keepDays, keepCount, minKeep, dryRun, forceCleanup = oldDays, oldKeep, oldMin, oldDryRun, oldForce
+	})
+	keepDays, keepCount, minKeep, dryRun, forceCleanup = days, keep, minimum, true, false
+}
+
+func backupNames(backups []BackupInfo) []string {
+	var names []string
+	for _, backup := range backups {
+		names = append(names, backup.Name)
+	}
+	return names
+}
+
+func TestBackupTarget(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		backup BackupInfo
+		want   string
+	}{
+		{writeBackup(t, dir, "shop_2026-10-17_14-00-00.sql.gz", "", time.Hour, false), "shop"},
+		{writeBackup(t, dir, "my_shop_2026-10-17_14-00-00.schema.sql", "", time.Hour, false), "my_shop"},
+		{writeBackup(t, dir, "renamed_2026-10-17_14-00-00.sql", "crm", time.Hour, true), "crm"},
+		{writeBackup(t, dir, "cluster_2026-10-17_14-00-00", "", time.Hour, false), "cluster"},
+		{writeBackup(t, dir, "base_2026-10-17_14-00-00", "", time.Hour, false), "base"},
+		{writeBackup(t, dir, "hand-made.sql", "", time.Hour, false), "hand-made.sql"},
+	}
+
+	for _, tt := range tests {
+		if got := backupTarget(tt.backup); got != tt.want {
+			t.Errorf("backupTarget(%s) = %q, want %q", tt.backup.Name, got, tt.want)
+		}
+	}
+}
+
+func TestGroupBackups(t *testing.T) {
+	dir := t.TempDir()
+	backups := []BackupInfo{
+		writeBackup(t, dir, "shop_2026-10-17_14-00-00.sql", "shop", time.Hour, true),
+		writeBackup(t, dir, "cluster_2026-10-17_13-00-00", "", 2*time.Hour, false),
+		writeBackup(t, dir, "shop_2026-10-17_12-00-00.schema.sql", "", 3*time.Hour, false),
+		writeBackup(t, dir, "base_2026-10-17_11-00-00", "", 4*time.Hour, false),
+		writeBackup(t, dir, "crm_2026-10-17_10-00-00.sql", "crm", 5*time.Hour, true),
+		writeBackup(t, dir, "shop_2026-10-17_09-00-00.sql", "shop", 6*time.Hour, true),
+	}
+
+	type group struct {
+		label   string
+		backups []string
+	}
+	want := []group{
+		{"base", []string{"base_2026-10-17_11-00-00"}},
+		{"cluster", []string{"cluster_2026-10-17_13-00-00"}},
+		{"crm", []string{"crm_2026-10-17_10-00-00.sql"}},
+		{"shop", []string{"shop_2026-10-17_14-00-00.sql", "shop_2026-10-17_09-00-00.sql"}},
+		{"shop, schema-only", []string{"shop_2026-10-17_12-00-00.schema.sql"}},
+	}
+
+	var got []group
+	for _, g := range groupBackups(backups) {
+		got = append(got, group{g.label(), backupNames(g.backups)})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupBackups() = %v, want %v", got, want)
+	}
+}
+
+func TestApplySafetyGuards(t *testing.T) {
+	dir := t.TempDir()
+	newestFailed := writeBackup(t, dir, "shop_2026-10-17_14-00-00.sql", "", time.Hour, false)
+	good := writeBackup(t, dir, "shop_2026-10-16_14-00-00.sql", "shop", 25*time.Hour, true)
+	older := writeBackup(t, dir, "shop_2026-10-15_14-00-00.sql", "shop", 49*time.Hour, true)
+	fromHistory := writeBackup(t, dir, "shop_2026-10-14_14-00-00.sql", "", 73*time.Hour, false)
+	pinned := writeBackup(t, dir, "shop_2026-10-13_14-00-00.sql", "shop", 97*time.Hour, true)
+	backups := []BackupInfo{newestFailed, good, older, fromHistory, pinned}
+
+	pins := map[string]bool{filepath.Clean(pinned.Path): true}
+	succeeded := map[string]bool{filepath.Clean(fromHistory.Path): true}
+
+	tests := []struct {
+		name       string
+		minKeep    int
+		wantDelete []string
+		wantKept   []string
+	}{
+		{
+			name:       "newest successful backup",
+			minKeep:    1,
+			wantDelete: []string{newestFailed.Name, older.Name, fromHistory.Name},
+			wantKept:   []string{good.Name, pinned.Name},
+		},
+		{
+			name:       "min-keep 0 still keeps one",
+			minKeep:    0,
+			wantDelete: []string{newestFailed.Name, older.Name, fromHistory.Name},
+			wantKept:   []string{good.Name, pinned.Name},
+		},
+		{
+			name:       "min-keep counts history successes",
+			minKeep:    3,
+			wantDelete: []string{newestFailed.Name},
+			wantKept:   []string{good.Name, older.Name, fromHistory.Name, pinned.Name},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setCleanupFlags(t, 0, 0, tt.minKeep)
+
+			toDelete, kept := applySafetyGuards(backups, backups, pins, succeeded)
+			if got := backupNames(toDelete); !reflect.DeepEqual(got, tt.wantDelete) {
+				t.Errorf("deleted %v, want %v", got, tt.wantDelete)
+			}
+			var keptNames []string
+			for _, backup := range kept {
+				keptNames = append(keptNames, backup.Name)
+			}
+			if !reflect.DeepEqual(keptNames, tt.wantKept) {
+				t.Errorf("kept %v, want %v", keptNames, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestHasBackupSince(t *testing.T) {
+	dir := t.TempDir()
+	cutoff := time.Now().Add(-24 * time.Hour)
+
+	recentFailed := writeBackup(t, dir, "shop_2026-10-17_14-00-00.sql", "", time.Hour, false)
+	oldGood := writeBackup(t, dir, "shop_2026-10-10_14-00-00.sql", "shop", 7*24*time.Hour, true)
+	if hasBackupSince([]BackupInfo{recentFailed, oldGood}, cutoff, nil) {
+		t.Error("a failed backup counted as a recent successful one")
+	}
+
+	recentGood := writeBackup(t, dir, "shop_2026-10-17_15-00-00.sql", "shop", time.Hour, true)
+	if !hasBackupSince([]BackupInfo{recentGood, recentFailed, oldGood}, cutoff, nil) {
+		t.Error("a recent successful backup wasn't found")
+	}
+}
+
+func TestCleanupGuardsPerDatabase(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := filepath.Join("BACKUP", "postgresql")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// shop is backed up daily; crm's only good backup is old, and a cluster
+	// set and a base backup have no manifest
+	writeBackup(t, dir, "shop_2026-10-17_14-00-00.sql", "shop", time.Hour, true)
+	writeBackup(t, dir, "shop_2026-10-16_14-00-00.sql", "shop", 25*time.Hour, true)
+	writeBackup(t, dir, "shop_2026-10-01_14-00-00.sql", "shop", 16*24*time.Hour, true)
+	writeBackup(t, dir, "crm_2026-10-01_14-00-00.sql", "crm", 16*24*time.Hour, true)
+	writeBackup(t, dir, "cluster_2026-10-01_14-00-00", "", 16*24*time.Hour, false)
+	writeBackup(t, dir, "base_2026-10-01_14-00-00", "", 16*24*time.Hour, false)
+
+	if got := len(engineBackups("postgresql")); got != 6 {
+		t.Fatalf("listed %d backups, want 6", got)
+	}
+
+	t.Run("days", func(t *testing.T) {
+		setCleanupFlags(t, 7, 0, 1)
+
+		// Only shop's old backup goes: crm, cluster and base have nothing
+		// recent to fall back on
+		if deleted, _ := cleanupDatabaseBackups("postgresql", "PostgreSQL"); deleted != 1 {
+			t.Errorf("cleanup would delete %d backups, want 1", deleted)
+		}
+	})
+
+	t.Run("keep", func(t *testing.T) {
+		setCleanupFlags(t, 0, 1, 1)
+
+		// --keep 1 leaves one backup of each target
+		if deleted, _ := cleanupDatabaseBackups("postgresql", "PostgreSQL"); deleted != 2 {
+			t.Errorf("cleanup would delete %d backups, want 2", deleted)
+		}
+	})
+}
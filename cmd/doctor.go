@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -57,9 +56,11 @@ func runDoctorAnalysis() {
 	}
 
 	// Analyze each database type
-	mongoStats := analyzeBackups(filepath.Join(backupDir, "mongo"), "MongoDB")
-	mysqlStats := analyzeBackups(filepath.Join(backupDir, "mysql"), "MySQL")
-	pgStats := analyzeBackups(filepath.Join(backupDir, "postgresql"), "PostgreSQL")
+	mongoStats := analyzeBackups("mongo", "MongoDB")
+	mysqlStats := analyzeBackups("mysql", "MySQL")
+	pgStats := analyzeBackups("postgresql", "PostgreSQL")
+	sqliteStats := analyzeBackups("sqlite", "SQLite")
+	redisStats := analyzeBackups("redis", "Redis")
 
 	// Print individual database analyses
 	if mongoStats.TotalBackups > 0 {
@@ -71,9 +72,15 @@ func runDoctorAnalysis() {
 	if pgStats.TotalBackups > 0 {
 		printDatabaseAnalysis("PostgreSQL", pgStats)
 	}
+	if sqliteStats.TotalBackups > 0 {
+		printDatabaseAnalysis("SQLite", sqliteStats)
+	}
+	if redisStats.TotalBackups > 0 {
+		printDatabaseAnalysis("Redis", redisStats)
+	}
 
 	// Calculate overall health score
-	allStats := []*BackupStats{&mongoStats, &mysqlStats, &pgStats}
+	allStats := []*BackupStats{&mongoStats, &mysqlStats, &pgStats, &sqliteStats, &redisStats}
 	healthScore := calculateHealthScore(allStats)
 
 	// Print overall summary
@@ -83,40 +90,15 @@ func runDoctorAnalysis() {
 	printRecommendations(allStats, healthScore)
 }
 
-func analyzeBackups(path, dbType string) BackupStats {
+func analyzeBackups(dbDir, dbType string) BackupStats {
 	stats := BackupStats{
 		SizeHistory: make([]int64, 0),
 		TimeHistory: make([]time.Time, 0),
 		Anomalies:   make([]string, 0),
 	}
 
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		return stats
-	}
-
-	backups := make([]BackupInfo, 0)
-
 	// Collect all backup info
-	for _, entry := range entries {
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
-
-		var size int64
-		if entry.IsDir() {
-			size = getDirSize(filepath.Join(path, entry.Name()))
-		} else {
-			size = info.Size()
-		}
-
-		backups = append(backups, BackupInfo{
-			Name:    entry.Name(),
-			Size:    size,
-			ModTime: info.ModTime(),
-		})
-	}
+	backups := engineBackups(dbDir)
 
 	if len(backups) == 0 {
 		return stats
@@ -384,7 +366,7 @@ func printOverallSummary(allStats []*BackupStats, healthScore int) {
 	}
 
 	fmt.Printf("  📦 Total Backups: %d\n", totalBackups)
-	fmt.Printf("  💾 Total Storage: %s\n", totalSize)
+	fmt.Printf("  💾 Total Storage: %s\n", formatSize(totalSize))
 }
 
 func printRecommendations(allStats []*BackupStats, healthScore int) {
@@ -446,5 +428,5 @@ func printRecommendations(allStats []*BackupStats, healthScore int) {
 		}
 	}
 
-	fmt.Println("\n")
+	fmt.Print("\n\n")
 }
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/tiyfiy/BackItUp/internal/backend"
+)
+
+// execBackend returns where the configured client tools run.
+func execBackend(execCfg backend.Config) backend.Backend {
+	b, err := backend.New(execCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return b
+}
+
+// requireTool exits when b can't run name. missing is the message for a
+// tool that isn't installed on this machine.
+func requireTool(b backend.Backend, name, missing string) {
+	err := b.LookPath(name)
+	if err == nil {
+		return
+	}
+	if b.Local() {
+		log.Fatal(missing)
+	}
+	log.Fatal(err)
+}
+
+// archiveInput points a pg_restore run by b at an archive. Tools in a
+// container can't open files here, so the archive is streamed to their
+// stdin instead, which pg_restore only supports for single-file archives
+// restored without parallel jobs. The returned func closes the archive.
+func archiveInput(b backend.Backend, cmd *exec.Cmd, backupPath string) (func(), error) {
+	if b.Local() {
+		cmd.Args = append(cmd.Args, backupPath)
+		return func() {}, nil
+	}
+
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("directory archives can't be streamed into pg_restore in %s, restore them with local tools", b)
+	}
+
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	backend.SetStdin(cmd, file)
+	return func() { file.Close() }, nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/history"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+)
+
+var (
+	historyLimit  int
+	historyFailed bool
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent backup and restore runs",
+	Long: `Show the most recent backup and restore runs, newest first, with their
+outcome. Failed runs include the tool's error output and a hint for common
+causes such as a wrong password or a full disk.
+
+Runs are recorded in BACKUP/history.jsonl.
+
+Examples:
+  ./BackItUp history
+  ./BackItUp history --failed        # Only failed runs
+  ./BackItUp history -n 50`,
+	Run: func(cmd *cobra.Command, args []string) {
+		showHistory()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Number of runs to show")
+	historyCmd.Flags().BoolVar(&historyFailed, "failed", false, "Show only failed runs")
+}
+
+func showHistory() {
+	runs, err := history.Load()
+	if err != nil {
+		log.Fatal("Failed to read run history:", err)
+	}
+
+	var shown []history.Run
+	for i := len(runs) - 1; i >= 0 && len(shown) < historyLimit; i-- {
+		if historyFailed && runs[i].Status != history.StatusFailed {
+			continue
+		}
+		shown = append(shown, runs[i])
+	}
+
+	if len(shown) == 0 {
+		fmt.Println("No runs recorded yet.")
+		return
+	}
+
+	fmt.Println("📜 Run History")
+	fmt.Println("══════════════════════════════════════════════════════════════")
+	for _, run := range shown {
+		icon := "✅"
+		if run.Status == history.StatusFailed {
+			icon = "❌"
+		}
+
+		duration := time.Duration(run.Duration * float64(time.Second)).Round(time.Second)
+		fmt.Printf("%s %s  %-7s %-25s %8s  %10s\n",
+			icon,
+			run.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			run.Operation,
+			runTarget(run),
+			duration,
+			progress.FormatBytes(run.Size),
+		)
+
+		if run.Status == history.StatusFailed {
+			fmt.Printf("   %s\n", run.Error)
+			if run.Hint != "" {
+				fmt.Printf("   💡 %s\n", run.Hint)
+			}
+		}
+		for _, h := range run.Hooks {
+			if h.Status == history.StatusFailed {
+				fmt.Printf("   🪝 %s hook %s failed: %s\n", h.Event, h.Hook, h.Error)
+			}
+		}
+	}
+}
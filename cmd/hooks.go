@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/hook"
+)
+
+// hookWhen says when each event's hooks run, for the flag help.
+var hookWhen = map[string]string{
+	hook.PreBackup:   "before each backup",
+	hook.PostBackup:  "after each backup, successful or not",
+	hook.OnFailure:   "when a backup or restore fails",
+	hook.PreRestore:  "before each restore",
+	hook.PostRestore: "after each restore, successful or not",
+}
+
+// addHookFlags adds the flags that configure an engine's hooks.
+func addHookFlags(c *cobra.Command) {
+	for _, event := range hook.Events {
+		c.Flags().StringArray("hook-"+event, nil, fmt.Sprintf("Shell command or http(s) URL to run %s (repeatable, \"\" clears)", hookWhen[event]))
+	}
+	c.Flags().Int("hook-timeout", 0, "Seconds each hook may run (default 60)")
+	c.Flags().String("hook-pre-failure", "", "When a pre hook fails: abort (the default) or continue")
+}
+
+// changedHooks returns the first event whose hooks were given on the
+// command line, and those hooks.
+func changedHooks(cmd *cobra.Command) (string, []string) {
+	for _, event := range hook.Events {
+		if !cmd.Flags().Changed("hook-" + event) {
+			continue
+		}
+		values, _ := cmd.Flags().GetStringArray("hook-" + event)
+		var hooks []string
+		for _, h := range values {
+			if h != "" {
+				hooks = append(hooks, h)
+			}
+		}
+		return event, hooks
+	}
+	return "", nil
+}
@@ -5,9 +5,13 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+	"github.com/tiyfiy/BackItUp/internal/mongodb"
+	"github.com/tiyfiy/BackItUp/internal/postgresql"
 )
 
 type BackupInfo struct {
@@ -44,7 +48,7 @@ func listBackups() {
 	hasBackups := false
 
 	// List MongoDB backups
-	mongoBackups := listDirBackups(filepath.Join(backupDir, "mongo"))
+	mongoBackups := engineBackups("mongo")
 	if len(mongoBackups) > 0 {
 		hasBackups = true
 		fmt.Println("\n📦 MongoDB Backups:")
@@ -53,7 +57,7 @@ func listBackups() {
 	}
 
 	// List MySQL backups
-	mysqlBackups := listFileBackups(filepath.Join(backupDir, "mysql"), ".sql")
+	mysqlBackups := engineBackups("mysql")
 	if len(mysqlBackups) > 0 {
 		hasBackups = true
 		fmt.Println("\n📦 MySQL Backups:")
@@ -62,7 +66,7 @@ func listBackups() {
 	}
 
 	// List PostgreSQL backups
-	pgBackups := listFileBackups(filepath.Join(backupDir, "postgresql"), ".sql")
+	pgBackups := engineBackups("postgresql")
 	if len(pgBackups) > 0 {
 		hasBackups = true
 		fmt.Println("\n📦 PostgreSQL Backups:")
@@ -70,6 +74,24 @@ func listBackups() {
 		printBackupList(pgBackups)
 	}
 
+	// List SQLite backups
+	sqliteBackups := engineBackups("sqlite")
+	if len(sqliteBackups) > 0 {
+		hasBackups = true
+		fmt.Println("\n📦 SQLite Backups:")
+		fmt.Println("══════════════════════════════════════════════════════════════")
+		printBackupList(sqliteBackups)
+	}
+
+	// List Redis backups
+	redisBackups := engineBackups("redis")
+	if len(redisBackups) > 0 {
+		hasBackups = true
+		fmt.Println("\n📦 Redis Backups:")
+		fmt.Println("══════════════════════════════════════════════════════════════")
+		printBackupList(redisBackups)
+	}
+
 	if !hasBackups {
 		fmt.Println("\nNo backups found.")
 		fmt.Println("Run a backup command to create your first backup.")
@@ -78,6 +100,43 @@ func listBackups() {
 	}
 }
 
+// engineBackups lists the backups stored under BACKUP/<dbDir>, newest first,
+// recognizing every layout the engine can produce.
+func engineBackups(dbDir string) []BackupInfo {
+	path := filepath.Join("BACKUP", dbDir)
+
+	switch dbDir {
+	case "mongo":
+		// mongodump directories and single-file archives. Captured oplog
+		// isn't a backup on its own.
+		var dirs []BackupInfo
+		for _, backup := range listDirBackups(path) {
+			if backup.Path != filepath.Clean(mongodb.OplogDir) {
+				dirs = append(dirs, backup)
+			}
+		}
+		return sortBackups(append(dirs, listFileBackups(path, ".archive", ".archive.gz")...))
+	case "postgresql":
+		// Plain SQL, custom format (.dump), directory format dumps, cluster
+		// sets and base backups. Archived WAL isn't a backup on its own.
+		var dirs []BackupInfo
+		for _, backup := range listDirBackups(path) {
+			if backup.Path != filepath.Clean(postgresql.WALDir) {
+				dirs = append(dirs, backup)
+			}
+		}
+		return sortBackups(append(listFileBackups(path, ".sql", ".sql.gz", ".dump"), dirs...))
+	case "sqlite":
+		// Database copies and SQL scripts
+		return listFileBackups(path, ".db", ".sql", ".sql.gz")
+	case "redis":
+		// RDB snapshots
+		return listFileBackups(path, ".rdb", ".rdb.gz")
+	default:
+		return listFileBackups(path, ".sql", ".sql.gz")
+	}
+}
+
 func listDirBackups(path string) []BackupInfo {
 	var backups []BackupInfo
 
@@ -112,7 +171,7 @@ func listDirBackups(path string) []BackupInfo {
 	return backups
 }
 
-func listFileBackups(path, extension string) []BackupInfo {
+func listFileBackups(path string, extensions ...string) []BackupInfo {
 	var backups []BackupInfo
 
 	entries, err := os.ReadDir(path)
@@ -121,7 +180,7 @@ func listFileBackups(path, extension string) []BackupInfo {
 	}
 
 	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == extension {
+		if !entry.IsDir() && hasExtension(entry.Name(), extensions) {
 			info, err := entry.Info()
 			if err != nil {
 				continue
@@ -145,6 +204,38 @@ func listFileBackups(path, extension string) []BackupInfo {
 	return backups
 }
 
+// backupKind returns whether a backup is full, schema-only or data-only.
+// The manifest is authoritative; the name tag covers backups whose manifest
+// went missing.
+func backupKind(backup BackupInfo) string {
+	if m, err := manifest.Read(backup.Path); err == nil && m.Kind != "" {
+		return m.Kind
+	}
+	for _, kind := range []string{manifest.KindSchema, manifest.KindData} {
+		if strings.Contains(backup.Name, "."+kind+".") || strings.HasSuffix(backup.Name, "."+kind) {
+			return kind
+		}
+	}
+	return manifest.KindFull
+}
+
+func hasExtension(name string, extensions []string) bool {
+	for _, ext := range extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortBackups sorts by modification time (newest first).
+func sortBackups(backups []BackupInfo) []BackupInfo {
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime.After(backups[j].ModTime)
+	})
+	return backups
+}
+
 func getDirSize(path string) int64 {
 	var size int64
 	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
@@ -160,17 +251,31 @@ func getDirSize(path string) int64 {
 }
 
 func printBackupList(backups []BackupInfo) {
+	pins := loadPins()
+
 	for _, backup := range backups {
 		typeStr := "file"
 		if backup.IsDir {
 			typeStr = "dir "
 		}
 
-		fmt.Printf("  [%s] %-40s %10s  %s\n",
+		tags := ""
+		if kind := backupKind(backup); kind != manifest.KindFull {
+			tags += fmt.Sprintf("  (%s only)", kind)
+		}
+		if m, err := manifest.Read(backup.Path); err == nil && m.Filters != nil {
+			tags += "  (partial)"
+		}
+		if isPinned(pins, backup) {
+			tags += "  📌"
+		}
+
+		fmt.Printf("  [%s] %-40s %10s  %s%s\n",
 			typeStr,
 			backup.Name,
 			formatSize(backup.Size),
 			backup.ModTime.Format("2006-01-02 15:04:05"),
+			tags,
 		)
 	}
 }
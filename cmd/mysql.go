@@ -5,8 +5,13 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/backend"
 	"github.com/tiyfiy/BackItUp/internal/config"
+	"github.com/tiyfiy/BackItUp/internal/hook"
+	"github.com/tiyfiy/BackItUp/internal/manifest"
 	"github.com/tiyfiy/BackItUp/internal/mysql"
+	"github.com/tiyfiy/BackItUp/internal/preflight"
+	"github.com/tiyfiy/BackItUp/internal/tlsconf"
 )
 
 var mysqlCmd = &cobra.Command{
@@ -17,8 +22,22 @@ var mysqlCmd = &cobra.Command{
 	Run: backupMySQL,
 }
 
+var mysqlBinlogFollowCmd = &cobra.Command{
+	Use:   "binlog-follow",
+	Short: "Continuously collect MySQL binary logs for point-in-time recovery",
+	Long: `Stream binary logs from the configured MySQL server into BACKUP/mysql/binlog
+with mysqlbinlog --read-from-remote-server --raw. Runs until interrupted and
+resumes from the newest collected binlog when restarted.
+
+Together with a dump, the collected binlogs let you restore to any point
+in time:
+  ./BackItUp restore mysql --pitr "2026-10-17 14:32"`,
+	Run: followMySQLBinlogs,
+}
+
 func init() {
 	rootCmd.AddCommand(mysqlCmd)
+	mysqlCmd.AddCommand(mysqlBinlogFollowCmd)
 
 	mysqlCmd.Flags().Bool("config", false, "Configure MySQL settings")
 	mysqlCmd.Flags().String("host", "", "MySQL host")
@@ -26,6 +45,28 @@ func init() {
 	mysqlCmd.Flags().String("user", "", "MySQL user")
 	mysqlCmd.Flags().String("password", "", "MySQL password")
 	mysqlCmd.Flags().String("database", "", "MySQL database")
+	mysqlCmd.Flags().StringSlice("include", nil, "Only back up tables matching these glob patterns")
+	mysqlCmd.Flags().StringSlice("exclude", nil, "Skip tables matching these glob patterns")
+	mysqlCmd.Flags().Bool("schema-only", false, "Back up only the schema, no data")
+	mysqlCmd.Flags().Bool("data-only", false, "Back up only the data, no schema")
+	mysqlCmd.Flags().String("dumper", "", "Dump with mysqldump or native (built in, no client tools needed)")
+	mysqlCmd.Flags().String("socket", "", "Unix socket to connect through instead of host and port")
+	mysqlCmd.Flags().String("tls-mode", "", "TLS mode: disable, prefer, require, verify-ca or verify-full")
+	mysqlCmd.Flags().String("tls-ca", "", "CA certificate (PEM) to check the server certificate against")
+	mysqlCmd.Flags().String("tls-cert", "", "Client certificate (PEM)")
+	mysqlCmd.Flags().String("tls-key", "", "Client certificate key (PEM)")
+	mysqlCmd.Flags().String("tls-server-name", "", "Name to check the server certificate against, when it isn't the host")
+	mysqlCmd.Flags().String("params", "", `Extra driver parameters, e.g. "timeout=5s&charset=utf8mb4"`)
+	mysqlCmd.Flags().String("ssh-host", "", "Bastion to tunnel through over SSH (host or host:port)")
+	mysqlCmd.Flags().String("ssh-user", "", "SSH user")
+	mysqlCmd.Flags().String("ssh-key", "", "SSH private key (the SSH agent is used without one)")
+	mysqlCmd.Flags().String("ssh-known-hosts", "", "known_hosts file to check host keys against (default ~/.ssh/known_hosts)")
+	mysqlCmd.Flags().StringSlice("ssh-jump", nil, "Jump hosts to go through before the bastion, as [user@]host[:port]")
+	mysqlCmd.Flags().String("exec", "", "Where to run mysqldump and mysql: local, docker or kubectl")
+	mysqlCmd.Flags().String("exec-container", "", "Docker container to run the tools in, or the container in the kubectl pod")
+	mysqlCmd.Flags().String("exec-pod", "", "Pod to run the tools in with kubectl exec, e.g. db-0 or deploy/db")
+	mysqlCmd.Flags().String("exec-namespace", "", "Namespace of the kubectl pod")
+	addHookFlags(mysqlCmd)
 }
 
 func backupMySQL(cmd *cobra.Command, args []string) {
@@ -35,6 +76,45 @@ func backupMySQL(cmd *cobra.Command, args []string) {
 	user, _ := cmd.Flags().GetString("user")
 	password, _ := cmd.Flags().GetString("password")
 	database, _ := cmd.Flags().GetString("database")
+	include, _ := cmd.Flags().GetStringSlice("include")
+	exclude, _ := cmd.Flags().GetStringSlice("exclude")
+	schemaOnly, _ := cmd.Flags().GetBool("schema-only")
+	dataOnly, _ := cmd.Flags().GetBool("data-only")
+	dumper, _ := cmd.Flags().GetString("dumper")
+	socket, _ := cmd.Flags().GetString("socket")
+	tlsMode, _ := cmd.Flags().GetString("tls-mode")
+	tlsCA, _ := cmd.Flags().GetString("tls-ca")
+	tlsCert, _ := cmd.Flags().GetString("tls-cert")
+	tlsKey, _ := cmd.Flags().GetString("tls-key")
+	tlsServerName, _ := cmd.Flags().GetString("tls-server-name")
+	params, _ := cmd.Flags().GetString("params")
+	sshHost, _ := cmd.Flags().GetString("ssh-host")
+	sshUser, _ := cmd.Flags().GetString("ssh-user")
+	sshKey, _ := cmd.Flags().GetString("ssh-key")
+	sshKnownHosts, _ := cmd.Flags().GetString("ssh-known-hosts")
+	sshJump, _ := cmd.Flags().GetStringSlice("ssh-jump")
+	execKind, _ := cmd.Flags().GetString("exec")
+	execContainer, _ := cmd.Flags().GetString("exec-container")
+	execPod, _ := cmd.Flags().GetString("exec-pod")
+	execNamespace, _ := cmd.Flags().GetString("exec-namespace")
+	hookTimeout, _ := cmd.Flags().GetInt("hook-timeout")
+	hookPreFailure, _ := cmd.Flags().GetString("hook-pre-failure")
+
+	if dumper != "" && !mysql.ValidDumper(dumper) {
+		log.Fatalf("unknown dumper %q, expected mysqldump or native", dumper)
+	}
+
+	if !tlsconf.ValidMode(tlsMode) {
+		log.Fatalf("unknown TLS mode %q, expected disable, prefer, require, verify-ca or verify-full", tlsMode)
+	}
+
+	if !backend.ValidKind(execKind) {
+		log.Fatalf("unknown exec backend %q, expected local, docker or kubectl", execKind)
+	}
+
+	if !hook.ValidPolicy(hookPreFailure) {
+		log.Fatalf("unknown pre hook failure policy %q, expected abort or continue", hookPreFailure)
+	}
 
 	if configMode {
 		if host != "" {
@@ -57,6 +137,102 @@ func backupMySQL(cmd *cobra.Command, args []string) {
 			config.SetMySQLDatabase(database)
 			fmt.Printf("MySQL database saved to config\n")
 			return
+		} else if cmd.Flags().Changed("include") {
+			config.SetMySQLInclude(include)
+			fmt.Printf("MySQL include filter saved to config\n")
+			return
+		} else if cmd.Flags().Changed("exclude") {
+			config.SetMySQLExclude(exclude)
+			fmt.Printf("MySQL exclude filter saved to config\n")
+			return
+		} else if cmd.Flags().Changed("schema-only") {
+			config.SetMySQLSchemaOnly(schemaOnly)
+			fmt.Printf("MySQL schema-only mode saved to config\n")
+			return
+		} else if cmd.Flags().Changed("data-only") {
+			config.SetMySQLDataOnly(dataOnly)
+			fmt.Printf("MySQL data-only mode saved to config\n")
+			return
+		} else if dumper != "" {
+			config.SetMySQLDumper(dumper)
+			fmt.Printf("MySQL dumper saved to config\n")
+			return
+		} else if socket != "" {
+			config.SetMySQLSocket(socket)
+			fmt.Printf("MySQL socket saved to config\n")
+			return
+		} else if tlsMode != "" {
+			config.SetMySQLTLSMode(tlsMode)
+			fmt.Printf("MySQL TLS mode saved to config\n")
+			return
+		} else if tlsCA != "" {
+			config.SetMySQLTLSCA(tlsCA)
+			fmt.Printf("MySQL TLS CA saved to config\n")
+			return
+		} else if tlsCert != "" {
+			config.SetMySQLTLSCert(tlsCert)
+			fmt.Printf("MySQL TLS client certificate saved to config\n")
+			return
+		} else if tlsKey != "" {
+			config.SetMySQLTLSKey(tlsKey)
+			fmt.Printf("MySQL TLS client key saved to config\n")
+			return
+		} else if tlsServerName != "" {
+			config.SetMySQLTLSServerName(tlsServerName)
+			fmt.Printf("MySQL TLS server name saved to config\n")
+			return
+		} else if params != "" {
+			config.SetMySQLParams(params)
+			fmt.Printf("MySQL parameters saved to config\n")
+			return
+		} else if sshHost != "" {
+			config.SetMySQLSSHHost(sshHost)
+			fmt.Printf("MySQL SSH host saved to config\n")
+			return
+		} else if sshUser != "" {
+			config.SetMySQLSSHUser(sshUser)
+			fmt.Printf("MySQL SSH user saved to config\n")
+			return
+		} else if sshKey != "" {
+			config.SetMySQLSSHKey(sshKey)
+			fmt.Printf("MySQL SSH key saved to config\n")
+			return
+		} else if sshKnownHosts != "" {
+			config.SetMySQLSSHKnownHosts(sshKnownHosts)
+			fmt.Printf("MySQL SSH known_hosts file saved to config\n")
+			return
+		} else if cmd.Flags().Changed("ssh-jump") {
+			config.SetMySQLSSHJump(sshJump)
+			fmt.Printf("MySQL SSH jump hosts saved to config\n")
+			return
+		} else if execKind != "" {
+			config.SetMySQLExec(execKind)
+			fmt.Printf("MySQL exec backend saved to config\n")
+			return
+		} else if execContainer != "" {
+			config.SetMySQLExecContainer(execContainer)
+			fmt.Printf("MySQL exec container saved to config\n")
+			return
+		} else if execPod != "" {
+			config.SetMySQLExecPod(execPod)
+			fmt.Printf("MySQL exec pod saved to config\n")
+			return
+		} else if execNamespace != "" {
+			config.SetMySQLExecNamespace(execNamespace)
+			fmt.Printf("MySQL exec namespace saved to config\n")
+			return
+		} else if event, hooks := changedHooks(cmd); event != "" {
+			config.SetMySQLHooks(event, hooks)
+			fmt.Printf("MySQL %s hooks saved to config\n", event)
+			return
+		} else if hookTimeout != 0 {
+			config.SetMySQLHookTimeout(hookTimeout)
+			fmt.Printf("MySQL hook timeout saved to config\n")
+			return
+		} else if hookPreFailure != "" {
+			config.SetMySQLHookPreFailure(hookPreFailure)
+			fmt.Printf("MySQL pre hook failure policy saved to config\n")
+			return
 		} else {
 			log.Fatal("when using config you must provide a value")
 		}
@@ -69,12 +245,98 @@ func backupMySQL(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 
-	db, err := mysql.Connection(cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.User, cfg.MySQL.Password, cfg.MySQL.Database)
+	if cmd.Flags().Changed("include") {
+		cfg.MySQL.Include = include
+	}
+	if cmd.Flags().Changed("exclude") {
+		cfg.MySQL.Exclude = exclude
+	}
+	if cmd.Flags().Changed("schema-only") || cmd.Flags().Changed("data-only") {
+		cfg.MySQL.SchemaOnly = schemaOnly
+		cfg.MySQL.DataOnly = dataOnly
+	}
+	if dumper != "" {
+		cfg.MySQL.Dumper = dumper
+	}
+
+	err = runBackup("mysql", cfg.MySQL.Database, cfg.MySQL.Hooks, func() (string, error) {
+		return backupMySQLDatabase(cfg.MySQL)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// backupMySQLDatabase connects to the configured server and dumps the
+// configured database.
+func backupMySQLDatabase(mysqlCfg config.MySQLConfig) (string, error) {
+	closeTunnel, err := tunnelMySQL(&mysqlCfg)
+	if err != nil {
+		return "", err
+	}
+	defer closeTunnel()
+
+	err = runPreflight(func() *preflight.Report { return preflightMySQL(mysqlCfg, opBackup) })
+	if err != nil {
+		return "", err
+	}
+
+	db, err := mysql.Connection(mysqlCfg.Host, mysqlCfg.Port, mysqlCfg.User, mysqlCfg.Password, mysqlCfg.Database, mysqlConn(mysqlCfg))
+	if err != nil {
+		return "", fmt.Errorf("error from the connection: %w", err)
+	}
+	defer db.Close()
+
+	return mysql.Backup(db, mysqlCfg.Host, mysqlCfg.Port, mysqlCfg.User, mysqlCfg.Password, mysqlCfg.Database, mysqlOptions(mysqlCfg))
+}
+
+func mysqlOptions(mysqlCfg config.MySQLConfig) mysql.BackupOptions {
+	return mysql.BackupOptions{
+		Kind:    backupKindOf(mysqlCfg.SchemaOnly, mysqlCfg.DataOnly),
+		Include: mysqlCfg.Include,
+		Exclude: mysqlCfg.Exclude,
+		Native:  mysqlCfg.Dumper == mysql.DumperNative,
+		Conn:    mysqlConn(mysqlCfg),
+	}
+}
+
+// mysqlConn returns the socket, TLS, driver and exec settings of the config.
+func mysqlConn(mysqlCfg config.MySQLConfig) mysql.ConnOptions {
+	return mysql.ConnOptions{
+		Socket: mysqlCfg.Socket,
+		TLS:    mysqlCfg.TLS,
+		Params: mysqlCfg.Params,
+		Exec:   execBackend(mysqlCfg.Exec),
+	}
+}
+
+func followMySQLBinlogs(cmd *cobra.Command, args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer mustTunnel(tunnelMySQL(&cfg.MySQL))()
+
+	db, err := mysql.Connection(cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.User, cfg.MySQL.Password, cfg.MySQL.Database, mysqlConn(cfg.MySQL))
 	if err != nil {
 		fmt.Println("error from the connection")
 		log.Fatal(err)
 	}
 	defer db.Close()
 
-	mysql.Backup(db, cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.User, cfg.MySQL.Password, cfg.MySQL.Database)
+	mysql.FollowBinlogs(db, cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.User, cfg.MySQL.Password, mysqlConn(cfg.MySQL))
+}
+
+// backupKindOf maps the schema-only and data-only settings to a backup kind.
+func backupKindOf(schemaOnly, dataOnly bool) string {
+	switch {
+	case schemaOnly && dataOnly:
+		log.Fatal("--schema-only and --data-only can't be combined")
+	case schemaOnly:
+		return manifest.KindSchema
+	case dataOnly:
+		return manifest.KindData
+	}
+	return manifest.KindFull
 }
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// pinFile lists backups that retention must never delete, one path per line.
+var pinFile = filepath.Join("BACKUP", ".pinned")
+
+var pinCmd = &cobra.Command{
+	Use:   "pin [backup]",
+	Short: "Protect a backup from cleanup",
+	Long: `Pin a backup so that cleanup never deletes it.
+
+The backup can be given as a path (BACKUP/mysql/mydb_2024-01-01_02-00-00.sql)
+or just its name. Run without arguments to show all pinned backups.
+
+Examples:
+  ./BackItUp pin mydb_2024-01-01_02-00-00.sql
+  ./BackItUp pin                                # Show pinned backups
+  ./BackItUp unpin mydb_2024-01-01_02-00-00.sql`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			showPinned()
+			return
+		}
+
+		path, err := resolveBackupPath(args[0])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+
+		pins := loadPins()
+		if pins[path] {
+			fmt.Printf("📌 %s is already pinned\n", path)
+			return
+		}
+		pins[path] = true
+
+		if err := savePins(pins); err != nil {
+			fmt.Println("Error saving pins:", err)
+			return
+		}
+		fmt.Printf("📌 Pinned %s\n", path)
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin <backup>",
+	Short: "Allow cleanup to delete a previously pinned backup",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := filepath.Clean(args[0])
+		if resolved, err := resolveBackupPath(args[0]); err == nil {
+			path = resolved
+		}
+
+		pins := loadPins()
+		if !pins[path] {
+			fmt.Printf("%s is not pinned\n", path)
+			return
+		}
+		delete(pins, path)
+
+		if err := savePins(pins); err != nil {
+			fmt.Println("Error saving pins:", err)
+			return
+		}
+		fmt.Printf("Unpinned %s\n", path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+	rootCmd.AddCommand(unpinCmd)
+}
+
+func showPinned() {
+	pins := loadPins()
+	if len(pins) == 0 {
+		fmt.Println("No pinned backups.")
+		return
+	}
+
+	paths := make([]string, 0, len(pins))
+	for path := range pins {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Println("📌 Pinned Backups:")
+	fmt.Println("══════════════════════════════════════════════════════════════")
+	for _, path := range paths {
+		status := ""
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			status = "  (missing)"
+		}
+		fmt.Printf("  %s%s\n", path, status)
+	}
+}
+
+// resolveBackupPath turns a backup path or bare backup name into the path
+// used by list and cleanup, so pins match regardless of how they were given.
+func resolveBackupPath(arg string) (string, error) {
+	if _, err := os.Stat(arg); err == nil {
+		return filepath.Clean(arg), nil
+	}
+
+	var matches []string
+	for _, dbDir := range []string{"mongo", "mysql", "postgresql", "sqlite", "redis"} {
+		candidate := filepath.Join("BACKUP", dbDir, arg)
+		if _, err := os.Stat(candidate); err == nil {
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("backup not found: %s", arg)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("%s is ambiguous, use the full path: %s", arg, strings.Join(matches, ", "))
+	}
+}
+
+func loadPins() map[string]bool {
+	pins := make(map[string]bool)
+
+	file, err := os.Open(pinFile)
+	if err != nil {
+		return pins
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			pins[filepath.Clean(line)] = true
+		}
+	}
+
+	return pins
+}
+
+func savePins(pins map[string]bool) error {
+	paths := make([]string, 0, len(pins))
+	for path := range pins {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	if err := os.MkdirAll(filepath.Dir(pinFile), 0755); err != nil {
+		return err
+	}
+
+	content := strings.Join(paths, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	return os.WriteFile(pinFile, []byte(content), 0644)
+}
+
+func isPinned(pins map[string]bool, backup BackupInfo) bool {
+	return pins[filepath.Clean(backup.Path)]
+}
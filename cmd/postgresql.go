@@ -5,8 +5,12 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/backend"
 	"github.com/tiyfiy/BackItUp/internal/config"
+	"github.com/tiyfiy/BackItUp/internal/hook"
 	"github.com/tiyfiy/BackItUp/internal/postgresql"
+	"github.com/tiyfiy/BackItUp/internal/preflight"
+	"github.com/tiyfiy/BackItUp/internal/tlsconf"
 )
 
 var postgresqlCmd = &cobra.Command{
@@ -26,6 +30,32 @@ func init() {
 	postgresqlCmd.Flags().String("user", "", "PostgreSQL user")
 	postgresqlCmd.Flags().String("password", "", "PostgreSQL password")
 	postgresqlCmd.Flags().String("database", "", "PostgreSQL database")
+	postgresqlCmd.Flags().String("format", "", "Dump format: plain, custom or directory")
+	postgresqlCmd.Flags().IntP("jobs", "j", 0, "Parallel dump jobs (directory format only)")
+	postgresqlCmd.Flags().Bool("cluster", false, "Back up the whole cluster: roles, tablespaces and every database")
+	postgresqlCmd.Flags().Bool("physical", false, "Take a physical base backup with pg_basebackup (for point-in-time recovery)")
+	postgresqlCmd.Flags().StringSlice("include", nil, "Only back up tables matching these patterns (pg_dump -t)")
+	postgresqlCmd.Flags().StringSlice("exclude", nil, "Skip tables matching these patterns (pg_dump -T)")
+	postgresqlCmd.Flags().StringSlice("exclude-data", nil, "Back up only the definition of tables matching these patterns")
+	postgresqlCmd.Flags().Bool("schema-only", false, "Back up only the schema, no data")
+	postgresqlCmd.Flags().Bool("data-only", false, "Back up only the data, no schema")
+	postgresqlCmd.Flags().String("socket", "", "Directory of the unix socket to connect through instead of host")
+	postgresqlCmd.Flags().String("tls-mode", "", "TLS mode: disable, prefer, require, verify-ca or verify-full")
+	postgresqlCmd.Flags().String("tls-ca", "", "CA certificate (PEM) to check the server certificate against")
+	postgresqlCmd.Flags().String("tls-cert", "", "Client certificate (PEM)")
+	postgresqlCmd.Flags().String("tls-key", "", "Client certificate key (PEM)")
+	postgresqlCmd.Flags().String("tls-server-name", "", "Name to check the server certificate against, when it isn't the host")
+	postgresqlCmd.Flags().String("params", "", `Extra libpq parameters, e.g. "connect_timeout=5"`)
+	postgresqlCmd.Flags().String("ssh-host", "", "Bastion to tunnel through over SSH (host or host:port)")
+	postgresqlCmd.Flags().String("ssh-user", "", "SSH user")
+	postgresqlCmd.Flags().String("ssh-key", "", "SSH private key (the SSH agent is used without one)")
+	postgresqlCmd.Flags().String("ssh-known-hosts", "", "known_hosts file to check host keys against (default ~/.ssh/known_hosts)")
+	postgresqlCmd.Flags().StringSlice("ssh-jump", nil, "Jump hosts to go through before the bastion, as [user@]host[:port]")
+	postgresqlCmd.Flags().String("exec", "", "Where to run pg_dump, psql and pg_restore: local, docker or kubectl")
+	postgresqlCmd.Flags().String("exec-container", "", "Docker container to run the tools in, or the container in the kubectl pod")
+	postgresqlCmd.Flags().String("exec-pod", "", "Pod to run the tools in with kubectl exec, e.g. db-0 or deploy/db")
+	postgresqlCmd.Flags().String("exec-namespace", "", "Namespace of the kubectl pod")
+	addHookFlags(postgresqlCmd)
 }
 
 func backupPostgreSQL(cmd *cobra.Command, args []string) {
@@ -35,6 +65,49 @@ func backupPostgreSQL(cmd *cobra.Command, args []string) {
 	user, _ := cmd.Flags().GetString("user")
 	password, _ := cmd.Flags().GetString("password")
 	database, _ := cmd.Flags().GetString("database")
+	format, _ := cmd.Flags().GetString("format")
+	jobs, _ := cmd.Flags().GetInt("jobs")
+	cluster, _ := cmd.Flags().GetBool("cluster")
+	physical, _ := cmd.Flags().GetBool("physical")
+	include, _ := cmd.Flags().GetStringSlice("include")
+	exclude, _ := cmd.Flags().GetStringSlice("exclude")
+	schemaOnly, _ := cmd.Flags().GetBool("schema-only")
+	dataOnly, _ := cmd.Flags().GetBool("data-only")
+	excludeData, _ := cmd.Flags().GetStringSlice("exclude-data")
+	socket, _ := cmd.Flags().GetString("socket")
+	tlsMode, _ := cmd.Flags().GetString("tls-mode")
+	tlsCA, _ := cmd.Flags().GetString("tls-ca")
+	tlsCert, _ := cmd.Flags().GetString("tls-cert")
+	tlsKey, _ := cmd.Flags().GetString("tls-key")
+	tlsServerName, _ := cmd.Flags().GetString("tls-server-name")
+	params, _ := cmd.Flags().GetString("params")
+	sshHost, _ := cmd.Flags().GetString("ssh-host")
+	sshUser, _ := cmd.Flags().GetString("ssh-user")
+	sshKey, _ := cmd.Flags().GetString("ssh-key")
+	sshKnownHosts, _ := cmd.Flags().GetString("ssh-known-hosts")
+	sshJump, _ := cmd.Flags().GetStringSlice("ssh-jump")
+	execKind, _ := cmd.Flags().GetString("exec")
+	execContainer, _ := cmd.Flags().GetString("exec-container")
+	execPod, _ := cmd.Flags().GetString("exec-pod")
+	execNamespace, _ := cmd.Flags().GetString("exec-namespace")
+	hookTimeout, _ := cmd.Flags().GetInt("hook-timeout")
+	hookPreFailure, _ := cmd.Flags().GetString("hook-pre-failure")
+
+	if format != "" && !postgresql.ValidFormat(format) {
+		log.Fatalf("unknown format %q, expected plain, custom or directory", format)
+	}
+
+	if !tlsconf.ValidMode(tlsMode) {
+		log.Fatalf("unknown TLS mode %q, expected disable, prefer, require, verify-ca or verify-full", tlsMode)
+	}
+
+	if !backend.ValidKind(execKind) {
+		log.Fatalf("unknown exec backend %q, expected local, docker or kubectl", execKind)
+	}
+
+	if !hook.ValidPolicy(hookPreFailure) {
+		log.Fatalf("unknown pre hook failure policy %q, expected abort or continue", hookPreFailure)
+	}
 
 	if configMode {
 		if host != "" {
@@ -57,6 +130,118 @@ func backupPostgreSQL(cmd *cobra.Command, args []string) {
 			config.SetPostgreSQLDatabase(database)
 			fmt.Printf("PostgreSQL database saved to config\n")
 			return
+		} else if format != "" {
+			config.SetPostgreSQLFormat(format)
+			fmt.Printf("PostgreSQL dump format saved to config\n")
+			return
+		} else if jobs != 0 {
+			config.SetPostgreSQLJobs(jobs)
+			fmt.Printf("PostgreSQL dump jobs saved to config\n")
+			return
+		} else if cmd.Flags().Changed("cluster") {
+			config.SetPostgreSQLCluster(cluster)
+			fmt.Printf("PostgreSQL cluster mode saved to config\n")
+			return
+		} else if cmd.Flags().Changed("physical") {
+			config.SetPostgreSQLPhysical(physical)
+			fmt.Printf("PostgreSQL physical mode saved to config\n")
+			return
+		} else if cmd.Flags().Changed("include") {
+			config.SetPostgreSQLInclude(include)
+			fmt.Printf("PostgreSQL include filter saved to config\n")
+			return
+		} else if cmd.Flags().Changed("exclude") {
+			config.SetPostgreSQLExclude(exclude)
+			fmt.Printf("PostgreSQL exclude filter saved to config\n")
+			return
+		} else if cmd.Flags().Changed("exclude-data") {
+			config.SetPostgreSQLExcludeData(excludeData)
+			fmt.Printf("PostgreSQL exclude-data filter saved to config\n")
+			return
+		} else if cmd.Flags().Changed("schema-only") {
+			config.SetPostgreSQLSchemaOnly(schemaOnly)
+			fmt.Printf("PostgreSQL schema-only mode saved to config\n")
+			return
+		} else if cmd.Flags().Changed("data-only") {
+			config.SetPostgreSQLDataOnly(dataOnly)
+			fmt.Printf("PostgreSQL data-only mode saved to config\n")
+			return
+		} else if socket != "" {
+			config.SetPostgreSQLSocket(socket)
+			fmt.Printf("PostgreSQL socket saved to config\n")
+			return
+		} else if tlsMode != "" {
+			config.SetPostgreSQLTLSMode(tlsMode)
+			fmt.Printf("PostgreSQL TLS mode saved to config\n")
+			return
+		} else if tlsCA != "" {
+			config.SetPostgreSQLTLSCA(tlsCA)
+			fmt.Printf("PostgreSQL TLS CA saved to config\n")
+			return
+		} else if tlsCert != "" {
+			config.SetPostgreSQLTLSCert(tlsCert)
+			fmt.Printf("PostgreSQL TLS client certificate saved to config\n")
+			return
+		} else if tlsKey != "" {
+			config.SetPostgreSQLTLSKey(tlsKey)
+			fmt.Printf("PostgreSQL TLS client key saved to config\n")
+			return
+		} else if tlsServerName != "" {
+			config.SetPostgreSQLTLSServerName(tlsServerName)
+			fmt.Printf("PostgreSQL TLS server name saved to config\n")
+			return
+		} else if params != "" {
+			config.SetPostgreSQLParams(params)
+			fmt.Printf("PostgreSQL parameters saved to config\n")
+			return
+		} else if sshHost != "" {
+			config.SetPostgreSQLSSHHost(sshHost)
+			fmt.Printf("PostgreSQL SSH host saved to config\n")
+			return
+		} else if sshUser != "" {
+			config.SetPostgreSQLSSHUser(sshUser)
+			fmt.Printf("PostgreSQL SSH user saved to config\n")
+			return
+		} else if sshKey != "" {
+			config.SetPostgreSQLSSHKey(sshKey)
+			fmt.Printf("PostgreSQL SSH key saved to config\n")
+			return
+		} else if sshKnownHosts != "" {
+			config.SetPostgreSQLSSHKnownHosts(sshKnownHosts)
+			fmt.Printf("PostgreSQL SSH known_hosts file saved to config\n")
+			return
+		} else if cmd.Flags().Changed("ssh-jump") {
+			config.SetPostgreSQLSSHJump(sshJump)
+			fmt.Printf("PostgreSQL SSH jump hosts saved to config\n")
+			return
+		} else if execKind != "" {
+			config.SetPostgreSQLExec(execKind)
+			fmt.Printf("PostgreSQL exec backend saved to config\n")
+			return
+		} else if execContainer != "" {
+			config.SetPostgreSQLExecContainer(execContainer)
+			fmt.Printf("PostgreSQL exec container saved to config\n")
+			return
+		} else if execPod != "" {
+			config.SetPostgreSQLExecPod(execPod)
+			fmt.Printf("PostgreSQL exec pod saved to config\n")
+			return
+		} else if execNamespace != "" {
+			config.SetPostgreSQLExecNamespace(execNamespace)
+			fmt.Printf("PostgreSQL exec namespace saved to config\n")
+			return
+		} else if event, hooks := changedHooks(cmd); event != "" {
+			config.SetPostgreSQLHooks(event, hooks)
+			fmt.Printf("PostgreSQL %s hooks saved to config\n", event)
+			return
+		} else if hookTimeout != 0 {
+			config.SetPostgreSQLHookTimeout(hookTimeout)
+			fmt.Printf("PostgreSQL hook timeout saved to config\n")
+			return
+		} else if hookPreFailure != "" {
+			config.SetPostgreSQLHookPreFailure(hookPreFailure)
+			fmt.Printf("PostgreSQL pre hook failure policy saved to config\n")
+			return
 		} else {
 			log.Fatal("when using config you must provide a value")
 		}
@@ -69,12 +254,120 @@ func backupPostgreSQL(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 
-	db, err := postgresql.Connection(cfg.PostgreSQL.Host, cfg.PostgreSQL.Port, cfg.PostgreSQL.User, cfg.PostgreSQL.Password, cfg.PostgreSQL.Database)
+	if cluster {
+		cfg.PostgreSQL.Cluster = true
+	}
+
+	if physical || cfg.PostgreSQL.Physical {
+		if schemaOnly || dataOnly {
+			log.Fatal("physical backups always copy the whole cluster, --schema-only and --data-only don't apply")
+		}
+		cfg.PostgreSQL.Physical = true
+		err = runBackup("postgresql", "", cfg.PostgreSQL.Hooks, func() (string, error) {
+			return backupPostgreSQLPhysical(cfg.PostgreSQL)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if format != "" {
+		cfg.PostgreSQL.Format = format
+	}
+	if jobs != 0 {
+		cfg.PostgreSQL.Jobs = jobs
+	}
+	if cmd.Flags().Changed("include") {
+		cfg.PostgreSQL.Include = include
+	}
+	if cmd.Flags().Changed("exclude") {
+		cfg.PostgreSQL.Exclude = exclude
+	}
+	if cmd.Flags().Changed("exclude-data") {
+		cfg.PostgreSQL.ExcludeData = excludeData
+	}
+	if cmd.Flags().Changed("schema-only") || cmd.Flags().Changed("data-only") {
+		cfg.PostgreSQL.SchemaOnly = schemaOnly
+		cfg.PostgreSQL.DataOnly = dataOnly
+	}
+
+	err = runBackup("postgresql", cfg.PostgreSQL.Database, cfg.PostgreSQL.Hooks, func() (string, error) {
+		return backupPostgreSQLDatabase(cfg.PostgreSQL)
+	})
 	if err != nil {
-		fmt.Println("error from the connection")
 		log.Fatal(err)
 	}
+}
+
+// backupPostgreSQLDatabase connects to the configured server and dumps the
+// configured database, or every database in cluster mode.
+func backupPostgreSQLDatabase(pgCfg config.PostgreSQLConfig) (string, error) {
+	closeTunnel, err := tunnelPostgreSQL(&pgCfg)
+	if err != nil {
+		return "", err
+	}
+	defer closeTunnel()
+
+	err = runPreflight(func() *preflight.Report { return preflightPostgreSQL(pgCfg, opBackup) })
+	if err != nil {
+		return "", err
+	}
+
+	// Cluster backups only need a database to enumerate the others
+	connectDB := pgCfg.Database
+	if pgCfg.Cluster && connectDB == "" {
+		connectDB = "postgres"
+	}
+
+	db, err := postgresql.Connection(pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, connectDB, postgresqlConn(pgCfg))
+	if err != nil {
+		return "", fmt.Errorf("error from the connection: %w", err)
+	}
 	defer db.Close()
 
-	postgresql.Backup(db, cfg.PostgreSQL.Host, cfg.PostgreSQL.Port, cfg.PostgreSQL.User, cfg.PostgreSQL.Password, cfg.PostgreSQL.Database)
+	opts := postgresqlOptions(pgCfg)
+	if pgCfg.Cluster {
+		return postgresql.BackupCluster(db, pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, opts)
+	}
+	return postgresql.Backup(db, pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, pgCfg.Database, opts)
+}
+
+// backupPostgreSQLPhysical takes a base backup of the configured server.
+func backupPostgreSQLPhysical(pgCfg config.PostgreSQLConfig) (string, error) {
+	closeTunnel, err := tunnelPostgreSQL(&pgCfg)
+	if err != nil {
+		return "", err
+	}
+	defer closeTunnel()
+
+	err = runPreflight(func() *preflight.Report { return preflightPostgreSQL(pgCfg, opBackup) })
+	if err != nil {
+		return "", err
+	}
+
+	return postgresql.BackupPhysical(pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, postgresqlConn(pgCfg))
+}
+
+func postgresqlOptions(pgCfg config.PostgreSQLConfig) postgresql.BackupOptions {
+	return postgresql.BackupOptions{
+		Format:      pgCfg.Format,
+		Kind:        backupKindOf(pgCfg.SchemaOnly, pgCfg.DataOnly),
+		Jobs:        pgCfg.Jobs,
+		Include:     pgCfg.Include,
+		Exclude:     pgCfg.Exclude,
+		ExcludeData: pgCfg.ExcludeData,
+		Conn:        postgresqlConn(pgCfg),
+	}
+}
+
+// postgresqlConn returns the socket, TLS, libpq and exec settings of the
+// config.
+func postgresqlConn(pgCfg config.PostgreSQLConfig) postgresql.ConnOptions {
+	return postgresql.ConnOptions{
+		Socket: pgCfg.Socket,
+		TLS:    pgCfg.TLS,
+		Params: pgCfg.Params,
+		Exec:   execBackend(pgCfg.Exec),
+	}
 }
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/config"
+	"github.com/tiyfiy/BackItUp/internal/hook"
+	"github.com/tiyfiy/BackItUp/internal/preflight"
+	"github.com/tiyfiy/BackItUp/internal/redis"
+)
+
+var redisCmd = &cobra.Command{
+	Use:   "redis",
+	Short: "Back up Redis with RDB snapshots",
+	Long: `Take an RDB snapshot of the configured Redis server and store it gzipped
+in BACKUP/redis.
+
+By default the snapshot is fetched over the replication protocol (PSYNC),
+the way a replica gets its initial copy, so it works against remote servers
+and needs no client tools. --method bgsave runs BGSAVE instead and copies
+the dump file the server wrote, which needs its data directory to be
+readable from here.
+
+Examples:
+  ./BackItUp redis --config --addr cache.internal:6379
+  ./BackItUp redis --config --password secret
+  ./BackItUp redis`,
+	Run: backupRedis,
+}
+
+var redisVerifyCmd = &cobra.Command{
+	Use:   "verify [backup]",
+	Short: "Check Redis backups",
+	Long: `Check the RDB header, end marker and CRC-64 of a Redis backup, and its
+SHA-256 against the manifest, or of every Redis backup when none is given.
+Exits non-zero if a backup fails the check.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  verifyRedis,
+}
+
+func init() {
+	rootCmd.AddCommand(redisCmd)
+	redisCmd.AddCommand(redisVerifyCmd)
+
+	redisCmd.Flags().Bool("config", false, "Configure Redis settings")
+	redisCmd.Flags().String("addr", "", "Redis address (host:port)")
+	redisCmd.Flags().String("user", "", "Redis ACL user (Redis 6 and later)")
+	redisCmd.Flags().String("password", "", "Redis password")
+	redisCmd.Flags().String("method", "", "Take snapshots with sync (over the network) or bgsave (copy the server's dump file)")
+	redisCmd.Flags().String("ssh-host", "", "Bastion to tunnel through over SSH (host or host:port)")
+	redisCmd.Flags().String("ssh-user", "", "SSH user")
+	redisCmd.Flags().String("ssh-key", "", "SSH private key (the SSH agent is used without one)")
+	redisCmd.Flags().String("ssh-known-hosts", "", "known_hosts file to check host keys against (default ~/.ssh/known_hosts)")
+	redisCmd.Flags().StringSlice("ssh-jump", nil, "Jump hosts to go through before the bastion, as [user@]host[:port]")
+	addHookFlags(redisCmd)
+}
+
+func backupRedis(cmd *cobra.Command, args []string) {
+	configMode, _ := cmd.Flags().GetBool("config")
+	addr, _ := cmd.Flags().GetString("addr")
+	user, _ := cmd.Flags().GetString("user")
+	password, _ := cmd.Flags().GetString("password")
+	method, _ := cmd.Flags().GetString("method")
+	sshHost, _ := cmd.Flags().GetString("ssh-host")
+	sshUser, _ := cmd.Flags().GetString("ssh-user")
+	sshKey, _ := cmd.Flags().GetString("ssh-key")
+	sshKnownHosts, _ := cmd.Flags().GetString("ssh-known-hosts")
+	sshJump, _ := cmd.Flags().GetStringSlice("ssh-jump")
+	hookTimeout, _ := cmd.Flags().GetInt("hook-timeout")
+	hookPreFailure, _ := cmd.Flags().GetString("hook-pre-failure")
+
+	if method != "" && !redis.ValidMethod(method) {
+		log.Fatalf("unknown method %q, expected sync or bgsave", method)
+	}
+
+	if !hook.ValidPolicy(hookPreFailure) {
+		log.Fatalf("unknown pre hook failure policy %q, expected abort or continue", hookPreFailure)
+	}
+
+	if configMode {
+		if addr != "" {
+			config.SetRedisAddr(addr)
+			fmt.Printf("Redis address saved to config\n")
+			return
+		} else if user != "" {
+			config.SetRedisUser(user)
+			fmt.Printf("Redis user saved to config\n")
+			return
+		} else if password != "" {
+			config.SetRedisPassword(password)
+			fmt.Printf("Redis password saved to config\n")
+			return
+		} else if method != "" {
+			config.SetRedisMethod(method)
+			fmt.Printf("Redis method saved to config\n")
+			return
+		} else if sshHost != "" {
+			config.SetRedisSSHHost(sshHost)
+			fmt.Printf("Redis SSH host saved to config\n")
+			return
+		} else if sshUser != "" {
+			config.SetRedisSSHUser(sshUser)
+			fmt.Printf("Redis SSH user saved to config\n")
+			return
+		} else if sshKey != "" {
+			config.SetRedisSSHKey(sshKey)
+			fmt.Printf("Redis SSH key saved to config\n")
+			return
+		} else if sshKnownHosts != "" {
+			config.SetRedisSSHKnownHosts(sshKnownHosts)
+			fmt.Printf("Redis SSH known_hosts file saved to config\n")
+			return
+		} else if cmd.Flags().Changed("ssh-jump") {
+			config.SetRedisSSHJump(sshJump)
+			fmt.Printf("Redis SSH jump hosts saved to config\n")
+			return
+		} else if event, hooks := changedHooks(cmd); event != "" {
+			config.SetRedisHooks(event, hooks)
+			fmt.Printf("Redis %s hooks saved to config\n", event)
+			return
+		} else if hookTimeout != 0 {
+			config.SetRedisHookTimeout(hookTimeout)
+			fmt.Printf("Redis hook timeout saved to config\n")
+			return
+		} else if hookPreFailure != "" {
+			config.SetRedisHookPreFailure(hookPreFailure)
+			fmt.Printf("Redis pre hook failure policy saved to config\n")
+			return
+		} else {
+			log.Fatal("when using config you must provide a value")
+		}
+	}
+
+	fmt.Println("Backing up redis...")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if addr != "" {
+		cfg.Redis.Addr = addr
+	}
+	if method != "" {
+		cfg.Redis.Method = method
+	}
+
+	err = runBackup("redis", "", cfg.Redis.Hooks, func() (string, error) {
+		return backupRedisInstance(cfg.Redis)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// backupRedisInstance connects to the configured server and takes a
+// snapshot of it.
+func backupRedisInstance(redisCfg config.RedisConfig) (string, error) {
+	closeTunnel, err := tunnelRedis(&redisCfg)
+	if err != nil {
+		return "", err
+	}
+	defer closeTunnel()
+
+	err = runPreflight(func() *preflight.Report { return preflightRedis(redisCfg, opBackup) })
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := redis.Connection(redisCfg.Addr, redisCfg.User, redisCfg.Password)
+	if err != nil {
+		return "", fmt.Errorf("error from the connection: %w", err)
+	}
+	defer conn.Close()
+
+	return redis.Backup(conn, redisCfg.Method)
+}
+
+func verifyRedis(cmd *cobra.Command, args []string) {
+	var backups []string
+	if len(args) > 0 {
+		backups = args
+	} else {
+		for _, backup := range engineBackups("redis") {
+			backups = append(backups, backup.Path)
+		}
+	}
+	if len(backups) == 0 {
+		fmt.Println("No Redis backups found.")
+		return
+	}
+
+	failed := false
+	for _, backup := range backups {
+		info, err := redis.Verify(backup)
+		if err != nil {
+			fmt.Printf("❌ %s\n   %v\n", backup, err)
+			failed = true
+			continue
+		}
+		checksum := "no checksum"
+		if info.Checksum != 0 {
+			checksum = fmt.Sprintf("CRC-64 %016x", info.Checksum)
+		}
+		fmt.Printf("✅ %s (RDB version %d, %s)\n", backup, info.Version, checksum)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tiyfiy/BackItUp/internal/config"
+	"github.com/tiyfiy/BackItUp/internal/hook"
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+	"github.com/tiyfiy/BackItUp/internal/mongodb"
+	"github.com/tiyfiy/BackItUp/internal/mysql"
+	"github.com/tiyfiy/BackItUp/internal/postgresql"
+	"github.com/tiyfiy/BackItUp/internal/redis"
+	"github.com/tiyfiy/BackItUp/internal/sqlite"
+	"golang.org/x/term"
+)
+
+// isTerminal reports whether f is an interactive terminal rather than a
+// pipe, file or /dev/null.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// The snapshot functions take a full backup of the restore target with the
+// regular backup code, so the snapshot lands in the backup catalog and can
+// be restored with --file if the restore turns out to be a mistake. Filters
+// and schema/data-only settings are ignored; a snapshot is always complete.
+// A target that can't be reached (e.g. a --target-db that doesn't exist
+// yet) has nothing to lose and is skipped.
+
+func snapshotMySQL(mysqlCfg config.MySQLConfig) {
+	fmt.Println("\n📸 Taking a safety snapshot of the target...")
+
+	db, err := mysql.Connection(mysqlCfg.Host, mysqlCfg.Port, mysqlCfg.User, mysqlCfg.Password, mysqlCfg.Database, mysqlConn(mysqlCfg))
+	if err != nil {
+		fmt.Printf("   Skipping snapshot, target not reachable: %v\n", err)
+		return
+	}
+	defer db.Close()
+
+	takeSnapshot("mysql", mysqlCfg.Database, func() (string, error) {
+		return mysql.Backup(db, mysqlCfg.Host, mysqlCfg.Port, mysqlCfg.User, mysqlCfg.Password, mysqlCfg.Database, mysql.BackupOptions{Kind: manifest.KindFull, Native: mysqlCfg.Dumper == mysql.DumperNative, Conn: mysqlConn(mysqlCfg)})
+	})
+}
+
+func snapshotPostgreSQL(pgCfg config.PostgreSQLConfig, cluster bool) {
+	fmt.Println("\n📸 Taking a safety snapshot of the target...")
+
+	database := pgCfg.Database
+	if cluster {
+		database = "postgres"
+	}
+
+	db, err := postgresql.Connection(pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, database, postgresqlConn(pgCfg))
+	if err != nil {
+		fmt.Printf("   Skipping snapshot, target not reachable: %v\n", err)
+		return
+	}
+	defer db.Close()
+
+	opts := postgresql.BackupOptions{Format: pgCfg.Format, Jobs: pgCfg.Jobs, Kind: manifest.KindFull, Conn: postgresqlConn(pgCfg)}
+	takeSnapshot("postgresql", pgCfg.Database, func() (string, error) {
+		if cluster {
+			return postgresql.BackupCluster(db, pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, opts)
+		}
+		return postgresql.Backup(db, pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, pgCfg.Database, opts)
+	})
+}
+
+func snapshotMongoDB(mongoCfg config.MongoDBConfig) {
+	fmt.Println("\n📸 Taking a safety snapshot of the target...")
+
+	client, err := mongodb.Connection(mongoCfg.URI)
+	if err != nil {
+		fmt.Printf("   Skipping snapshot, target not reachable: %v\n", err)
+		return
+	}
+
+	opts := mongodbOptions(mongoCfg)
+	opts.Include, opts.Exclude, opts.Query = nil, nil, ""
+	takeSnapshot("mongodb", "", func() (string, error) {
+		return mongodb.Backup(client, mongoCfg.URI, opts)
+	})
+}
+
+func snapshotSQLite(sqliteCfg config.SQLiteConfig) {
+	fmt.Println("\n📸 Taking a safety snapshot of the target...")
+
+	path := sqliteCfg.Paths[0]
+	if _, err := os.Stat(path); err != nil {
+		fmt.Printf("   Skipping snapshot, target not readable: %v\n", err)
+		return
+	}
+
+	takeSnapshot("sqlite", sqlite.Name(path), func() (string, error) {
+		return sqlite.Backup(path, sqliteCfg.Format)
+	})
+}
+
+func snapshotRedis(redisCfg config.RedisConfig) {
+	fmt.Println("\n📸 Taking a safety snapshot of the target...")
+
+	conn, err := redis.Connection(redisCfg.Addr, redisCfg.User, redisCfg.Password)
+	if err != nil {
+		fmt.Printf("   Skipping snapshot, target not reachable: %v\n", err)
+		return
+	}
+	defer conn.Close()
+
+	takeSnapshot("redis", "", func() (string, error) {
+		return redis.Backup(conn, redisCfg.Method)
+	})
+}
+
+// takeSnapshot runs the snapshot backup and tells the user how to roll back
+// to it. It is part of the restore, after the pre-restore hooks, so the
+// target's backup hooks don't run for it. A failed snapshot cancels the
+// restore.
+func takeSnapshot(engine, database string, backup func() (string, error)) {
+	var path string
+	err := runBackup(engine, database, hook.Config{}, func() (string, error) {
+		var err error
+		path, err = backup()
+		return path, err
+	})
+	if err != nil {
+		restoreFailed(fmt.Errorf("safety snapshot failed, restore cancelled: %w", err))
+	}
+
+	fmt.Printf("   Snapshot saved: %s\n", path)
+	fmt.Printf("   To undo this restore: ./BackItUp restore %s --file %s\n", engine, filepath.ToSlash(path))
+}
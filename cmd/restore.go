@@ -1,30 +1,123 @@
 package cmd
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/backend"
 	"github.com/tiyfiy/BackItUp/internal/config"
+	"github.com/tiyfiy/BackItUp/internal/hook"
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+	"github.com/tiyfiy/BackItUp/internal/mongodb"
+	"github.com/tiyfiy/BackItUp/internal/mysql"
+	"github.com/tiyfiy/BackItUp/internal/postgresql"
+	"github.com/tiyfiy/BackItUp/internal/preflight"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+	"github.com/tiyfiy/BackItUp/internal/redis"
+	"github.com/tiyfiy/BackItUp/internal/sqlite"
+	"github.com/tiyfiy/BackItUp/internal/tool"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 var (
-	restoreLatest bool
-	restoreFile   string
+	restoreLatest    bool
+	restoreFile      string
+	restoreJobs      int
+	restoreClean     bool
+	restoreTables    []string
+	restoreSchemas   []string
+	restorePITR      string
+	restoreDataDir   string
+	restoreWALDir    string
+	restoreNsInclude []string
+	restoreNsExclude []string
+	restoreKind      string
+
+	restoreTargetDB      string
+	restoreTargetHost    string
+	restoreTargetProfile string
+	restoreNsFrom        []string
+	restoreNsTo          []string
+
+	restoreCollections []string
+	restoreIntoSchema  string
+
+	restoreTargetPath string
+
+	restoreYes           bool
+	restoreConfirmTarget string
+	restoreSnapshot      bool
 )
 
 var restoreCmd = &cobra.Command{
-	Use:   "restore [mongodb|mysql|postgresql]",
+	Use:   "restore [mongodb|mysql|postgresql|sqlite|redis]",
 	Short: "Restore a database from backup",
 	Long: `Restore a database from a previously created backup.
 
 By default, shows available backups and prompts for selection.
 Use --latest to automatically restore the most recent backup.
-Use --file to specify a specific backup file/directory.`,
+Use --file to specify a specific backup file/directory.
+
+Restore somewhere else than the configured database, e.g. to clone
+production into staging or inspect an old backup next to live data:
+  ./BackItUp restore mysql --latest --target-db shop_copy
+  ./BackItUp restore postgresql --latest --target-profile staging
+  ./BackItUp restore mongodb --latest --target-host 10.0.0.5:27017 --target-db shop_copy
+
+PostgreSQL custom (.dump) and directory format backups are restored with
+pg_restore, which supports parallel jobs and restoring selected tables or
+schemas:
+  ./BackItUp restore postgresql --latest -j 8
+  ./BackItUp restore postgresql --latest --table orders --table customers
+  ./BackItUp restore postgresql --latest --schema billing
+
+Single tables can be restored from any backup; plain SQL dumps are filtered
+while they stream. --into-schema puts them next to the live data so they
+can be compared before swapping:
+  ./BackItUp restore mysql --latest --table orders,order_items
+  ./BackItUp restore postgresql --latest --table orders --into-schema restored
+  ./BackItUp restore mongodb --latest --collection shop.orders --into-schema shop_restored
+
+MySQL point-in-time restores load the newest dump taken before --pitr and
+replay the binlogs collected by 'mysql binlog-follow' up to that time:
+  ./BackItUp restore mysql --pitr "2026-10-17 14:32"
+
+MongoDB point-in-time restores work the same way with the oplog captured by
+'mongodb oplog-follow':
+  ./BackItUp restore mongodb --pitr "2026-10-17 14:32"
+
+Unattended restores (cron, CI) never prompt. They need --yes plus
+--confirm-target naming the database that gets overwritten, and a backup
+chosen with --latest, --file or --pitr. Add --snapshot to back up the
+target first:
+  ./BackItUp restore mysql --latest --yes --confirm-target shop --snapshot
+
+SQLite backups are checked with PRAGMA integrity_check and written back to
+the file they were taken from, or to --target-path:
+  ./BackItUp restore sqlite --latest --target-path /tmp/app-restored.db
+
+Redis backups are verified and staged as the RDB file the server loads on
+its next start. Stop the server with SHUTDOWN NOSAVE first, stage the file
+and start it again. Servers on other machines need --target-path and the
+file copied over:
+  ./BackItUp restore redis --latest --target-path /var/lib/redis/dump.rdb
+
+Physical PostgreSQL backups are restored into a new data directory, which
+is set up to replay archived WAL up to --pitr (or to the end of the archive):
+  ./BackItUp restore postgresql --pitr "2026-10-17 14:32" --data-dir /var/lib/postgresql/restore`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		dbType := args[0]
@@ -35,11 +128,35 @@ Use --file to specify a specific backup file/directory.`,
 func init() {
 	rootCmd.AddCommand(restoreCmd)
 	restoreCmd.Flags().BoolVarP(&restoreLatest, "latest", "l", false, "Restore the latest backup")
+	restoreCmd.Flags().StringVar(&restoreKind, "kind", manifest.KindFull, "Backup kind --latest picks: full, schema or data")
 	restoreCmd.Flags().StringVarP(&restoreFile, "file", "f", "", "Restore from specific backup file/directory")
+	restoreCmd.Flags().IntVarP(&restoreJobs, "jobs", "j", 1, "Parallel restore jobs (PostgreSQL custom/directory format)")
+	restoreCmd.Flags().BoolVar(&restoreClean, "clean", true, "Drop objects before recreating them (PostgreSQL custom/directory format)")
+	restoreCmd.Flags().StringSliceVar(&restoreTables, "table", nil, "Restore only these tables, e.g. orders,customers (MySQL, PostgreSQL)")
+	restoreCmd.Flags().StringSliceVar(&restoreCollections, "collection", nil, "Restore only these collections, e.g. orders or shop.orders (MongoDB)")
+	restoreCmd.Flags().StringVar(&restoreIntoSchema, "into-schema", "", "Restore into this side schema (PostgreSQL) or database (MySQL, MongoDB) to compare before swapping")
+	restoreCmd.Flags().StringSliceVar(&restoreSchemas, "schema", nil, "Restore only these schemas (PostgreSQL custom/directory format)")
+	restoreCmd.Flags().StringVar(&restorePITR, "pitr", "", "Recover to this point in time, e.g. \"2026-10-17 14:32\" (PostgreSQL physical backups, MySQL binlogs, MongoDB oplog)")
+	restoreCmd.Flags().StringVar(&restoreDataDir, "data-dir", "", "Data directory to lay out a physical backup in (PostgreSQL)")
+	restoreCmd.Flags().StringVar(&restoreWALDir, "wal-dir", postgresql.WALDir, "Directory wal-archive stores WAL segments in, as its --dir (PostgreSQL physical backups)")
+	restoreCmd.Flags().StringSliceVar(&restoreNsInclude, "nsInclude", nil, "Restore only matching namespaces, e.g. shop.* (MongoDB)")
+	restoreCmd.Flags().StringSliceVar(&restoreNsExclude, "nsExclude", nil, "Skip matching namespaces, e.g. shop.logs (MongoDB)")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "Don't prompt; requires --confirm-target")
+	restoreCmd.Flags().StringVar(&restoreConfirmTarget, "confirm-target", "", "Name of the database being overwritten (or the server, for multi-database restores), as a safety check")
+	restoreCmd.Flags().BoolVar(&restoreSnapshot, "snapshot", false, "Back up the target before overwriting it")
+	restoreCmd.Flags().StringVar(&restoreTargetDB, "target-db", "", "Restore into this database instead of the configured one")
+	restoreCmd.Flags().StringVar(&restoreTargetHost, "target-host", "", "Restore to this server (host or host:port) instead of the configured one")
+	restoreCmd.Flags().StringVar(&restoreTargetProfile, "target-profile", "", "Restore using the connection settings of this config.yaml profile")
+	restoreCmd.Flags().StringVar(&restoreTargetPath, "target-path", "", "Restore to this database file instead of the one the backup was taken from (SQLite), or stage the RDB file here (Redis)")
+	restoreCmd.Flags().StringSliceVar(&restoreNsFrom, "nsFrom", nil, "Rename namespaces matching these patterns, e.g. shop.$coll$ (MongoDB, with --nsTo)")
+	restoreCmd.Flags().StringSliceVar(&restoreNsTo, "nsTo", nil, "New names for --nsFrom namespaces, e.g. shop_copy.$coll$ (MongoDB)")
 }
 
 func restoreDatabase(dbType string) {
-	cfg, err := config.Load()
+	if restoreYes && restoreConfirmTarget == "" {
+		log.Fatal("--yes requires --confirm-target <database> naming the database being overwritten")
+	}
+	cfg, err := restoreTargetConfig()
 	if err != nil {
 		log.Fatal("Error loading configuration:", err)
 	}
@@ -49,16 +166,23 @@ func restoreDatabase(dbType string) {
 
 	switch dbType {
 	case "mongodb":
-		backups = listDirBackups(filepath.Join("BACKUP", "mongo"))
+		backups = engineBackups("mongo")
 		if len(backups) == 0 {
 			fmt.Println("No MongoDB backups found.")
 			return
 		}
 
-		if restoreFile != "" {
+		if restorePITR != "" && (restoreTargetDB != "" || restoreIntoSchema != "" || len(restoreNsFrom) > 0) {
+			log.Fatal("--pitr can't restore into another database: mongorestore --oplogReplay applies the oplog to the namespaces it was recorded in")
+		}
+
+		var pitrBase *manifest.Manifest
+		if restorePITR != "" {
+			backupPath, pitrBase = selectMongoDBPITRBase(backups)
+		} else if restoreFile != "" {
 			backupPath = restoreFile
 		} else if restoreLatest {
-			backupPath = backups[0].Path
+			backupPath = latestBackupOfKind(backups, restoreKind)
 		} else {
 			backupPath = selectBackup(backups, "MongoDB")
 		}
@@ -68,24 +192,50 @@ func restoreDatabase(dbType string) {
 			return
 		}
 
-		if !confirmRestore("MongoDB") {
+		warnPartialBackup(backupPath)
+
+		if err := checkMongoDBExec(cfg.MongoDB, backupPath); err != nil {
+			log.Fatal(err)
+		}
+
+		nsFrom, nsTo := mongoNamespaceRemap(backupPath)
+
+		// The confirmation names the server itself, not the tunnel
+		tunneled := cfg.MongoDB
+		defer mustTunnel(tunnelMongoDB(&tunneled))()
+
+		restorePreflight(func() *preflight.Report { return preflightMongoDB(tunneled, restoreOperations()...) })
+
+		if !confirmRestore("MongoDB", mongoServer(cfg.MongoDB.URI), mongoRestoreTargets(backupPath, nsTo)) {
 			fmt.Println("Restore cancelled.")
 			return
 		}
 
-		restoreMongoDB(cfg.MongoDB.URI, backupPath)
+		startRestoreRun("mongodb", "", backupPath, cfg.MongoDB.Hooks)
+		if restoreSnapshot {
+			snapshotMongoDB(tunneled)
+		}
+		restoreMongoDB(tunneled, backupPath, nsFrom, nsTo)
+
+		if pitrBase != nil {
+			replayMongoDBOplog(tunneled.URI, pitrBase)
+		}
+		finishRestoreRun()
 
 	case "mysql":
-		backups = listFileBackups(filepath.Join("BACKUP", "mysql"), ".sql")
+		backups = engineBackups("mysql")
 		if len(backups) == 0 {
 			fmt.Println("No MySQL backups found.")
 			return
 		}
 
-		if restoreFile != "" {
+		var pitrBase *manifest.Manifest
+		if restorePITR != "" {
+			backupPath, pitrBase = selectMySQLPITRBase(backups)
+		} else if restoreFile != "" {
 			backupPath = restoreFile
 		} else if restoreLatest {
-			backupPath = backups[0].Path
+			backupPath = latestBackupOfKind(backups, restoreKind)
 		} else {
 			backupPath = selectBackup(backups, "MySQL")
 		}
@@ -95,24 +245,51 @@ func restoreDatabase(dbType string) {
 			return
 		}
 
-		if !confirmRestore("MySQL") {
+		warnPartialBackup(backupPath)
+
+		if restoreIntoSchema != "" {
+			cfg.MySQL.Database = restoreIntoSchema
+		}
+
+		server := cfg.MySQL.Host + ":" + cfg.MySQL.Port
+		defer mustTunnel(tunnelMySQL(&cfg.MySQL))()
+
+		restorePreflight(func() *preflight.Report { return preflightMySQL(cfg.MySQL, restoreOperations()...) })
+
+		if !confirmRestore("MySQL", server, []string{cfg.MySQL.Database}) {
 			fmt.Println("Restore cancelled.")
 			return
 		}
 
-		restoreMySQL(cfg.MySQL, backupPath)
+		startRestoreRun("mysql", cfg.MySQL.Database, backupPath, cfg.MySQL.Hooks)
+		if restoreSnapshot {
+			snapshotMySQL(cfg.MySQL)
+		}
+
+		flavor := mysqlRestoreFlavor(cfg.MySQL, backupPath)
+		restoreMySQL(cfg.MySQL, backupPath, flavor)
+
+		if pitrBase != nil {
+			replayMySQLBinlogs(cfg.MySQL, pitrBase, flavor)
+		}
+		finishRestoreRun()
 
 	case "postgresql":
-		backups = listFileBackups(filepath.Join("BACKUP", "postgresql"), ".sql")
+		backups = engineBackups("postgresql")
 		if len(backups) == 0 {
 			fmt.Println("No PostgreSQL backups found.")
 			return
 		}
 
+		if restorePITR != "" || restoreDataDir != "" {
+			restorePostgreSQLPhysical(backups, cfg.PostgreSQL.Hooks)
+			return
+		}
+
 		if restoreFile != "" {
 			backupPath = restoreFile
 		} else if restoreLatest {
-			backupPath = backups[0].Path
+			backupPath = latestBackupOfKind(backups, restoreKind)
 		} else {
 			backupPath = selectBackup(backups, "PostgreSQL")
 		}
@@ -122,21 +299,169 @@ func restoreDatabase(dbType string) {
 			return
 		}
 
-		if !confirmRestore("PostgreSQL") {
+		warnPartialBackup(backupPath)
+
+		targetDBs := []string{cfg.PostgreSQL.Database}
+		if isClusterBackup(backupPath) {
+			if restoreTargetDB != "" {
+				log.Fatal("--target-db doesn't apply to cluster backups, they restore every database under its own name")
+			}
+			targetDBs = nil
+		}
+
+		server := cfg.PostgreSQL.Host + ":" + cfg.PostgreSQL.Port
+		defer mustTunnel(tunnelPostgreSQL(&cfg.PostgreSQL))()
+
+		restorePreflight(func() *preflight.Report { return preflightPostgreSQL(cfg.PostgreSQL, restoreOperations()...) })
+
+		if !confirmRestore("PostgreSQL", server, targetDBs) {
 			fmt.Println("Restore cancelled.")
 			return
 		}
 
+		startRestoreRun("postgresql", cfg.PostgreSQL.Database, backupPath, cfg.PostgreSQL.Hooks)
+		if restoreSnapshot {
+			snapshotPostgreSQL(cfg.PostgreSQL, targetDBs == nil)
+		}
 		restorePostgreSQL(cfg.PostgreSQL, backupPath)
+		finishRestoreRun()
+
+	case "sqlite":
+		backups = engineBackups("sqlite")
+		if len(backups) == 0 {
+			fmt.Println("No SQLite backups found.")
+			return
+		}
+
+		if restoreFile != "" {
+			backupPath = restoreFile
+		} else if restoreLatest {
+			backupPath = latestBackupOfKind(backups, restoreKind)
+		} else {
+			backupPath = selectBackup(backups, "SQLite")
+		}
+
+		if backupPath == "" {
+			fmt.Println("No backup selected. Restore cancelled.")
+			return
+		}
+
+		target := restoreTargetPath
+		if target == "" {
+			m, err := manifest.Read(backupPath)
+			if err != nil || m.Source == "" {
+				log.Fatal("The backup doesn't record the file it was taken from, choose one with --target-path")
+			}
+			target = m.Source
+		}
+
+		sqliteCfg := config.SQLiteConfig{Paths: []string{target}, Format: cfg.SQLite.Format, Hooks: cfg.SQLite.Hooks}
+		restorePreflight(func() *preflight.Report { return preflightSQLite(sqliteCfg, restoreOperations()...) })
+
+		if !confirmRestore("SQLite", "local", []string{target}) {
+			fmt.Println("Restore cancelled.")
+			return
+		}
+
+		startRestoreRun("sqlite", sqlite.Name(target), backupPath, cfg.SQLite.Hooks)
+		if restoreSnapshot {
+			snapshotSQLite(sqliteCfg)
+		}
+		restoreSQLite(backupPath, target)
+		finishRestoreRun()
+
+	case "redis":
+		backups = engineBackups("redis")
+		if len(backups) == 0 {
+			fmt.Println("No Redis backups found.")
+			return
+		}
+
+		if restoreFile != "" {
+			backupPath = restoreFile
+		} else if restoreLatest {
+			backupPath = latestBackupOfKind(backups, restoreKind)
+		} else {
+			backupPath = selectBackup(backups, "Redis")
+		}
+
+		if backupPath == "" {
+			fmt.Println("No backup selected. Restore cancelled.")
+			return
+		}
+
+		// The staging hints name the server itself, not the tunnel
+		tunneled := cfg.Redis
+		defer mustTunnel(tunnelRedis(&tunneled))()
+
+		// The dir and dbfilename the server reports are on its own machine
+		local := !cfg.Redis.SSH.Enabled() && isLoopbackAddr(cfg.Redis.Addr)
+		if !local && restoreTargetPath == "" {
+			log.Fatalf("%s isn't on this machine, so its RDB file can't be written from here. Stage the backup with --target-path and copy it to the server", cfg.Redis.Addr)
+		}
+
+		target, appendOnly := restoreTargetPath, false
+		running := redisRunning(tunneled.Addr)
+		if running {
+			conn, err := redis.Connection(tunneled.Addr, tunneled.User, tunneled.Password)
+			if err != nil && target == "" {
+				log.Fatalf("Can't ask %s where it loads its RDB file from (%v), choose one with --target-path", cfg.Redis.Addr, err)
+			}
+			if err == nil {
+				var dumpPath string
+				dumpPath, appendOnly, err = redis.DumpPath(conn)
+				conn.Close()
+				if err != nil && target == "" {
+					log.Fatal(err)
+				}
+				if target == "" {
+					target = dumpPath
+				}
+			}
+		}
+
+		// A running server writes over the staged file when it next saves
+		// or shuts down
+		if local && running {
+			host, port := redisHostPort(cfg.Redis.Addr)
+			fmt.Printf("Redis at %s is running and would overwrite %s the next time it saves.\n", cfg.Redis.Addr, target)
+			fmt.Println("Back it up first if you need to (./BackItUp redis), then stop it without saving:")
+			fmt.Printf("   redis-cli -h %s -p %s SHUTDOWN NOSAVE\n", host, port)
+			if appendOnly {
+				fmt.Println("It has appendonly enabled and would load its AOF instead: set appendonly no in redis.conf (re-enable it once the data is back)")
+			}
+			log.Fatalf("Run the restore again once it is stopped: ./BackItUp restore redis --target-path %s", target)
+		}
+		if target == "" {
+			log.Fatalf("%s isn't running, so it can't say where it loads its RDB file from. Choose it with --target-path", cfg.Redis.Addr)
+		}
+
+		restorePreflight(func() *preflight.Report { return preflightRedis(tunneled, restoreOperations()...) })
+
+		if !confirmRestore("Redis", cfg.Redis.Addr, []string{target}) {
+			fmt.Println("Restore cancelled.")
+			return
+		}
+
+		startRestoreRun("redis", "", backupPath, cfg.Redis.Hooks)
+		if restoreSnapshot {
+			snapshotRedis(tunneled)
+		}
+		restoreRedis(cfg.Redis, backupPath, target, local, appendOnly)
+		finishRestoreRun()
 
 	default:
 		fmt.Printf("Unknown database type: %s\n", dbType)
-		fmt.Println("Supported types: mongodb, mysql, postgresql")
+		fmt.Println("Supported types: mongodb, mysql, postgresql, sqlite, redis")
 		return
 	}
 }
 
 func selectBackup(backups []BackupInfo, dbType string) string {
+	if restoreYes || !isTerminal(os.Stdin) {
+		log.Fatal("Without a terminal, choose the backup up front with --latest, --file or --pitr")
+	}
+
 	fmt.Printf("\n📦 Available %s Backups:\n", dbType)
 	fmt.Println("══════════════════════════════════════════════════════════════")
 
@@ -161,11 +486,102 @@ func selectBackup(backups []BackupInfo, dbType string) string {
 	return backups[selection-1].Path
 }
 
-func confirmRestore(dbType string) bool {
+// latestBackupOfKind returns the newest backup of the given kind, so that
+// --latest doesn't pick a schema-only snapshot by accident.
+func latestBackupOfKind(backups []BackupInfo, kind string) string {
+	for _, backup := range backups {
+		if backupKind(backup) == kind {
+			return backup.Path
+		}
+	}
+	return ""
+}
+
+// warnPartialBackup tells the user when a backup only covers part of the
+// database, so a restore doesn't bring back everything.
+func warnPartialBackup(backupPath string) {
+	m, err := manifest.Read(backupPath)
+	if err != nil || !m.Partial() {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("⚠️  This is a partial backup:")
+	switch m.Kind {
+	case manifest.KindSchema:
+		fmt.Println("   Schema only - no data will be restored")
+	case manifest.KindData:
+		fmt.Println("   Data only - the tables must already exist")
+	}
+	if m.Filters == nil {
+		return
+	}
+	if len(m.Filters.Include) > 0 {
+		fmt.Printf("   Only includes: %s\n", strings.Join(m.Filters.Include, ", "))
+	}
+	if len(m.Filters.Exclude) > 0 {
+		fmt.Printf("   Excludes:      %s\n", strings.Join(m.Filters.Exclude, ", "))
+	}
+	if len(m.Filters.ExcludeData) > 0 {
+		fmt.Printf("   Schema only:   %s\n", strings.Join(m.Filters.ExcludeData, ", "))
+	}
+}
+
+// restoreOperations returns what the restore will run: the restore itself,
+// and a backup when a safety snapshot is taken first.
+func restoreOperations() []string {
+	if restoreSnapshot {
+		return []string{opRestore, opSnapshot}
+	}
+	return []string{opRestore}
+}
+
+// restorePreflight stops the restore before anything is touched if a
+// preflight check fails.
+func restorePreflight(checks func() *preflight.Report) {
+	if err := runPreflight(checks); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// confirmRestore shows what a restore is about to overwrite and asks for
+// confirmation. With --yes it doesn't prompt, but --confirm-target must name
+// the target database (or the server, for restores that span several
+// databases).
+func confirmRestore(dbType, server string, databases []string) bool {
+	target := strings.Join(databases, ", ")
+	if len(databases) == 0 {
+		target = "all databases in the backup"
+	}
+
 	fmt.Println()
 	fmt.Printf("⚠️  WARNING: This will restore the %s database.\n", dbType)
+	fmt.Printf("   Server:   %s\n", server)
+	fmt.Printf("   Database: %s\n", target)
+	if restoreIntoSchema != "" && dbType == "PostgreSQL" {
+		fmt.Printf("   Schema:   %s\n", restoreIntoSchema)
+	}
 	fmt.Println("   This operation may overwrite existing data!")
 	fmt.Println()
+
+	if restoreConfirmTarget != "" {
+		expected := server
+		if len(databases) == 1 {
+			expected = databases[0]
+		}
+		if restoreConfirmTarget != expected {
+			log.Fatalf("--confirm-target %q doesn't match the restore target %q", restoreConfirmTarget, expected)
+		}
+	}
+
+	if restoreYes {
+		fmt.Println("Confirmed with --yes and --confirm-target.")
+		return true
+	}
+	if !isTerminal(os.Stdin) {
+		log.Fatal("Refusing to restore without a terminal to confirm on. For unattended restores pass --yes --confirm-target <database>")
+	}
+
 	fmt.Print("Are you sure you want to continue? (yes/no): ")
 
 	var response string
@@ -175,29 +591,423 @@ func confirmRestore(dbType string) bool {
 	return response == "yes" || response == "y"
 }
 
-func restoreMongoDB(uri, backupPath string) {
+// restoreTargetConfig returns the connection settings restore writes to:
+// the --target-profile (or the main config), with --target-host and
+// --target-db applied on top.
+func restoreTargetConfig() (*config.Config, error) {
+	var cfg *config.Config
+	var err error
+	if restoreTargetProfile != "" {
+		cfg, err = config.LoadProfile(restoreTargetProfile)
+	} else {
+		cfg, err = config.Load()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if restoreTargetHost != "" {
+		host, port := restoreTargetHost, ""
+		if h, p, err := net.SplitHostPort(restoreTargetHost); err == nil {
+			host, port = h, p
+		}
+
+		cfg.MySQL.Host = host
+		cfg.PostgreSQL.Host = host
+		if port != "" {
+			cfg.MySQL.Port = port
+			cfg.PostgreSQL.Port = port
+		}
+
+		redisAddr := restoreTargetHost
+		if _, redisPort, err := net.SplitHostPort(cfg.Redis.Addr); err == nil && port == "" {
+			redisAddr = net.JoinHostPort(host, redisPort)
+		}
+		cfg.Redis.Addr = redisAddr
+
+		uri, err := url.Parse(cfg.MongoDB.URI)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MongoDB URI: %w", err)
+		}
+		uri.Host = restoreTargetHost
+		cfg.MongoDB.URI = uri.String()
+	}
+
+	if restoreTargetDB != "" {
+		cfg.MySQL.Database = restoreTargetDB
+		cfg.PostgreSQL.Database = restoreTargetDB
+	}
+
+	return cfg, nil
+}
+
+// mongoServer returns the host part of a MongoDB URI without credentials.
+func mongoServer(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return maskURI(uri)
+	}
+	return parsed.Host
+}
+
+// mongoNamespaceRemap returns the --nsFrom/--nsTo pairs for mongorestore.
+// --target-db renames the backup's only database; for backups with several
+// databases, use --nsFrom/--nsTo directly.
+func mongoNamespaceRemap(backupPath string) ([]string, []string) {
+	if len(restoreNsFrom) != len(restoreNsTo) {
+		log.Fatal("--nsFrom and --nsTo must be given the same number of times")
+	}
+	if restoreIntoSchema != "" {
+		if restoreTargetDB != "" || len(restoreNsFrom) > 0 {
+			log.Fatal("Use only one of --into-schema, --target-db or --nsFrom/--nsTo")
+		}
+		return []string{"$db$.$coll$"}, []string{restoreIntoSchema + ".$coll$"}
+	}
+	if restoreTargetDB == "" {
+		return restoreNsFrom, restoreNsTo
+	}
+	if len(restoreNsFrom) > 0 {
+		log.Fatal("Use either --target-db or --nsFrom/--nsTo, not both")
+	}
+
+	databases := mongoBackupDatabases(backupPath)
+	if len(databases) != 1 {
+		log.Fatal("--target-db needs a backup with exactly one database. Use --nsFrom 'db.$coll$' --nsTo 'newdb.$coll$' instead")
+	}
+
+	return []string{databases[0] + ".$coll$"}, []string{restoreTargetDB + ".$coll$"}
+}
+
+// mongoBackupDatabases lists the databases in a directory backup. Archive
+// backups can't be inspected without reading them, so they return nil.
+func mongoBackupDatabases(backupPath string) []string {
+	entries, err := os.ReadDir(backupPath)
+	if err != nil {
+		return nil
+	}
+
+	var databases []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != "admin" {
+			databases = append(databases, entry.Name())
+		}
+	}
+	return databases
+}
+
+// mongoRestoreTargets returns the databases a MongoDB restore overwrites, or
+// nil when they can't be told in advance (archives, wildcard renames).
+func mongoRestoreTargets(backupPath string, nsTo []string) []string {
+	if len(nsTo) == 0 {
+		return mongoBackupDatabases(backupPath)
+	}
+
+	var databases []string
+	seen := make(map[string]bool)
+	for _, ns := range nsTo {
+		database, _, _ := strings.Cut(ns, ".")
+		if strings.Contains(database, "$") {
+			return nil
+		}
+		if !seen[database] {
+			seen[database] = true
+			databases = append(databases, database)
+		}
+	}
+	return databases
+}
+
+func restoreMongoDB(mongoCfg config.MongoDBConfig, backupPath string, nsFrom, nsTo []string) {
 	fmt.Println("\n🔄 Restoring MongoDB from backup...")
 	fmt.Printf("   Source: %s\n", backupPath)
 	fmt.Println()
 
-	// Check if mongorestore is available
-	if _, err := exec.LookPath("mongorestore"); err != nil {
-		log.Fatal("mongorestore command not found. Please install MongoDB tools.")
+	if mongoCfg.Dumper == mongodb.DumperNative {
+		restoreMongoDBNative(mongoCfg.URI, backupPath, nsFrom, nsTo)
+		return
 	}
+	tools := execBackend(mongoCfg.Exec)
+	requireTool(tools, "mongorestore", "mongorestore command not found. Please install MongoDB tools.")
+
+	args := []string{"--drop"}
 
-	cmd := exec.Command("mongorestore", "--uri", uri, "--drop", backupPath)
+	if strings.HasSuffix(backupPath, ".gz") || hasGzipFiles(backupPath) {
+		args = append(args, "--gzip")
+	}
+	if m, err := manifest.Read(backupPath); err == nil && m.Oplog {
+		args = append(args, "--oplogReplay")
+	}
+	for _, ns := range restoreNsInclude {
+		args = append(args, "--nsInclude", ns)
+	}
+	for _, collection := range restoreCollections {
+		// A bare collection name matches it in every database
+		if !strings.Contains(collection, ".") {
+			collection = "*." + collection
+		}
+		args = append(args, "--nsInclude", collection)
+	}
+	for _, ns := range restoreNsExclude {
+		args = append(args, "--nsExclude", ns)
+	}
+	for i := range nsFrom {
+		args = append(args, "--nsFrom", nsFrom[i], "--nsTo", nsTo[i])
+	}
+
+	// Archives are streamed in on stdin so progress can be shown; mongorestore
+	// decompresses them itself with --gzip
+	archive := false
+	if info, err := os.Stat(backupPath); err == nil && !info.IsDir() {
+		archive = true
+		args = append(args, "--archive")
+	} else {
+		args = append(args, backupPath)
+	}
+
+	cmd := mongodb.ToolCommand(tools, mongoCfg.URI, "mongorestore", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	err := cmd.Run()
+	finish := func() {}
+	if archive {
+		file, err := os.Open(backupPath)
+		if err != nil {
+			restoreFailed(fmt.Errorf("failed to read backup file: %w", err))
+		}
+		info, _ := file.Stat()
+		meter := progress.Start("Restored", info.Size())
+		backend.SetStdin(cmd, meter.Reader(file))
+		finish = func() {
+			meter.Finish()
+			file.Close()
+		}
+	}
+
+	err := tool.Run(cmd)
+	finish()
 	if err != nil {
-		log.Fatal("Restore failed:", err)
+		restoreFailed(err)
 	}
 
 	fmt.Println("\n✅ MongoDB restore completed successfully!")
 }
 
-func restoreMySQL(mysqlCfg config.MySQLConfig, backupPath string) {
+// checkMongoDBExec refuses restores that mongorestore can't do in a
+// container: the backup has to be an archive, streamed in on stdin, and
+// the oplog for --pitr is replayed from a local directory.
+func checkMongoDBExec(mongoCfg config.MongoDBConfig, backupPath string) error {
+	if mongoCfg.Exec.Local() || mongoCfg.Dumper == mongodb.DumperNative {
+		return nil
+	}
+	tools := execBackend(mongoCfg.Exec)
+	if restorePITR != "" {
+		return fmt.Errorf("--pitr replays the oplog from a local directory, it can't run with mongorestore in %s", tools)
+	}
+	if info, err := os.Stat(backupPath); err == nil && info.IsDir() {
+		return fmt.Errorf("directory backups can't be streamed into mongorestore in %s, restore them with local tools", tools)
+	}
+	return nil
+}
+
+// restoreMongoDBNative restores a directory backup through the driver,
+// without mongorestore.
+func restoreMongoDBNative(uri, backupPath string, nsFrom, nsTo []string) {
+	client, err := mongodb.Connection(uri)
+	if err != nil {
+		restoreFailed(fmt.Errorf("error from the connection: %w", err))
+	}
+	defer client.Disconnect(context.Background())
+
+	include := append([]string{}, restoreNsInclude...)
+	for _, collection := range restoreCollections {
+		// A bare collection name matches it in every database
+		if !strings.Contains(collection, ".") {
+			collection = "*." + collection
+		}
+		include = append(include, collection)
+	}
+
+	err = mongodb.RestoreNative(client, backupPath, mongodb.RestoreOptions{
+		Include: include,
+		Exclude: restoreNsExclude,
+		From:    nsFrom,
+		To:      nsTo,
+	})
+	if err != nil {
+		restoreFailed(err)
+	}
+
+	fmt.Println("\n✅ MongoDB restore completed successfully!")
+}
+
+// selectMySQLPITRBase picks the newest dump taken before --pitr that recorded
+// binlog coordinates.
+func selectMySQLPITRBase(backups []BackupInfo) (string, *manifest.Manifest) {
+	target, err := parsePITR(restorePITR)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, backup := range backups {
+		if restoreFile != "" && filepath.Clean(restoreFile) != backup.Path {
+			continue
+		}
+
+		m, err := manifest.Read(backup.Path)
+		if err != nil || m.Binlog == nil || m.Partial() || !m.CreatedAt.Before(target) {
+			continue
+		}
+		return backup.Path, m
+	}
+
+	log.Fatalf("No MySQL backup with binlog coordinates was taken before %s", restorePITR)
+	return "", nil
+}
+
+func replayMySQLBinlogs(mysqlCfg config.MySQLConfig, base *manifest.Manifest, flavor string) {
+	target, err := parsePITR(restorePITR)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Restores into another database replay the original one's changes
+	// renamed into it
+	database := mysqlCfg.Database
+	if base.Database != "" {
+		database = base.Database
+	}
+	if database != mysqlCfg.Database {
+		fmt.Printf("   Rewriting changes to %s into %s\n", database, mysqlCfg.Database)
+	}
+
+	fmt.Printf("\n🔄 Replaying binlogs from %s:%d up to %s...\n", base.Binlog.File, base.Binlog.Position, restorePITR)
+
+	err = mysql.ReplayBinlogs(flavor, mysqlCfg.Host, mysqlCfg.Port, mysqlCfg.User, mysqlCfg.Password, mysqlConn(mysqlCfg),
+		base.Binlog.File, base.Binlog.Position, target.Format("2006-01-02 15:04:05"), database, mysqlCfg.Database)
+	if err != nil {
+		restoreFailed(fmt.Errorf("binlog replay: %w", err))
+	}
+
+	fmt.Println("\n✅ MySQL point-in-time recovery completed successfully!")
+}
+
+// selectMongoDBPITRBase picks the newest full dump taken before --pitr that
+// recorded its oplog position, and checks that the collected oplog reaches
+// from it to --pitr.
+func selectMongoDBPITRBase(backups []BackupInfo) (string, *manifest.Manifest) {
+	target, err := parsePITR(restorePITR)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, backup := range backups {
+		if restoreFile != "" && filepath.Clean(restoreFile) != backup.Path {
+			continue
+		}
+
+		m, err := manifest.Read(backup.Path)
+		if err != nil || m.OplogStart == nil || m.Partial() || !m.CreatedAt.Before(target) {
+			continue
+		}
+
+		start := bson.Timestamp{T: m.OplogStart.T, I: m.OplogStart.I}
+		if err := mongodb.CheckOplog(start, target); err != nil {
+			log.Fatalf("Can't recover to %s from %s: %v", restorePITR, filepath.Base(backup.Path), err)
+		}
+		return backup.Path, m
+	}
+
+	log.Fatalf("No MongoDB backup with an oplog position was taken before %s", restorePITR)
+	return "", nil
+}
+
+// replayMongoDBOplog replays the captured oplog from the base dump's oplog
+// position up to --pitr. The entries are gathered into an oplog.bson in a
+// temporary directory and applied with mongorestore --oplogReplay.
+func replayMongoDBOplog(uri string, base *manifest.Manifest) {
+	target, err := parsePITR(restorePITR)
+	if err != nil {
+		restoreFailed(err)
+	}
+
+	fmt.Printf("\n🔄 Replaying oplog up to %s...\n", restorePITR)
+
+	tmpDir, err := os.MkdirTemp("", "backitup-oplog-")
+	if err != nil {
+		restoreFailed(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oplogFile, err := os.Create(filepath.Join(tmpDir, "oplog.bson"))
+	if err != nil {
+		restoreFailed(err)
+	}
+
+	start := bson.Timestamp{T: base.OplogStart.T, I: base.OplogStart.I}
+	count, err := mongodb.ExtractOplog(oplogFile, start, target)
+	oplogFile.Close()
+	if err != nil {
+		restoreFailed(fmt.Errorf("failed to read captured oplog: %w", err))
+	}
+	fmt.Printf("   %d oplog entries to replay\n", count)
+
+	if count > 0 {
+		cmd := exec.Command("mongorestore", "--uri", uri, "--oplogReplay", tmpDir)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		if err := tool.Run(cmd); err != nil {
+			restoreFailed(fmt.Errorf("oplog replay: %w", err))
+		}
+	}
+
+	fmt.Println("\n✅ MongoDB point-in-time recovery completed successfully!")
+}
+
+// hasGzipFiles reports whether a mongodump directory was written with --gzip.
+func hasGzipFiles(backupPath string) bool {
+	matches, _ := filepath.Glob(filepath.Join(backupPath, "*", "*.bson.gz"))
+	return len(matches) > 0
+}
+
+// mysqlRestoreFlavor returns the flavor whose client tools restore a backup:
+// the one recorded in its manifest, or else the target server's. Restoring
+// a MariaDB dump into MySQL, or the other way round, gets a warning, as
+// each has statements the other rejects.
+func mysqlRestoreFlavor(mysqlCfg config.MySQLConfig, backupPath string) string {
+	var backupFlavor string
+	if m, err := manifest.Read(backupPath); err == nil {
+		backupFlavor = m.Flavor
+	}
+
+	var serverFlavor string
+	if db, err := mysql.Connection(mysqlCfg.Host, mysqlCfg.Port, mysqlCfg.User, mysqlCfg.Password, "", mysqlConn(mysqlCfg)); err == nil {
+		serverFlavor, _ = mysql.ServerFlavor(db)
+		db.Close()
+	}
+
+	if backupFlavor != "" && serverFlavor != "" && backupFlavor != serverFlavor {
+		fmt.Printf("⚠️  This backup was taken from %s but the target server is %s; some statements may not be accepted.\n",
+			flavorName(backupFlavor), flavorName(serverFlavor))
+	}
+
+	switch {
+	case backupFlavor != "":
+		return backupFlavor
+	case serverFlavor != "":
+		return serverFlavor
+	}
+	return mysql.FlavorMySQL
+}
+
+func flavorName(flavor string) string {
+	if flavor == mysql.FlavorMariaDB {
+		return "MariaDB"
+	}
+	return "MySQL"
+}
+
+func restoreMySQL(mysqlCfg config.MySQLConfig, backupPath, flavor string) {
 	fmt.Println("\n🔄 Restoring MySQL from backup...")
 	fmt.Printf("   Source: %s\n", backupPath)
 	fmt.Printf("   Database: %s\n", mysqlCfg.Database)
@@ -207,69 +1017,522 @@ func restoreMySQL(mysqlCfg config.MySQLConfig, backupPath string) {
 		log.Fatal("MySQL database not configured. Run: ./BackItUp mysql --config --database yourdb")
 	}
 
-	// Check if mysql is available
-	if _, err := exec.LookPath("mysql"); err != nil {
-		log.Fatal("mysql command not found. Please install MySQL client.")
-	}
+	// Check if the client is available
+	tools := mysqlConn(mysqlCfg).Backend()
+	client := mysql.ToolsFor(flavor, tools).Client
+	requireTool(tools, client, fmt.Sprintf("%s command not found. Please install the %s client.", client, flavorName(flavor)))
 
-	// Read the backup file
-	backupData, err := os.ReadFile(backupPath)
-	if err != nil {
-		log.Fatal("Failed to read backup file:", err)
+	if err := mysql.EnsureDatabase(mysqlCfg.Host, mysqlCfg.Port, mysqlCfg.User, mysqlCfg.Password, mysqlCfg.Database, mysqlConn(mysqlCfg)); err != nil {
+		restoreFailed(fmt.Errorf("failed to create target database: %w", err))
 	}
 
 	// Execute restore
-	cmd := exec.Command("mysql",
-		"-h", mysqlCfg.Host,
-		"-P", mysqlCfg.Port,
-		"-u", mysqlCfg.User,
-		fmt.Sprintf("-p%s", mysqlCfg.Password),
-		mysqlCfg.Database,
-	)
+	args := mysql.ClientArgs(client, mysqlCfg.Host, mysqlCfg.Port, mysqlCfg.User, mysqlCfg.Password, mysqlConn(mysqlCfg))
+	cmd := tools.Command(mysql.ClientEnv(mysqlCfg.Password, mysqlConn(mysqlCfg)), client, append(args, mysqlCfg.Database)...)
 
-	cmd.Stdin = strings.NewReader(string(backupData))
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	err = cmd.Run()
+	if len(restoreTables) > 0 {
+		fmt.Printf("   Tables: %s\n", strings.Join(restoreTables, ", "))
+	}
+
+	source, finish := openBackupStream(backupPath)
+
+	var err error
+	if len(restoreTables) > 0 {
+		err = runWithInput(cmd, func(w io.Writer) error {
+			return mysql.ExtractTables(source, w, restoreTables)
+		})
+	} else {
+		backend.SetStdin(cmd, source)
+		err = tool.Run(cmd)
+	}
+	finish()
 	if err != nil {
-		log.Fatal("Restore failed:", err)
+		restoreFailed(err)
 	}
 
 	fmt.Println("\n✅ MySQL restore completed successfully!")
 }
 
+func restoreSQLite(backupPath, target string) {
+	fmt.Println("\n🔄 Restoring SQLite from backup...")
+	fmt.Printf("   Source: %s\n", backupPath)
+	fmt.Printf("   Target: %s\n", target)
+	fmt.Println()
+
+	// The backup is checked before the target is touched
+	if err := sqlite.Restore(backupPath, target); err != nil {
+		restoreFailed(err)
+	}
+
+	fmt.Println("\n✅ SQLite restore completed successfully!")
+}
+
+// restoreRedis stages the RDB file and explains how to load it. A running
+// server would overwrite it when it next saves, so it has to be stopped
+// without saving.
+func restoreRedis(redisCfg config.RedisConfig, backupPath, target string, local, appendOnly bool) {
+	fmt.Println("\n🔄 Staging Redis backup...")
+	fmt.Printf("   Source: %s\n", backupPath)
+	fmt.Printf("   Target: %s\n", target)
+	fmt.Println()
+
+	info, err := redis.Stage(backupPath, target)
+	if err != nil {
+		restoreFailed(err)
+	}
+
+	fmt.Printf("\n✅ RDB file staged (version %d, %s)\n", info.Version, formatSize(info.Size))
+	fmt.Println("\nTo load it:")
+	step := 1
+	if !local {
+		host, port := redisHostPort(redisCfg.Addr)
+		fmt.Printf("   %d. Stop Redis without saving over it: redis-cli -h %s -p %s SHUTDOWN NOSAVE\n", step, host, port)
+		fmt.Printf("   %d. Copy %s to the server, as the dbfilename in its dir (CONFIG GET dir, CONFIG GET dbfilename)\n", step+1, target)
+		step += 2
+	}
+	if appendOnly || local {
+		// A stopped server can't tell whether it has appendonly enabled
+		fmt.Printf("   %d. With appendonly enabled, Redis would load its AOF instead:\n", step)
+		fmt.Println("      set appendonly no in redis.conf (re-enable it once the data is back)")
+		step++
+	}
+	fmt.Printf("   %d. Start Redis\n", step)
+}
+
+// redisRunning reports whether a Redis server accepts connections at addr.
+func redisRunning(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, 3*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// isLoopbackAddr reports whether a host:port address is on this machine.
+func isLoopbackAddr(addr string) bool {
+	host, _ := redisHostPort(addr)
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// redisHostPort splits a Redis address, defaulting to the standard port.
+func redisHostPort(addr string) (string, string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, "6379"
+	}
+	return host, port
+}
+
 func restorePostgreSQL(pgCfg config.PostgreSQLConfig, backupPath string) {
 	fmt.Println("\n🔄 Restoring PostgreSQL from backup...")
 	fmt.Printf("   Source: %s\n", backupPath)
-	fmt.Printf("   Database: %s\n", pgCfg.Database)
 	fmt.Println()
 
+	if isClusterBackup(backupPath) {
+		if len(restoreTables) > 0 || len(restoreSchemas) > 0 || restoreIntoSchema != "" {
+			log.Fatal("--table, --schema and --into-schema can't be used with a cluster backup, restore a single database dump from it instead")
+		}
+		restorePostgreSQLCluster(pgCfg, backupPath)
+		fmt.Println("\n✅ PostgreSQL cluster restore completed successfully!")
+		return
+	}
+
 	if pgCfg.Database == "" {
 		log.Fatal("PostgreSQL database not configured. Run: ./BackItUp postgresql --config --database yourdb")
 	}
+	fmt.Printf("   Database: %s\n", pgCfg.Database)
 
-	// Check if psql is available
-	if _, err := exec.LookPath("psql"); err != nil {
-		log.Fatal("psql command not found. Please install PostgreSQL client.")
+	if postgresql.IsPhysicalBackup(backupPath) {
+		log.Fatal("Physical backups are restored into a new data directory. Use --data-dir (and optionally --pitr)")
+	}
+
+	if err := postgresql.EnsureDatabase(pgCfg.Host, pgCfg.Port, pgCfg.User, pgCfg.Password, pgCfg.Database, postgresqlConn(pgCfg)); err != nil {
+		restoreFailed(fmt.Errorf("failed to create target database: %w", err))
+	}
+
+	plain := isPlainSQLBackup(backupPath)
+	if plain && len(restoreSchemas) > 0 {
+		log.Fatal("--schema requires a custom or directory format backup")
+	}
+
+	if restoreIntoSchema != "" || (plain && len(restoreTables) > 0) {
+		restorePostgreSQLTables(pgCfg, backupPath, plain)
+	} else if plain {
+		restorePostgreSQLPlain(pgCfg, backupPath)
+	} else {
+		restorePostgreSQLArchive(pgCfg, backupPath)
+	}
+
+	fmt.Println("\n✅ PostgreSQL restore completed successfully!")
+}
+
+// pitrLayouts are the accepted --pitr time formats.
+var pitrLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+// restorePostgreSQLPhysical lays out a data directory from a physical backup.
+// With --pitr it picks the newest base backup taken before the target time.
+func restorePostgreSQLPhysical(backups []BackupInfo, hooks hook.Config) {
+	if restoreDataDir == "" {
+		log.Fatal("Physical restores need --data-dir for the new data directory")
+	}
+
+	var physical []BackupInfo
+	for _, backup := range backups {
+		if postgresql.IsPhysicalBackup(backup.Path) {
+			physical = append(physical, backup)
+		}
+	}
+
+	var backupPath string
+	if restoreFile != "" {
+		backupPath = restoreFile
+	} else if restorePITR != "" {
+		target, err := parsePITR(restorePITR)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, backup := range physical {
+			taken, err := postgresql.BaseBackupTime(backup.Path)
+			if err == nil && taken.Before(target) {
+				backupPath = backup.Path
+				break
+			}
+		}
+		if backupPath == "" {
+			log.Fatalf("No physical backup taken before %s", restorePITR)
+		}
+	} else if len(physical) == 0 {
+		fmt.Println("No PostgreSQL physical backups found.")
+		return
+	} else if restoreLatest {
+		backupPath = physical[0].Path
+	} else {
+		backupPath = selectBackup(physical, "PostgreSQL physical")
+	}
+
+	if backupPath == "" {
+		fmt.Println("No backup selected. Restore cancelled.")
+		return
+	}
+
+	fmt.Println("\n🔄 Preparing PostgreSQL data directory from physical backup...")
+	fmt.Printf("   Source:     %s\n", backupPath)
+	fmt.Printf("   Data dir:   %s\n", restoreDataDir)
+	fmt.Printf("   WAL dir:    %s\n", restoreWALDir)
+	if restorePITR != "" {
+		fmt.Printf("   Target:     %s\n", restorePITR)
+	} else {
+		fmt.Println("   Target:     end of archived WAL")
+	}
+
+	restorePreflight(func() *preflight.Report {
+		return preflightPostgreSQLPhysical(backupPath, restoreDataDir, restoreWALDir)
+	})
+
+	// The cluster is rebuilt whole, so the data directory is the target
+	if !confirmRestore("PostgreSQL", restoreDataDir, nil) {
+		fmt.Println("Restore cancelled.")
+		return
+	}
+
+	startRestoreRun("postgresql", "", backupPath, hooks)
+	err := postgresql.PrepareRecovery(backupPath, restoreDataDir, restoreWALDir, restorePITR)
+	if err != nil {
+		restoreFailed(err)
 	}
+	finishRestoreRun()
 
-	cmd := exec.Command("psql",
+	fmt.Println("\n✅ Data directory is ready for recovery!")
+	fmt.Printf("   Start PostgreSQL with: pg_ctl -D %s start\n", restoreDataDir)
+	fmt.Println("   It replays archived WAL, then promotes and accepts connections.")
+}
+
+func parsePITR(value string) (time.Time, error) {
+	for _, layout := range pitrLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --pitr time %q, expected e.g. \"2026-10-17 14:32\"", value)
+}
+
+// isClusterBackup reports whether a PostgreSQL backup is a cluster backup set
+// created with --cluster.
+func isClusterBackup(backupPath string) bool {
+	_, err := os.Stat(filepath.Join(backupPath, postgresql.GlobalsFile))
+	return err == nil
+}
+
+// restorePostgreSQLCluster restores roles and tablespaces first, then every
+// database in the set. The database dumps carry CREATE DATABASE, so they are
+// replayed through the postgres maintenance database, except the dump of
+// postgres itself, which drops and recreates it and so goes through
+// template1.
+func restorePostgreSQLCluster(pgCfg config.PostgreSQLConfig, backupPath string) {
+	maintenanceCfg := pgCfg
+	maintenanceCfg.Database = "postgres"
+	template1Cfg := pgCfg
+	template1Cfg.Database = "template1"
+
+	fmt.Println("   Restoring roles and tablespaces...")
+	restorePostgreSQLPlain(maintenanceCfg, filepath.Join(backupPath, postgresql.GlobalsFile))
+
+	entries, err := os.ReadDir(backupPath)
+	if err != nil {
+		restoreFailed(fmt.Errorf("failed to read backup: %w", err))
+	}
+
+	for _, entry := range entries {
+		if entry.Name() == postgresql.GlobalsFile {
+			continue
+		}
+
+		dumpPath := filepath.Join(backupPath, entry.Name())
+		database := strings.TrimSuffix(entry.Name(), ".gz")
+		database = strings.TrimSuffix(strings.TrimSuffix(database, ".sql"), ".dump")
+		fmt.Printf("   Restoring database %s...\n", database)
+
+		connectCfg := maintenanceCfg
+		if database == "postgres" {
+			connectCfg = template1Cfg
+		}
+
+		if isPlainSQLBackup(dumpPath) {
+			restorePostgreSQLPlain(connectCfg, dumpPath)
+		} else {
+			restorePostgreSQLArchive(connectCfg, dumpPath, "--create")
+		}
+	}
+}
+
+// isPlainSQLBackup reports whether a PostgreSQL backup is a plain SQL script
+// (replayed with psql) rather than a custom or directory archive (pg_restore).
+func isPlainSQLBackup(backupPath string) bool {
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		restoreFailed(fmt.Errorf("failed to read backup: %w", err))
+	}
+	return !info.IsDir() && (strings.HasSuffix(backupPath, ".sql") || strings.HasSuffix(backupPath, ".sql.gz"))
+}
+
+func restorePostgreSQLPlain(pgCfg config.PostgreSQLConfig, backupPath string) {
+	// Check if psql is available
+	tools := postgresqlConn(pgCfg).Backend()
+	requireTool(tools, "psql", "psql command not found. Please install PostgreSQL client.")
+
+	cmd := tools.Command([]string{"PGPASSWORD=" + pgCfg.Password}, "psql",
 		"-h", pgCfg.Host,
 		"-p", pgCfg.Port,
 		"-U", pgCfg.User,
-		"-d", pgCfg.Database,
-		"-f", backupPath,
+		"-d", postgresql.Conninfo(pgCfg.Host, pgCfg.Database, postgresqlConn(pgCfg)),
 	)
 
-	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", pgCfg.Password))
+	source, finish := openBackupStream(backupPath)
+
+	backend.SetStdin(cmd, source)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	err := cmd.Run()
+	err := tool.Run(cmd)
+	finish()
 	if err != nil {
-		log.Fatal("Restore failed:", err)
+		restoreFailed(err)
 	}
+}
 
-	fmt.Println("\n✅ PostgreSQL restore completed successfully!")
+// restorePostgreSQLTables restores selected tables, optionally into a side
+// schema. pg_restore can't rename schemas, so archives are converted back to
+// a SQL script with pg_restore -f - and filtered like plain dumps on the way
+// into psql.
+func restorePostgreSQLTables(pgCfg config.PostgreSQLConfig, backupPath string, plain bool) {
+	tools := postgresqlConn(pgCfg).Backend()
+	requireTool(tools, "psql", "psql command not found. Please install PostgreSQL client.")
+
+	opts := postgresql.ExtractOptions{
+		Tables:     restoreTables,
+		IntoSchema: restoreIntoSchema,
+		Clean:      restoreClean,
+	}
+	if len(opts.Tables) == 0 {
+		opts.Tables = []string{"*"}
+	}
+	fmt.Printf("   Tables: %s\n", strings.Join(opts.Tables, ", "))
+	if opts.IntoSchema != "" {
+		fmt.Printf("   Into schema: %s\n", opts.IntoSchema)
+	}
+
+	var source io.Reader
+	var script *exec.Cmd
+	var checkScript func(error) error
+	finish := func() {}
+
+	if plain {
+		source, finish = openBackupStream(backupPath)
+	} else {
+		requireTool(tools, "pg_restore", "pg_restore command not found. Please install PostgreSQL client.")
+
+		args := []string{"-f", "-"}
+		for _, schema := range restoreSchemas {
+			args = append(args, "-n", schema)
+		}
+		script = tools.Command(nil, "pg_restore", args...)
+		closeArchive, err := archiveInput(tools, script, backupPath)
+		if err != nil {
+			restoreFailed(err)
+		}
+		defer closeArchive()
+		script.Stderr = os.Stderr
+		scriptOutput := tool.Capture(script)
+		checkScript = scriptOutput.Check
+
+		stdout, err := script.StdoutPipe()
+		if err != nil {
+			restoreFailed(err)
+		}
+		if err := script.Start(); err != nil {
+			restoreFailed(err)
+		}
+		source = stdout
+	}
+
+	cmd := tools.Command([]string{"PGPASSWORD=" + pgCfg.Password}, "psql",
+		"-h", pgCfg.Host,
+		"-p", pgCfg.Port,
+		"-U", pgCfg.User,
+		"-d", postgresql.Conninfo(pgCfg.Host, pgCfg.Database, postgresqlConn(pgCfg)),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := runWithInput(cmd, func(w io.Writer) error {
+		return postgresql.ExtractTables(source, w, opts)
+	})
+	finish()
+
+	if script != nil {
+		if err != nil {
+			script.Process.Kill()
+		}
+		if waitErr := checkScript(script.Wait()); err == nil {
+			err = waitErr
+		}
+	}
+	if err != nil {
+		restoreFailed(err)
+	}
+}
+
+// openBackupStream opens a dump file for streaming into a database client.
+// Gzipped dumps (.gz) are decompressed on the fly, so nothing is loaded into
+// memory or unpacked to disk. Progress is measured on the bytes read from the
+// file, which gives an accurate percentage and ETA for compressed dumps too.
+// The returned function stops the progress line and closes the file.
+func openBackupStream(backupPath string) (io.Reader, func()) {
+	file, err := os.Open(backupPath)
+	if err != nil {
+		restoreFailed(fmt.Errorf("failed to read backup file: %w", err))
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		restoreFailed(fmt.Errorf("failed to read backup file: %w", err))
+	}
+
+	meter := progress.Start("Restored", info.Size())
+	var source io.Reader = bufio.NewReaderSize(meter.Reader(file), 1<<20)
+
+	if strings.HasSuffix(backupPath, ".gz") {
+		gz, err := gzip.NewReader(source)
+		if err != nil {
+			restoreFailed(fmt.Errorf("failed to decompress backup file: %w", err))
+		}
+		source = gz
+	}
+
+	return source, func() {
+		meter.Finish()
+		file.Close()
+	}
+}
+
+// runWithInput runs cmd with its stdin fed by produce, e.g. a filter
+// streaming the selected tables out of a backup file.
+func runWithInput(cmd *exec.Cmd, produce func(w io.Writer) error) error {
+	reader, writer := io.Pipe()
+	backend.SetStdin(cmd, reader)
+	output := tool.Capture(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// If the tool exits early, produce's writes fail instead of blocking
+	waited := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		reader.CloseWithError(io.ErrClosedPipe)
+		waited <- err
+	}()
+
+	produceErr := produce(writer)
+	writer.Close()
+
+	if err := <-waited; err != nil {
+		return output.Check(err)
+	}
+	return produceErr
+}
+
+func restorePostgreSQLArchive(pgCfg config.PostgreSQLConfig, backupPath string, extraArgs ...string) {
+	// Check if pg_restore is available
+	tools := postgresqlConn(pgCfg).Backend()
+	requireTool(tools, "pg_restore", "pg_restore command not found. Please install PostgreSQL client.")
+
+	args := []string{
+		"-h", pgCfg.Host,
+		"-p", pgCfg.Port,
+		"-U", pgCfg.User,
+		"-d", postgresql.Conninfo(pgCfg.Host, pgCfg.Database, postgresqlConn(pgCfg)),
+	}
+	if restoreJobs > 1 && tools.Local() {
+		args = append(args, "-j", strconv.Itoa(restoreJobs))
+	} else if restoreJobs > 1 {
+		fmt.Printf("⚠️  Restoring without parallel jobs: the archive is streamed into pg_restore in %s\n", tools)
+	}
+	if restoreClean {
+		args = append(args, "--clean", "--if-exists")
+	}
+	for _, table := range restoreTables {
+		args = append(args, "-t", table)
+	}
+	for _, schema := range restoreSchemas {
+		args = append(args, "-n", schema)
+	}
+	args = append(args, extraArgs...)
+
+	cmd := tools.Command([]string{"PGPASSWORD=" + pgCfg.Password}, "pg_restore", args...)
+	closeArchive, err := archiveInput(tools, cmd, backupPath)
+	if err != nil {
+		restoreFailed(err)
+	}
+	defer closeArchive()
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = tool.Run(cmd)
+	if err != nil {
+		restoreFailed(err)
+	}
 }
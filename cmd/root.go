@@ -16,6 +16,8 @@ Currently supports:
   - MongoDB
   - MySQL
   - PostgreSQL
+  - SQLite
+  - Redis
 
 Use the database-specific subcommands to configure and run backups.
 All backups are stored in the BACKUP/ directory organized by database type.`,
@@ -29,5 +31,5 @@ func Execute() {
 }
 
 func init() {
-	// Root command flags can be added here if needed
+	rootCmd.PersistentFlags().BoolVar(&skipPreflight, "skip-preflight", false, "Skip the tool, version and disk space checks before backups and restores")
 }
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/config"
+	"github.com/tiyfiy/BackItUp/internal/history"
+	"github.com/tiyfiy/BackItUp/internal/hook"
+	"github.com/tiyfiy/BackItUp/internal/notify"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+	"github.com/tiyfiy/BackItUp/internal/tool"
+)
+
+// runBackup runs one backup between its hooks, records it in the run
+// history and sends a notification if it fails. backup returns the path of
+// the new backup.
+func runBackup(engine, database string, hooks hook.Config, backup func() (string, error)) error {
+	run := history.Run{
+		Operation: "backup",
+		Engine:    engine,
+		Database:  database,
+		StartedAt: time.Now(),
+	}
+
+	results, err := hook.Run(hooks, hookEnv(run, hook.PreBackup))
+	run.Hooks = results
+	if err == nil {
+		var path string
+		path, err = backup()
+		run.Path = path
+		run.Size = progress.SizeOf(path)
+	}
+
+	finishRun(run, hooks, err)
+	return err
+}
+
+// finishRun completes a run with its outcome, runs the post and failure
+// hooks, appends it to the run history and notifies Slack about failures.
+func finishRun(run history.Run, hooks hook.Config, err error) {
+	run.Duration = time.Since(run.StartedAt).Seconds()
+	run.Status = history.StatusSuccess
+
+	if err != nil {
+		run.Status = history.StatusFailed
+		run.Error = err.Error()
+
+		var toolErr *tool.Error
+		var hookErr *hook.Error
+		if errors.As(err, &toolErr) {
+			run.Error = fmt.Sprintf("%s failed: %v", toolErr.Tool, toolErr.Err)
+			run.Stderr = toolErr.Stderr
+			run.Hint = toolErr.Hint
+		} else if !errors.As(err, &hookErr) {
+			// Driver errors (e.g. a failed login) don't carry a hint yet;
+			// the hints are about databases, not hooks
+			if run.Hint = tool.Classify(run.Error); run.Hint != "" && !strings.Contains(run.Error, run.Hint) {
+				fmt.Println("💡 " + run.Hint)
+			}
+		}
+	}
+
+	// Post hooks run either way, e.g. to restart what a pre hook stopped
+	events := []string{"post-" + run.Operation}
+	if err != nil {
+		events = append(events, hook.OnFailure)
+	}
+	for _, event := range events {
+		results, _ := hook.Run(hooks, hookEnv(run, event))
+		run.Hooks = append(run.Hooks, results...)
+	}
+
+	if err != nil {
+		notifyFailure(run)
+	}
+
+	if err := history.Append(run); err != nil {
+		log.Printf("Warning: failed to record run history: %v", err)
+	}
+}
+
+// hookEnv describes a run to its hooks.
+func hookEnv(run history.Run, event string) hook.Env {
+	return hook.Env{
+		Event:     event,
+		Operation: run.Operation,
+		Target:    runTarget(run),
+		Engine:    run.Engine,
+		Database:  run.Database,
+		Path:      run.Path,
+		Size:      run.Size,
+		Status:    run.Status,
+		Error:     run.Error,
+	}
+}
+
+// notifyFailure posts a failed run to the configured Slack webhook.
+func notifyFailure(run history.Run) {
+	cfg, err := config.Load()
+	if err != nil || cfg.SlackWebhook == "" {
+		return
+	}
+
+	host, _ := os.Hostname()
+	text := fmt.Sprintf(":x: BackItUp %s of %s failed on %s", run.Operation, runTarget(run), host)
+	text += fmt.Sprintf("\n*Error:* %s", run.Error)
+	if run.Stderr != "" {
+		text += fmt.Sprintf("\n```%s```", run.Stderr)
+	}
+	if run.Hint != "" {
+		text += fmt.Sprintf("\n*Hint:* %s", run.Hint)
+	}
+
+	if err := notify.Slack(cfg.SlackWebhook, text); err != nil {
+		log.Printf("Warning: failed to send Slack notification: %v", err)
+	}
+}
+
+// runTarget describes what a run backed up or restored, e.g. "mysql/shop".
+func runTarget(run history.Run) string {
+	if run.Database == "" {
+		return run.Engine
+	}
+	return run.Engine + "/" + run.Database
+}
+
+// restoreRun is the restore in progress, recorded in the run history when it
+// finishes or fails, and restoreHooks the hooks of its target.
+var (
+	restoreRun   *history.Run
+	restoreHooks hook.Config
+)
+
+// startRestoreRun starts recording a restore of backupPath and runs the
+// pre-restore hooks, which may cancel it.
+func startRestoreRun(engine, database, backupPath string, hooks hook.Config) {
+	restoreRun = &history.Run{
+		Operation: "restore",
+		Engine:    engine,
+		Database:  database,
+		Path:      backupPath,
+		Size:      progress.SizeOf(backupPath),
+		StartedAt: time.Now(),
+	}
+	restoreHooks = hooks
+
+	results, err := hook.Run(hooks, hookEnv(*restoreRun, hook.PreRestore))
+	restoreRun.Hooks = results
+	if err != nil {
+		restoreFailed(err)
+	}
+}
+
+// finishRestoreRun records the restore in progress as successful.
+func finishRestoreRun() {
+	if restoreRun != nil {
+		finishRun(*restoreRun, restoreHooks, nil)
+		restoreRun = nil
+	}
+}
+
+// restoreFailed records the restore in progress as failed and exits.
+func restoreFailed(err error) {
+	if restoreRun != nil {
+		finishRun(*restoreRun, restoreHooks, err)
+		restoreRun = nil
+	}
+	log.Fatal("Restore failed: ", err)
+}
@@ -60,6 +60,7 @@ func showStatus() {
 	fmt.Printf("  User:       %s\n", cfg.MySQL.User)
 	fmt.Printf("  Password:   %s\n", maskPassword(cfg.MySQL.Password))
 	fmt.Printf("  Database:   %s\n", getValueOrDefault(cfg.MySQL.Database, "not set"))
+	fmt.Printf("  Dumper:     %s\n", cfg.MySQL.Dumper)
 	if cfg.MySQL.Database != "" {
 		fmt.Printf("  Status:     ✅ Configured\n")
 	} else {
@@ -75,13 +76,46 @@ func showStatus() {
 	fmt.Printf("  User:       %s\n", cfg.PostgreSQL.User)
 	fmt.Printf("  Password:   %s\n", maskPassword(cfg.PostgreSQL.Password))
 	fmt.Printf("  Database:   %s\n", getValueOrDefault(cfg.PostgreSQL.Database, "not set"))
-	if cfg.PostgreSQL.Database != "" {
+	fmt.Printf("  Format:     %s (jobs: %d)\n", cfg.PostgreSQL.Format, cfg.PostgreSQL.Jobs)
+	if cfg.PostgreSQL.Physical {
+		fmt.Printf("  Mode:       physical (pg_basebackup)\n")
+	} else if cfg.PostgreSQL.Cluster {
+		fmt.Printf("  Mode:       cluster (roles, tablespaces and all databases)\n")
+	}
+	if cfg.PostgreSQL.Database != "" || cfg.PostgreSQL.Cluster || cfg.PostgreSQL.Physical {
 		fmt.Printf("  Status:     ✅ Configured\n")
 	} else {
 		fmt.Printf("  Status:     ⚠️  Database not set\n")
 	}
 	fmt.Println()
 
+	// SQLite Status
+	fmt.Println("🪶 SQLite")
+	fmt.Println("───────────────────────────────────────────────────────────────")
+	if len(cfg.SQLite.Paths) > 0 {
+		for _, path := range cfg.SQLite.Paths {
+			fmt.Printf("  Path:       %s\n", path)
+		}
+		fmt.Printf("  Format:     %s\n", cfg.SQLite.Format)
+		fmt.Printf("  Status:     ✅ Configured\n")
+	} else {
+		fmt.Printf("  Status:     ⚠️  No paths set\n")
+	}
+	fmt.Println()
+
+	// Redis Status
+	fmt.Println("🟥 Redis")
+	fmt.Println("───────────────────────────────────────────────────────────────")
+	fmt.Printf("  Address:    %s\n", cfg.Redis.Addr)
+	fmt.Printf("  Password:   %s\n", maskPassword(cfg.Redis.Password))
+	fmt.Printf("  Method:     %s\n", cfg.Redis.Method)
+	if cfg.Redis.Addr != "localhost:6379" {
+		fmt.Printf("  Status:     ✅ Configured\n")
+	} else {
+		fmt.Printf("  Status:     ⚠️  Using defaults\n")
+	}
+	fmt.Println()
+
 	// General Settings
 	fmt.Println("⚙️  General Settings")
 	fmt.Println("───────────────────────────────────────────────────────────────")
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/tiyfiy/BackItUp/internal/config"
+	"github.com/tiyfiy/BackItUp/internal/mongodb"
+	"github.com/tiyfiy/BackItUp/internal/tunnel"
+)
+
+var (
+	errSocketTunnel = errors.New("a unix socket can't be reached through an SSH tunnel, set a host instead")
+	errExecTunnel   = errors.New("tools running in a container can't reach an SSH tunnel opened on this machine, use one or the other")
+)
+
+// tunnelMySQL opens the SSH tunnel configured for MySQL, if any, and points
+// the config at its local end. The returned func closes the tunnel.
+func tunnelMySQL(mysqlCfg *config.MySQLConfig) (func(), error) {
+	if !mysqlCfg.SSH.Enabled() {
+		return func() {}, nil
+	}
+	if mysqlCfg.Socket != "" {
+		return nil, errSocketTunnel
+	}
+	if !mysqlCfg.Exec.Local() {
+		return nil, errExecTunnel
+	}
+
+	t, err := openTunnel(mysqlCfg.SSH, net.JoinHostPort(mysqlCfg.Host, mysqlCfg.Port))
+	if err != nil {
+		return nil, err
+	}
+
+	// The server certificate is still issued for the server's own name
+	if mysqlCfg.TLS.ServerName == "" {
+		mysqlCfg.TLS.ServerName = mysqlCfg.Host
+	}
+	mysqlCfg.Host, mysqlCfg.Port = t.Host, t.Port
+	return func() { t.Close() }, nil
+}
+
+// tunnelPostgreSQL is tunnelMySQL for PostgreSQL.
+func tunnelPostgreSQL(pgCfg *config.PostgreSQLConfig) (func(), error) {
+	if !pgCfg.SSH.Enabled() {
+		return func() {}, nil
+	}
+	if pgCfg.Socket != "" {
+		return nil, errSocketTunnel
+	}
+	if !pgCfg.Exec.Local() {
+		return nil, errExecTunnel
+	}
+
+	t, err := openTunnel(pgCfg.SSH, net.JoinHostPort(pgCfg.Host, pgCfg.Port))
+	if err != nil {
+		return nil, err
+	}
+
+	if pgCfg.TLS.ServerName == "" {
+		pgCfg.TLS.ServerName = pgCfg.Host
+	}
+	pgCfg.Host, pgCfg.Port = t.Host, t.Port
+	return func() { t.Close() }, nil
+}
+
+// tunnelRedis is tunnelMySQL for Redis.
+func tunnelRedis(redisCfg *config.RedisConfig) (func(), error) {
+	if !redisCfg.SSH.Enabled() {
+		return func() {}, nil
+	}
+
+	t, err := openTunnel(redisCfg.SSH, redisCfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	redisCfg.Addr = t.Addr()
+	return func() { t.Close() }, nil
+}
+
+// tunnelMongoDB is tunnelMySQL for MongoDB. Only URIs naming one server
+// can be tunneled; the URI is pointed at the tunnel as a direct connection.
+func tunnelMongoDB(mongoCfg *config.MongoDBConfig) (func(), error) {
+	if !mongoCfg.SSH.Enabled() {
+		return func() {}, nil
+	}
+	if !mongoCfg.Exec.Local() {
+		return nil, errExecTunnel
+	}
+
+	remote, err := mongodb.TunnelHost(mongoCfg.URI)
+	if err != nil {
+		return nil, fmt.Errorf("SSH tunnel: %w", err)
+	}
+	t, err := openTunnel(mongoCfg.SSH, remote)
+	if err != nil {
+		return nil, err
+	}
+
+	mongoCfg.URI = mongodb.TunnelURI(mongoCfg.URI, t.Addr())
+	return func() { t.Close() }, nil
+}
+
+func openTunnel(sshCfg tunnel.Config, remote string) (*tunnel.Tunnel, error) {
+	route := sshCfg.Host
+	if len(sshCfg.Jump) > 0 {
+		route = fmt.Sprintf("%s (via %s)", sshCfg.Host, strings.Join(sshCfg.Jump, ", "))
+	}
+	fmt.Printf("🔐 Tunneling to %s through %s\n", remote, route)
+
+	t, err := tunnel.Open(sshCfg, remote)
+	if err != nil {
+		return nil, fmt.Errorf("SSH tunnel: %w", err)
+	}
+	return t, nil
+}
+
+// mustTunnel exits when a tunnel can't be opened, for commands that don't
+// record runs.
+func mustTunnel(closeTunnel func(), err error) func() {
+	if err != nil {
+		log.Fatal(err)
+	}
+	return closeTunnel
+}
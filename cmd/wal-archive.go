@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tiyfiy/BackItUp/internal/postgresql"
+)
+
+var walArchiveDir string
+
+var walArchiveCmd = &cobra.Command{
+	Use:   "wal-archive <path> <filename>",
+	Short: "Archive a PostgreSQL WAL segment (for archive_command)",
+	Long: `Copy a completed WAL segment into the backup store so physical backups
+can be recovered to any point in time.
+
+Use it as PostgreSQL's archive_command. PostgreSQL runs archive_command from
+its data directory, so pass an absolute --dir:
+
+  archive_mode = on
+  archive_command = '/usr/local/bin/BackItUp wal-archive %p %f --dir /srv/backitup/BACKUP/postgresql/wal'
+
+The command exits non-zero if the segment can't be stored, which makes
+PostgreSQL keep the segment and retry later.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := postgresql.ArchiveWAL(args[0], args[1], walArchiveDir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wal-archive:", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(walArchiveCmd)
+	walArchiveCmd.Flags().StringVar(&walArchiveDir, "dir", postgresql.WALDir, "Directory to store WAL segments in")
+}
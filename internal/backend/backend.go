@@ -0,0 +1,246 @@
+// Package backend runs the database client tools either on this machine or
+// inside the database's container, with docker exec or kubectl exec. The
+// tools' stdin and stdout are streamed either way, so dumps and restores go
+// through the same pipeline.
+package backend
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Kinds of backends.
+const (
+	KindLocal   = "local"
+	KindDocker  = "docker"
+	KindKubectl = "kubectl"
+)
+
+// ValidKind reports whether kind is a known backend. An empty kind is
+// local.
+func ValidKind(kind string) bool {
+	return kind == "" || kind == KindLocal || kind == KindDocker || kind == KindKubectl
+}
+
+// Config selects where the tools run.
+type Config struct {
+	// Kind is local (the default), docker or kubectl.
+	Kind string
+	// Container is the docker container, or for kubectl the container in
+	// the pod (the pod's default one when empty).
+	Container string
+	// Pod is the kubectl target: a pod name or e.g. deploy/postgres.
+	Pod string
+	// Namespace is the pod's namespace, kubectl's current one when empty.
+	Namespace string
+}
+
+// Local reports whether the tools run on this machine.
+func (c Config) Local() bool {
+	return c.Kind == "" || c.Kind == KindLocal
+}
+
+// Backend runs tools.
+type Backend interface {
+	// Command returns the command that runs name with args. env holds
+	// extra KEY=VALUE variables for the tool, such as passwords, which are
+	// kept off the command line. The tool's input is set with SetStdin.
+	Command(env []string, name string, args ...string) *exec.Cmd
+	// LookPath checks that the tool name can be run.
+	LookPath(name string) error
+	// Local reports whether the tools run on this machine and can use its
+	// files.
+	Local() bool
+	// String describes where the tools run, e.g. "docker container db".
+	String() string
+}
+
+// Local runs the tools on this machine.
+var Local Backend = local{}
+
+// New returns the backend cfg selects.
+func New(cfg Config) (Backend, error) {
+	switch cfg.Kind {
+	case "", KindLocal:
+		return Local, nil
+	case KindDocker:
+		if cfg.Container == "" {
+			return nil, fmt.Errorf("the docker backend needs a container")
+		}
+		return docker{container: cfg.Container}, nil
+	case KindKubectl:
+		if cfg.Pod == "" {
+			return nil, fmt.Errorf("the kubectl backend needs a pod")
+		}
+		return kubectl{pod: cfg.Pod, container: cfg.Container, namespace: cfg.Namespace}, nil
+	}
+	return nil, fmt.Errorf("unknown exec backend %q, expected local, docker or kubectl", cfg.Kind)
+}
+
+type local struct{}
+
+func (local) Command(env []string, name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
+}
+
+func (local) LookPath(name string) error {
+	_, err := exec.LookPath(name)
+	return err
+}
+
+func (local) Local() bool {
+	return true
+}
+
+func (local) String() string {
+	return "this machine"
+}
+
+type docker struct {
+	container string
+}
+
+func (d docker) Command(env []string, name string, args ...string) *exec.Cmd {
+	// -e with just a name passes the variable on from docker's environment
+	dockerArgs := []string{"exec", "-i"}
+	for _, kv := range env {
+		key, _, _ := strings.Cut(kv, "=")
+		dockerArgs = append(dockerArgs, "-e", key)
+	}
+	dockerArgs = append(dockerArgs, d.container, name)
+
+	cmd := exec.Command("docker", append(dockerArgs, args...)...)
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
+}
+
+func (d docker) LookPath(name string) error {
+	return lookPath(d, "docker", name)
+}
+
+func (docker) Local() bool {
+	return false
+}
+
+func (d docker) String() string {
+	return "docker container " + d.container
+}
+
+type kubectl struct {
+	pod       string
+	container string
+	namespace string
+}
+
+// envHeader starts the variables kubectl sends ahead of the tool's stdin.
+const envHeader = "BACKITUP-ENV"
+
+// readEnv reads the KEY=VALUE lines sent after envHeader, up to an empty
+// line, then runs the tool with the rest of stdin. sh's read takes one byte
+// at a time from a pipe, so none of the tool's input is consumed. Without
+// the header, stdin was replaced by the caller and the tool isn't run.
+const readEnv = `IFS= read -r line && [ "$line" = ` + envHeader + ` ] || { echo "BackItUp: the credentials were not sent on stdin" >&2; exit 1; }
+while IFS= read -r line && [ -n "$line" ]; do export "$line" || exit 1; done
+exec "$@"`
+
+func (k kubectl) Command(env []string, name string, args ...string) *exec.Cmd {
+	if len(env) == 0 {
+		return exec.Command("kubectl", append(k.execArgs(name), args...)...)
+	}
+
+	// kubectl exec can't set variables, and ones passed on the command line
+	// show up in ps here and in the pod. They are sent ahead of the tool's
+	// stdin instead, and nothing is left in the pod if the command never
+	// runs.
+	kubectlArgs := append(k.execArgs("sh"), "-c", readEnv, "sh", name)
+	cmd := exec.Command("kubectl", append(kubectlArgs, args...)...)
+
+	var header strings.Builder
+	header.WriteString(envHeader + "\n")
+	for _, kv := range env {
+		if strings.ContainsAny(kv, "\n\r") {
+			cmd.Err = fmt.Errorf("can't pass %s to %s: it contains a line break", strings.SplitN(kv, "=", 2)[0], k)
+		}
+		header.WriteString(kv + "\n")
+	}
+	header.WriteString("\n")
+	cmd.Stdin = &envInput{r: strings.NewReader(header.String())}
+	return cmd
+}
+
+// envInput is the stdin of a kubectl command with variables: the variables,
+// followed by the tool's input set with SetStdin.
+type envInput struct {
+	r io.Reader
+}
+
+func (in *envInput) Read(p []byte) (int, error) {
+	return in.r.Read(p)
+}
+
+// SetStdin makes r the tool's stdin. Commands with variables need it instead
+// of setting cmd.Stdin, since kubectl sends the variables ahead of r.
+func SetStdin(cmd *exec.Cmd, r io.Reader) {
+	if in, ok := cmd.Stdin.(*envInput); ok {
+		in.r = io.MultiReader(in.r, r)
+		return
+	}
+	cmd.Stdin = r
+}
+
+// execArgs returns the kubectl arguments that run name in the pod.
+func (k kubectl) execArgs(name string) []string {
+	kubectlArgs := []string{"exec", "-i"}
+	if k.namespace != "" {
+		kubectlArgs = append(kubectlArgs, "-n", k.namespace)
+	}
+	kubectlArgs = append(kubectlArgs, k.pod)
+	if k.container != "" {
+		kubectlArgs = append(kubectlArgs, "-c", k.container)
+	}
+	return append(kubectlArgs, "--", name)
+}
+
+func (k kubectl) LookPath(name string) error {
+	return lookPath(k, "kubectl", name)
+}
+
+func (kubectl) Local() bool {
+	return false
+}
+
+func (k kubectl) String() string {
+	target := "pod " + k.pod
+	if k.container != "" {
+		target += " container " + k.container
+	}
+	if k.namespace != "" {
+		target += " in " + k.namespace
+	}
+	return target
+}
+
+// lookPath checks that client is installed here and that name runs in the
+// container, by asking it for its version.
+func lookPath(b Backend, client, name string) error {
+	if _, err := exec.LookPath(client); err != nil {
+		return err
+	}
+	out, err := b.Command(nil, name, "--version").CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s in %s: %s", name, b, msg)
+		}
+		return fmt.Errorf("%s in %s: %w", name, b, err)
+	}
+	return nil
+}
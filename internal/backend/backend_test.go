@@ -0,0 +1,193 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// secret has the characters a shell would expand or split on.
+const secret = "it's a $ecret `x` \"y\" z=1\\"
+
+// fakeTools puts docker and kubectl stand-ins on PATH that run the command
+// after the container, here, and log their arguments to dir/args.
+func fakeTools(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	scripts := map[string]string{
+		// docker exec -i [-e NAME]... container cmd...
+		"docker": `echo "$@" >> "$FAKE_DIR/args"
+shift 2
+while [ "$1" = "-e" ]; do shift 2; done
+shift
+exec "$@"
+`,
+		// kubectl exec -i [-n ns] pod [-c container] -- cmd...
+		"kubectl": `echo "$@" >> "$FAKE_DIR/args"
+while [ "$1" != "--" ]; do shift; done
+shift
+exec "$@"
+`,
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_DIR", dir)
+	return dir
+}
+
+func TestCommandKeepsSecretsOffTheCommandLine(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"local", Config{}},
+		{"docker", Config{Kind: KindDocker, Container: "db"}},
+		{"kubectl", Config{Kind: KindKubectl, Pod: "deploy/postgres", Container: "postgres", Namespace: "data"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := fakeTools(t)
+			b, err := New(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := b.Command([]string{"PGPASSWORD=" + secret}, "sh", "-c", `printf %s "$PGPASSWORD"`)
+			for _, arg := range cmd.Args {
+				if strings.Contains(arg, "ecret") {
+					t.Errorf("secret in the arguments: %q", cmd.Args)
+				}
+			}
+
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("running %q: %v", cmd.Args, err)
+			}
+			if string(out) != secret {
+				t.Errorf("PGPASSWORD = %q, want %q", out, secret)
+			}
+
+			if logged, err := os.ReadFile(filepath.Join(dir, "args")); err == nil && strings.Contains(string(logged), "ecret") {
+				t.Errorf("secret passed to %s: %s", tt.name, logged)
+			}
+		})
+	}
+}
+
+func TestKubectlSendsVariablesAheadOfStdin(t *testing.T) {
+	dir := fakeTools(t)
+	b, err := New(Config{Kind: KindKubectl, Pod: "postgres-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := b.Command([]string{"PGPASSWORD=" + secret, "PGAPPNAME=backitup"}, "sh", "-c", `printf '%s|%s|' "$PGPASSWORD" "$PGAPPNAME"; cat`)
+	if _, err := os.Stat(filepath.Join(dir, "args")); !os.IsNotExist(err) {
+		t.Errorf("kubectl ran before the command was started: %v", err)
+	}
+
+	input := "-- dump\n\nSELECT 1;\n"
+	SetStdin(cmd, strings.NewReader(input))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running %q: %v", cmd.Args, err)
+	}
+	if want := secret + "|backitup|" + input; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestKubectlWithoutVariablesOnStdin(t *testing.T) {
+	fakeTools(t)
+	b, err := New(Config{Kind: KindKubectl, Pod: "postgres-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing the stdin loses the variables, so the tool must not run
+	cmd := b.Command([]string{"PGPASSWORD=" + secret}, "sh", "-c", "echo ran")
+	cmd.Stdin = strings.NewReader("PGPASSWORD=guess\n\n")
+	out, err := cmd.Output()
+	if err == nil || strings.Contains(string(out), "ran") {
+		t.Errorf("tool ran without the variables: %q, %v", out, err)
+	}
+}
+
+func TestKubectlRefusesLineBreaks(t *testing.T) {
+	fakeTools(t)
+	b, err := New(Config{Kind: KindKubectl, Pod: "postgres-0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := b.Command([]string{"PGPASSWORD=two\nlines"}, "true")
+	if err := cmd.Run(); err == nil || !strings.Contains(err.Error(), "line break") {
+		t.Errorf("Run() error = %v, want a line break error", err)
+	}
+}
+
+func TestSetStdin(t *testing.T) {
+	cmd := Local.Command([]string{"PGPASSWORD=x"}, "cat")
+	SetStdin(cmd, strings.NewReader("local"))
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "local" {
+		t.Errorf("output = %q, want %q", out, "local")
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		env  []string
+		want []string
+	}{
+		{
+			name: "local",
+			cfg:  Config{},
+			want: []string{"pg_dump", "-F", "c"},
+		},
+		{
+			name: "docker",
+			cfg:  Config{Kind: KindDocker, Container: "db"},
+			env:  []string{"PGPASSWORD=x"},
+			want: []string{"docker", "exec", "-i", "-e", "PGPASSWORD", "db", "pg_dump", "-F", "c"},
+		},
+		{
+			name: "kubectl",
+			cfg:  Config{Kind: KindKubectl, Pod: "deploy/postgres", Container: "postgres", Namespace: "data"},
+			want: []string{"kubectl", "exec", "-i", "-n", "data", "deploy/postgres", "-c", "postgres", "--", "pg_dump", "-F", "c"},
+		},
+		{
+			name: "kubectl with variables",
+			cfg:  Config{Kind: KindKubectl, Pod: "postgres-0"},
+			env:  []string{"PGPASSWORD=x"},
+			want: []string{"kubectl", "exec", "-i", "postgres-0", "--", "sh", "-c", readEnv, "sh", "pg_dump", "-F", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := New(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd := b.Command(tt.env, "pg_dump", "-F", "c")
+			if got := append([]string{filepath.Base(cmd.Args[0])}, cmd.Args[1:]...); !slices.Equal(got, tt.want) {
+				t.Errorf("Command() args = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,15 +2,25 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"github.com/tiyfiy/BackItUp/internal/backend"
+	"github.com/tiyfiy/BackItUp/internal/hook"
+	"github.com/tiyfiy/BackItUp/internal/tlsconf"
+	"github.com/tiyfiy/BackItUp/internal/tunnel"
 )
 
 type Config struct {
 	MongoDB    MongoDBConfig
 	PostgreSQL PostgreSQLConfig
 	MySQL      MySQLConfig
+	SQLite     SQLiteConfig
+	Redis      RedisConfig
 
 	BackupDir    string
 	Compression  bool
@@ -18,8 +28,28 @@ type Config struct {
 }
 
 type MongoDBConfig struct {
-	URI  string
-	path string
+	URI     string
+	path    string
+	Archive bool
+	Gzip    bool
+	Oplog   bool
+	Include []string
+	Exclude []string
+
+	// Dumper is "mongodump" (the default) or "native" to dump and restore
+	// through the driver without the database tools.
+	Dumper string
+	// Query limits the documents native dumps include.
+	Query string
+
+	// Exec is where mongodump and mongorestore run.
+	Exec backend.Config
+
+	// SSH is the tunnel to reach the server through, if any. Only URIs
+	// naming a single server can use one.
+	SSH tunnel.Config
+
+	Hooks hook.Config
 }
 
 type PostgreSQLConfig struct {
@@ -28,6 +58,29 @@ type PostgreSQLConfig struct {
 	User     string
 	Password string
 	Database string
+	Format   string
+	Jobs     int
+	Cluster  bool
+	Physical bool
+
+	Include     []string
+	Exclude     []string
+	ExcludeData []string
+	SchemaOnly  bool
+	DataOnly    bool
+
+	// Socket is the directory of a unix socket to connect through instead
+	// of Host.
+	Socket string
+	TLS    tlsconf.Config
+	// Params are extra libpq parameters, e.g. "connect_timeout=5".
+	Params string
+	// SSH is the tunnel to reach the server through, if any.
+	SSH tunnel.Config
+	// Exec is where pg_dump, psql and friends run.
+	Exec backend.Config
+
+	Hooks hook.Config
 }
 
 type MySQLConfig struct {
@@ -36,6 +89,50 @@ type MySQLConfig struct {
 	User     string
 	Password string
 	Database string
+	Include  []string
+	Exclude  []string
+
+	SchemaOnly bool
+	DataOnly   bool
+
+	// Dumper is "mysqldump" (the default) or "native" for the built-in
+	// dumper that needs no client tools.
+	Dumper string
+
+	// Socket is a unix socket to connect through instead of Host and Port.
+	Socket string
+	TLS    tlsconf.Config
+	// Params are extra driver parameters, e.g. "timeout=5s".
+	Params string
+	// SSH is the tunnel to reach the server through, if any.
+	SSH tunnel.Config
+	// Exec is where mysqldump and mysql run.
+	Exec backend.Config
+
+	Hooks hook.Config
+}
+
+type SQLiteConfig struct {
+	// Paths are the database files to back up.
+	Paths []string
+	// Format is "copy" (the default) for a copy of the database file or
+	// "sql" for a SQL script.
+	Format string
+
+	Hooks hook.Config
+}
+
+type RedisConfig struct {
+	Addr     string
+	User     string
+	Password string
+	// Method is "sync" (the default) to fetch snapshots over the
+	// replication protocol or "bgsave" to copy the server's dump file.
+	Method string
+	// SSH is the tunnel to reach the server through, if any.
+	SSH tunnel.Config
+
+	Hooks hook.Config
 }
 
 func init() {
@@ -53,35 +150,186 @@ func init() {
 }
 
 func Load() (*Config, error) {
+	return load(viper.GetViper()), nil
+}
+
+// LoadProfile loads a named connection profile from the profiles section of
+// config.yaml. Settings the profile doesn't set fall back to the main config.
+//
+//	profiles:
+//	  staging:
+//	    MYSQL_HOST: staging-db.internal
+//	    MYSQL_PASSWORD: secret
+func LoadProfile(name string) (*Config, error) {
+	profile := viper.Sub("profiles." + name)
+	if profile == nil {
+		return nil, fmt.Errorf("profile %q not found in config.yaml", name)
+	}
+
+	merged := viper.New()
+	for _, key := range viper.AllKeys() {
+		if !strings.HasPrefix(key, "profiles.") {
+			merged.Set(key, viper.Get(key))
+		}
+	}
+	for _, key := range profile.AllKeys() {
+		merged.Set(key, profile.Get(key))
+	}
+
+	return load(merged), nil
+}
+
+func load(v *viper.Viper) *Config {
 	cfg := &Config{
 		MongoDB: MongoDBConfig{
-			URI:  getEnvOrDefault("mongodb.uri", "mongodb://localhost:27017"),
-			path: getEnvOrDefault("mongodb.path", ""),
+			URI:     getEnvOrDefault(v, "mongodb.uri", "mongodb://localhost:27017"),
+			path:    getEnvOrDefault(v, "mongodb.path", ""),
+			Archive: v.GetBool("mongodb.archive"),
+			Gzip:    v.GetBool("mongodb.gzip"),
+			Oplog:   v.GetBool("mongodb.oplog"),
+			Include: v.GetStringSlice("mongodb.include"),
+			Exclude: v.GetStringSlice("mongodb.exclude"),
+			Dumper:  getEnvOrDefault(v, "mongodb.dumper", "mongodump"),
+			Query:   getEnvOrDefault(v, "mongodb.query", ""),
+			Exec: backend.Config{
+				Kind:      getEnvOrDefault(v, "mongodb.exec", backend.KindLocal),
+				Container: getEnvOrDefault(v, "mongodb.exec_container", ""),
+				Pod:       getEnvOrDefault(v, "mongodb.exec_pod", ""),
+				Namespace: getEnvOrDefault(v, "mongodb.exec_namespace", ""),
+			},
+			SSH: tunnel.Config{
+				Host:       getEnvOrDefault(v, "mongodb.ssh_host", ""),
+				User:       getEnvOrDefault(v, "mongodb.ssh_user", ""),
+				Key:        getEnvOrDefault(v, "mongodb.ssh_key", ""),
+				KnownHosts: getEnvOrDefault(v, "mongodb.ssh_known_hosts", ""),
+				Jump:       v.GetStringSlice("mongodb.ssh_jump"),
+			},
+			Hooks: hooksConfig(v, "mongodb.hook_"),
 		},
 		PostgreSQL: PostgreSQLConfig{
-			Host:     getEnvOrDefault("POSTGRES_HOST", "localhost"),
-			Port:     getEnvOrDefault("POSTGRES_PORT", "5432"),
-			User:     getEnvOrDefault("POSTGRES_USER", "postgres"),
-			Password: getEnvOrDefault("POSTGRES_PASSWORD", ""),
-			Database: getEnvOrDefault("POSTGRES_DB", ""),
+			Host:     getEnvOrDefault(v, "POSTGRES_HOST", "localhost"),
+			Port:     getEnvOrDefault(v, "POSTGRES_PORT", "5432"),
+			User:     getEnvOrDefault(v, "POSTGRES_USER", "postgres"),
+			Password: getEnvOrDefault(v, "POSTGRES_PASSWORD", ""),
+			Database: getEnvOrDefault(v, "POSTGRES_DB", ""),
+			Format:   getEnvOrDefault(v, "POSTGRES_FORMAT", "plain"),
+			Jobs:     getIntOrDefault(v, "POSTGRES_JOBS", 1),
+			Cluster:  v.GetBool("POSTGRES_CLUSTER"),
+			Physical: v.GetBool("POSTGRES_PHYSICAL"),
+
+			Include:     v.GetStringSlice("POSTGRES_INCLUDE"),
+			Exclude:     v.GetStringSlice("POSTGRES_EXCLUDE"),
+			ExcludeData: v.GetStringSlice("POSTGRES_EXCLUDE_DATA"),
+			SchemaOnly:  v.GetBool("POSTGRES_SCHEMA_ONLY"),
+			DataOnly:    v.GetBool("POSTGRES_DATA_ONLY"),
+
+			Socket: getEnvOrDefault(v, "POSTGRES_SOCKET", ""),
+			TLS:    tlsConfig(v, "POSTGRES"),
+			Params: getEnvOrDefault(v, "POSTGRES_PARAMS", ""),
+			SSH:    sshConfig(v, "POSTGRES"),
+			Exec:   execConfig(v, "POSTGRES"),
+			Hooks:  hooksConfig(v, "POSTGRES_HOOK_"),
 		},
 		MySQL: MySQLConfig{
-			Host:     getEnvOrDefault("MYSQL_HOST", "localhost"),
-			Port:     getEnvOrDefault("MYSQL_PORT", "3306"),
-			User:     getEnvOrDefault("MYSQL_USER", "root"),
-			Password: getEnvOrDefault("MYSQL_PASSWORD", ""),
-			Database: getEnvOrDefault("MYSQL_DB", ""),
+			Host:     getEnvOrDefault(v, "MYSQL_HOST", "localhost"),
+			Port:     getEnvOrDefault(v, "MYSQL_PORT", "3306"),
+			User:     getEnvOrDefault(v, "MYSQL_USER", "root"),
+			Password: getEnvOrDefault(v, "MYSQL_PASSWORD", ""),
+			Database: getEnvOrDefault(v, "MYSQL_DB", ""),
+			Include:  v.GetStringSlice("MYSQL_INCLUDE"),
+			Exclude:  v.GetStringSlice("MYSQL_EXCLUDE"),
+
+			SchemaOnly: v.GetBool("MYSQL_SCHEMA_ONLY"),
+			DataOnly:   v.GetBool("MYSQL_DATA_ONLY"),
+			Dumper:     getEnvOrDefault(v, "MYSQL_DUMPER", "mysqldump"),
+
+			Socket: getEnvOrDefault(v, "MYSQL_SOCKET", ""),
+			TLS:    tlsConfig(v, "MYSQL"),
+			Params: getEnvOrDefault(v, "MYSQL_PARAMS", ""),
+			SSH:    sshConfig(v, "MYSQL"),
+			Exec:   execConfig(v, "MYSQL"),
+			Hooks:  hooksConfig(v, "MYSQL_HOOK_"),
+		},
+		SQLite: SQLiteConfig{
+			Paths:  v.GetStringSlice("SQLITE_PATHS"),
+			Format: getEnvOrDefault(v, "SQLITE_FORMAT", "copy"),
+			Hooks:  hooksConfig(v, "SQLITE_HOOK_"),
 		},
-		BackupDir:    getEnvOrDefault("BACKUP_DIR", "./backups"),
-		Compression:  getEnvOrDefault("COMPRESSION", "true") == "true",
-		SlackWebhook: getEnvOrDefault("SLACK_WEBHOOK_URL", ""),
+		Redis: RedisConfig{
+			Addr:     getEnvOrDefault(v, "REDIS_ADDR", "localhost:6379"),
+			User:     getEnvOrDefault(v, "REDIS_USER", ""),
+			Password: getEnvOrDefault(v, "REDIS_PASSWORD", ""),
+			Method:   getEnvOrDefault(v, "REDIS_METHOD", "sync"),
+			SSH:      sshConfig(v, "REDIS"),
+			Hooks:    hooksConfig(v, "REDIS_HOOK_"),
+		},
+		BackupDir:    getEnvOrDefault(v, "BACKUP_DIR", "./backups"),
+		Compression:  getEnvOrDefault(v, "COMPRESSION", "true") == "true",
+		SlackWebhook: getEnvOrDefault(v, "SLACK_WEBHOOK_URL", os.Getenv("SLACK_WEBHOOK_URL")),
+	}
+
+	return cfg
+}
+
+func getEnvOrDefault(v *viper.Viper, key string, defaultValue string) string {
+	if value := v.GetString(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// tlsConfig reads the <prefix>_TLS_* settings.
+func tlsConfig(v *viper.Viper, prefix string) tlsconf.Config {
+	return tlsconf.Config{
+		Mode:       getEnvOrDefault(v, prefix+"_TLS_MODE", ""),
+		CA:         getEnvOrDefault(v, prefix+"_TLS_CA", ""),
+		Cert:       getEnvOrDefault(v, prefix+"_TLS_CERT", ""),
+		Key:        getEnvOrDefault(v, prefix+"_TLS_KEY", ""),
+		ServerName: getEnvOrDefault(v, prefix+"_TLS_SERVER_NAME", ""),
+	}
+}
+
+// sshConfig reads the <prefix>_SSH_* settings.
+func sshConfig(v *viper.Viper, prefix string) tunnel.Config {
+	return tunnel.Config{
+		Host:       getEnvOrDefault(v, prefix+"_SSH_HOST", ""),
+		User:       getEnvOrDefault(v, prefix+"_SSH_USER", ""),
+		Key:        getEnvOrDefault(v, prefix+"_SSH_KEY", ""),
+		KnownHosts: getEnvOrDefault(v, prefix+"_SSH_KNOWN_HOSTS", ""),
+		Jump:       v.GetStringSlice(prefix + "_SSH_JUMP"),
+	}
+}
+
+// execConfig reads the <prefix>_EXEC* settings.
+func execConfig(v *viper.Viper, prefix string) backend.Config {
+	return backend.Config{
+		Kind:      getEnvOrDefault(v, prefix+"_EXEC", backend.KindLocal),
+		Container: getEnvOrDefault(v, prefix+"_EXEC_CONTAINER", ""),
+		Pod:       getEnvOrDefault(v, prefix+"_EXEC_POD", ""),
+		Namespace: getEnvOrDefault(v, prefix+"_EXEC_NAMESPACE", ""),
+	}
+}
+
+// hooksConfig reads the hook settings, keyed prefix + e.g. PRE_BACKUP.
+func hooksConfig(v *viper.Viper, prefix string) hook.Config {
+	return hook.Config{
+		PreBackup:   v.GetStringSlice(hookKey(prefix, hook.PreBackup)),
+		PostBackup:  v.GetStringSlice(hookKey(prefix, hook.PostBackup)),
+		OnFailure:   v.GetStringSlice(hookKey(prefix, hook.OnFailure)),
+		PreRestore:  v.GetStringSlice(hookKey(prefix, hook.PreRestore)),
+		PostRestore: v.GetStringSlice(hookKey(prefix, hook.PostRestore)),
+		Timeout:     time.Duration(v.GetInt(prefix+"TIMEOUT")) * time.Second,
+		PreFailure:  getEnvOrDefault(v, prefix+"PRE_FAILURE", hook.PolicyAbort),
 	}
+}
 
-	return cfg, nil
+// hookKey returns the key of an event's hooks, e.g. MYSQL_HOOK_PRE_BACKUP.
+func hookKey(prefix, event string) string {
+	return prefix + strings.ToUpper(strings.ReplaceAll(event, "-", "_"))
 }
 
-func getEnvOrDefault(key string, defaultValue string) string {
-	if value := viper.GetString(key); value != "" {
+func getIntOrDefault(v *viper.Viper, key string, defaultValue int) int {
+	if value := v.GetInt(key); value != 0 {
 		return value
 	}
 	return defaultValue
@@ -111,6 +359,66 @@ func SetMongodbPath(path string) {
 	}
 }
 
+func SetMongodbArchive(archive bool) {
+	viper.Set("mongodb.archive", archive)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbGzip(gzip bool) {
+	viper.Set("mongodb.gzip", gzip)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbOplog(oplog bool) {
+	viper.Set("mongodb.oplog", oplog)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbDumper(dumper string) {
+	viper.Set("mongodb.dumper", dumper)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbQuery(query string) {
+	viper.Set("mongodb.query", query)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
 func SetMySQLHost(host string) {
 	viper.Set("MYSQL_HOST", host)
 
@@ -230,3 +538,999 @@ func SetPostgreSQLDatabase(database string) {
 		}
 	}
 }
+
+func SetPostgreSQLFormat(format string) {
+	viper.Set("POSTGRES_FORMAT", format)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLJobs(jobs int) {
+	viper.Set("POSTGRES_JOBS", jobs)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLCluster(cluster bool) {
+	viper.Set("POSTGRES_CLUSTER", cluster)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLPhysical(physical bool) {
+	viper.Set("POSTGRES_PHYSICAL", physical)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbInclude(patterns []string) {
+	viper.Set("mongodb.include", patterns)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbExclude(patterns []string) {
+	viper.Set("mongodb.exclude", patterns)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLInclude(patterns []string) {
+	viper.Set("MYSQL_INCLUDE", patterns)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLExclude(patterns []string) {
+	viper.Set("MYSQL_EXCLUDE", patterns)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLInclude(patterns []string) {
+	viper.Set("POSTGRES_INCLUDE", patterns)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLExclude(patterns []string) {
+	viper.Set("POSTGRES_EXCLUDE", patterns)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLExcludeData(patterns []string) {
+	viper.Set("POSTGRES_EXCLUDE_DATA", patterns)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLSchemaOnly(enabled bool) {
+	viper.Set("MYSQL_SCHEMA_ONLY", enabled)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLDataOnly(enabled bool) {
+	viper.Set("MYSQL_DATA_ONLY", enabled)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLDumper(dumper string) {
+	viper.Set("MYSQL_DUMPER", dumper)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLSchemaOnly(enabled bool) {
+	viper.Set("POSTGRES_SCHEMA_ONLY", enabled)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLDataOnly(enabled bool) {
+	viper.Set("POSTGRES_DATA_ONLY", enabled)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetSQLitePaths(paths []string) {
+	viper.Set("SQLITE_PATHS", paths)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetSQLiteFormat(format string) {
+	viper.Set("SQLITE_FORMAT", format)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisAddr(addr string) {
+	viper.Set("REDIS_ADDR", addr)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisUser(user string) {
+	viper.Set("REDIS_USER", user)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisPassword(password string) {
+	viper.Set("REDIS_PASSWORD", password)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisMethod(method string) {
+	viper.Set("REDIS_METHOD", method)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLSocket(socket string) {
+	viper.Set("MYSQL_SOCKET", socket)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLTLSMode(mode string) {
+	viper.Set("MYSQL_TLS_MODE", mode)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLTLSCA(path string) {
+	viper.Set("MYSQL_TLS_CA", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLTLSCert(path string) {
+	viper.Set("MYSQL_TLS_CERT", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLTLSKey(path string) {
+	viper.Set("MYSQL_TLS_KEY", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLTLSServerName(name string) {
+	viper.Set("MYSQL_TLS_SERVER_NAME", name)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLParams(params string) {
+	viper.Set("MYSQL_PARAMS", params)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLSocket(socket string) {
+	viper.Set("POSTGRES_SOCKET", socket)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLTLSMode(mode string) {
+	viper.Set("POSTGRES_TLS_MODE", mode)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLTLSCA(path string) {
+	viper.Set("POSTGRES_TLS_CA", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLTLSCert(path string) {
+	viper.Set("POSTGRES_TLS_CERT", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLTLSKey(path string) {
+	viper.Set("POSTGRES_TLS_KEY", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLTLSServerName(name string) {
+	viper.Set("POSTGRES_TLS_SERVER_NAME", name)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLParams(params string) {
+	viper.Set("POSTGRES_PARAMS", params)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLSSHHost(host string) {
+	viper.Set("MYSQL_SSH_HOST", host)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLSSHUser(user string) {
+	viper.Set("MYSQL_SSH_USER", user)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLSSHKey(path string) {
+	viper.Set("MYSQL_SSH_KEY", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLSSHKnownHosts(path string) {
+	viper.Set("MYSQL_SSH_KNOWN_HOSTS", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLSSHJump(hosts []string) {
+	viper.Set("MYSQL_SSH_JUMP", hosts)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLSSHHost(host string) {
+	viper.Set("POSTGRES_SSH_HOST", host)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLSSHUser(user string) {
+	viper.Set("POSTGRES_SSH_USER", user)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLSSHKey(path string) {
+	viper.Set("POSTGRES_SSH_KEY", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLSSHKnownHosts(path string) {
+	viper.Set("POSTGRES_SSH_KNOWN_HOSTS", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLSSHJump(hosts []string) {
+	viper.Set("POSTGRES_SSH_JUMP", hosts)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisSSHHost(host string) {
+	viper.Set("REDIS_SSH_HOST", host)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisSSHUser(user string) {
+	viper.Set("REDIS_SSH_USER", user)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisSSHKey(path string) {
+	viper.Set("REDIS_SSH_KEY", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisSSHKnownHosts(path string) {
+	viper.Set("REDIS_SSH_KNOWN_HOSTS", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisSSHJump(hosts []string) {
+	viper.Set("REDIS_SSH_JUMP", hosts)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbExec(kind string) {
+	viper.Set("mongodb.exec", kind)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbExecContainer(container string) {
+	viper.Set("mongodb.exec_container", container)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbExecPod(pod string) {
+	viper.Set("mongodb.exec_pod", pod)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbExecNamespace(namespace string) {
+	viper.Set("mongodb.exec_namespace", namespace)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbSSHHost(host string) {
+	viper.Set("mongodb.ssh_host", host)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbSSHUser(user string) {
+	viper.Set("mongodb.ssh_user", user)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbSSHKey(path string) {
+	viper.Set("mongodb.ssh_key", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbSSHKnownHosts(path string) {
+	viper.Set("mongodb.ssh_known_hosts", path)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbSSHJump(hosts []string) {
+	viper.Set("mongodb.ssh_jump", hosts)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLExec(kind string) {
+	viper.Set("MYSQL_EXEC", kind)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLExecContainer(container string) {
+	viper.Set("MYSQL_EXEC_CONTAINER", container)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLExecPod(pod string) {
+	viper.Set("MYSQL_EXEC_POD", pod)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLExecNamespace(namespace string) {
+	viper.Set("MYSQL_EXEC_NAMESPACE", namespace)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLExec(kind string) {
+	viper.Set("POSTGRES_EXEC", kind)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLExecContainer(container string) {
+	viper.Set("POSTGRES_EXEC_CONTAINER", container)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLExecPod(pod string) {
+	viper.Set("POSTGRES_EXEC_POD", pod)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLExecNamespace(namespace string) {
+	viper.Set("POSTGRES_EXEC_NAMESPACE", namespace)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbHooks(event string, hooks []string) {
+	viper.Set(hookKey("mongodb.hook_", event), hooks)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbHookTimeout(seconds int) {
+	viper.Set("mongodb.hook_TIMEOUT", seconds)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMongodbHookPreFailure(policy string) {
+	viper.Set("mongodb.hook_PRE_FAILURE", policy)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLHooks(event string, hooks []string) {
+	viper.Set(hookKey("MYSQL_HOOK_", event), hooks)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLHookTimeout(seconds int) {
+	viper.Set("MYSQL_HOOK_TIMEOUT", seconds)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetMySQLHookPreFailure(policy string) {
+	viper.Set("MYSQL_HOOK_PRE_FAILURE", policy)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLHooks(event string, hooks []string) {
+	viper.Set(hookKey("POSTGRES_HOOK_", event), hooks)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLHookTimeout(seconds int) {
+	viper.Set("POSTGRES_HOOK_TIMEOUT", seconds)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetPostgreSQLHookPreFailure(policy string) {
+	viper.Set("POSTGRES_HOOK_PRE_FAILURE", policy)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetSQLiteHooks(event string, hooks []string) {
+	viper.Set(hookKey("SQLITE_HOOK_", event), hooks)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetSQLiteHookTimeout(seconds int) {
+	viper.Set("SQLITE_HOOK_TIMEOUT", seconds)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetSQLiteHookPreFailure(policy string) {
+	viper.Set("SQLITE_HOOK_PRE_FAILURE", policy)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisHooks(event string, hooks []string) {
+	viper.Set(hookKey("REDIS_HOOK_", event), hooks)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisHookTimeout(seconds int) {
+	viper.Set("REDIS_HOOK_TIMEOUT", seconds)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
+
+func SetRedisHookPreFailure(policy string) {
+	viper.Set("REDIS_HOOK_PRE_FAILURE", policy)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		err = viper.SafeWriteConfig()
+		if err != nil {
+			log.Fatal("Failed to save config:", err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package history records every backup and restore run, successful or not,
+// in a JSON lines file next to the backups.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// File is where runs are recorded.
+const File = "BACKUP/history.jsonl"
+
+// Run statuses.
+const (
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// Run is one backup or restore.
+type Run struct {
+	Operation string    `json:"operation"`
+	Engine    string    `json:"engine"`
+	Database  string    `json:"database,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  float64   `json:"duration_seconds"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	// Stderr is the tail of the failed tool's stderr.
+	Stderr string `json:"stderr,omitempty"`
+	// Hint explains a recognized failure.
+	Hint string `json:"hint,omitempty"`
+	// Hooks are the hooks that ran before and after.
+	Hooks []Hook `json:"hooks,omitempty"`
+}
+
+// Hook is one hook run around a backup or restore.
+type Hook struct {
+	Event    string  `json:"event"`
+	Hook     string  `json:"hook"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Append adds a run to the history.
+func Append(run Run) error {
+	if err := os.MkdirAll(filepath.Dir(File), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Load returns the recorded runs, oldest first. Lines that can't be parsed
+// are skipped.
+func Load() ([]Run, error) {
+	f, err := os.Open(File)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err == nil {
+			runs = append(runs, run)
+		}
+	}
+	return runs, scanner.Err()
+}
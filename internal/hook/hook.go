@@ -0,0 +1,269 @@
+// Package hook runs the commands and HTTP calls configured around backups
+// and restores, e.g. to stop a cron job before a dump or push a marker
+// after it.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/history"
+	"github.com/tiyfiy/BackItUp/internal/tool"
+)
+
+// Events hooks run on.
+const (
+	PreBackup   = "pre-backup"
+	PostBackup  = "post-backup"
+	OnFailure   = "on-failure"
+	PreRestore  = "pre-restore"
+	PostRestore = "post-restore"
+)
+
+// Events lists every event, in the order they can happen.
+var Events = []string{PreBackup, PostBackup, OnFailure, PreRestore, PostRestore}
+
+// Policies for a failed pre-backup or pre-restore hook.
+const (
+	PolicyAbort    = "abort"
+	PolicyContinue = "continue"
+)
+
+// DefaultTimeout bounds a hook without a configured timeout.
+const DefaultTimeout = 60 * time.Second
+
+// ValidPolicy reports whether policy is a known pre-hook failure policy.
+// An empty policy aborts.
+func ValidPolicy(policy string) bool {
+	return policy == "" || policy == PolicyAbort || policy == PolicyContinue
+}
+
+// Config holds the hooks of one target. Each hook is a shell command, or
+// an http:// or https:// URL that gets the run as JSON, POSTed unless the
+// URL is prefixed with another method ("PUT https://...").
+type Config struct {
+	PreBackup   []string
+	PostBackup  []string
+	OnFailure   []string
+	PreRestore  []string
+	PostRestore []string
+
+	// Timeout bounds each hook, DefaultTimeout when zero.
+	Timeout time.Duration
+	// PreFailure is abort (the default) to cancel the backup or restore
+	// when a pre hook fails, or continue to go ahead anyway.
+	PreFailure string
+}
+
+// For returns the hooks of an event.
+func (c Config) For(event string) []string {
+	switch event {
+	case PreBackup:
+		return c.PreBackup
+	case PostBackup:
+		return c.PostBackup
+	case OnFailure:
+		return c.OnFailure
+	case PreRestore:
+		return c.PreRestore
+	case PostRestore:
+		return c.PostRestore
+	}
+	return nil
+}
+
+// Aborts reports whether a failed hook of event cancels the run.
+func (c Config) Aborts(event string) bool {
+	return (event == PreBackup || event == PreRestore) && c.PreFailure != PolicyContinue
+}
+
+// Error is a failed hook that cancelled the run.
+type Error struct {
+	Event string
+	Hook  string
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s hook %q failed: %v", e.Event, e.Hook, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Env describes the run to the hooks. Shell hooks get it as BACKITUP_*
+// variables, HTTP hooks as the JSON body.
+type Env struct {
+	Event     string `json:"event"`
+	Operation string `json:"operation"`
+	Target    string `json:"target"`
+	Engine    string `json:"engine"`
+	Database  string `json:"database,omitempty"`
+	Path      string `json:"backup_path,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+	// Status and Error are only set after the run.
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (e Env) vars() []string {
+	return []string{
+		"BACKITUP_EVENT=" + e.Event,
+		"BACKITUP_OPERATION=" + e.Operation,
+		"BACKITUP_TARGET=" + e.Target,
+		"BACKITUP_ENGINE=" + e.Engine,
+		"BACKITUP_DATABASE=" + e.Database,
+		"BACKITUP_BACKUP_PATH=" + e.Path,
+		"BACKITUP_SIZE=" + strconv.FormatInt(e.Size, 10),
+		"BACKITUP_STATUS=" + e.Status,
+		"BACKITUP_ERROR=" + e.Error,
+	}
+}
+
+// Run runs the hooks of env.Event in order and returns their results. err
+// is an *Error for the first failure of a hook that aborts the run, after
+// which the remaining hooks are skipped; other failures are only in the
+// results.
+func Run(cfg Config, env Env) (results []history.Hook, err error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	for _, h := range cfg.For(env.Event) {
+		fmt.Printf("🪝 Running %s hook: %s\n", env.Event, describe(h))
+
+		start := time.Now()
+		hookErr := run(h, env, timeout)
+		result := history.Hook{
+			Event:    env.Event,
+			Hook:     describe(h),
+			Status:   history.StatusSuccess,
+			Duration: time.Since(start).Seconds(),
+		}
+		if hookErr != nil {
+			result.Status = history.StatusFailed
+			result.Error = hookErr.Error()
+			fmt.Printf("   ❌ %s hook failed: %v\n", env.Event, hookErr)
+		}
+		results = append(results, result)
+
+		if hookErr != nil && cfg.Aborts(env.Event) {
+			return results, &Error{Event: env.Event, Hook: describe(h), Err: hookErr}
+		}
+	}
+	return results, nil
+}
+
+func run(h string, env Env, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var err error
+	if method, rawURL, ok := httpHook(h); ok {
+		err = call(ctx, method, rawURL, env)
+	} else {
+		err = command(ctx, h, env)
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// command runs a shell hook, its output going to ours.
+func command(ctx context.Context, h string, env Env) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", h)
+	cmd.Env = append(os.Environ(), env.vars()...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	killGroup(cmd)
+	// Don't wait on children that escaped the process group
+	cmd.WaitDelay = time.Second
+	output := tool.Capture(cmd)
+
+	if err := cmd.Run(); err != nil {
+		if stderr := output.String(); stderr != "" {
+			lines := strings.Split(stderr, "\n")
+			return fmt.Errorf("%w: %s", err, lines[len(lines)-1])
+		}
+		return err
+	}
+	return nil
+}
+
+// call sends the run to an HTTP hook.
+func call(ctx context.Context, method, rawURL string, env Env) error {
+	var body []byte
+	if method != http.MethodGet {
+		var err error
+		body, err = json.Marshal(env)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		// Without the URL, which may carry a token
+		return urlErr.Err
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", method, resp.Status)
+	}
+	return nil
+}
+
+// httpHook splits an HTTP hook into its method and URL. ok is false for
+// shell hooks.
+func httpHook(h string) (method, rawURL string, ok bool) {
+	method, rawURL = http.MethodPost, strings.TrimSpace(h)
+	if m, rest, found := strings.Cut(rawURL, " "); found && m == strings.ToUpper(m) {
+		method, rawURL = m, strings.TrimSpace(rest)
+	}
+	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+		return "", "", false
+	}
+	return method, rawURL, true
+}
+
+// describe names a hook for output and the run history. URLs lose their
+// credentials and query, which often carry tokens.
+func describe(h string) string {
+	method, rawURL, ok := httpHook(h)
+	if !ok {
+		return h
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return method + " " + rawURL
+	}
+	u.User, u.RawQuery, u.Fragment = nil, "", ""
+	return method + " " + u.String()
+}
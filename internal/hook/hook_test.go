@@ -0,0 +1,303 @@
+package hook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/history"
+)
+
+func requireShell(t *testing.T) {
+	t.Helper()
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+}
+
+func statuses(results []history.Hook) []string {
+	var got []string
+	for _, result := range results {
+		got = append(got, result.Status)
+	}
+	return got
+}
+
+func TestRunEnvironment(t *testing.T) {
+	requireShell(t)
+	out := filepath.Join(t.TempDir(), "env")
+
+	cfg := Config{PostBackup: []string{`env | grep '^BACKITUP_' | sort > "` + out + `"`}}
+	env := Env{
+		Event:     PostBackup,
+		Operation: "backup",
+		Target:    "PostgreSQL shop",
+		Engine:    "postgresql",
+		Database:  "shop",
+		Path:      "BACKUP/postgresql/shop_2026-10-17_14-00-00.dump",
+		Size:      1048576,
+		Status:    history.StatusFailed,
+		Error:     `pg_dump failed: "quoted" $HOME`,
+	}
+	if _, err := Run(cfg, env); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"BACKITUP_BACKUP_PATH=BACKUP/postgresql/shop_2026-10-17_14-00-00.dump",
+		"BACKITUP_DATABASE=shop",
+		"BACKITUP_ENGINE=postgresql",
+		`BACKITUP_ERROR=pg_dump failed: "quoted" $HOME`,
+		"BACKITUP_EVENT=post-backup",
+		"BACKITUP_OPERATION=backup",
+		"BACKITUP_SIZE=1048576",
+		"BACKITUP_STATUS=failed",
+		"BACKITUP_TARGET=PostgreSQL shop",
+	}
+	if got := strings.Split(strings.TrimSpace(string(data)), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("hook environment:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	requireShell(t)
+
+	tests := []struct {
+		name string
+		hook string
+	}{
+		{"command", "sleep 30; touch %s"},
+		// The background command would outlive the shell, holding its
+		// output open
+		{"background command", "(sleep 0.3; touch %s) & sleep 30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			marker := filepath.Join(t.TempDir(), "finished")
+			cfg := Config{PreBackup: []string{strings.Replace(tt.hook, "%s", marker, 1)}, Timeout: 200 * time.Millisecond}
+
+			start := time.Now()
+			results, err := Run(cfg, Env{Event: PreBackup})
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("the hook was stopped after %s", elapsed)
+			}
+
+			var hookErr *Error
+			if !errors.As(err, &hookErr) || !strings.Contains(err.Error(), "timed out after 200ms") {
+				t.Errorf("Run() error = %v, want a timeout", err)
+			}
+			if len(results) != 1 || results[0].Status != history.StatusFailed {
+				t.Errorf("results = %+v, want one failed hook", results)
+			}
+
+			time.Sleep(600 * time.Millisecond)
+			if _, err := os.Stat(marker); !os.IsNotExist(err) {
+				t.Error("the hook kept running after its timeout")
+			}
+		})
+	}
+}
+
+func TestRunFailurePolicy(t *testing.T) {
+	requireShell(t)
+
+	tests := []struct {
+		name       string
+		event      string
+		policy     string
+		wantAbort  bool
+		wantStatus []string
+	}{
+		{"pre-backup aborts by default", PreBackup, "", true, []string{history.StatusFailed}},
+		{"pre-restore aborts", PreRestore, PolicyAbort, true, []string{history.StatusFailed}},
+		{"pre-backup continues", PreBackup, PolicyContinue, false, []string{history.StatusFailed, history.StatusSuccess}},
+		{"post-backup never aborts", PostBackup, PolicyAbort, false, []string{history.StatusFailed, history.StatusSuccess}},
+		{"on-failure never aborts", OnFailure, "", false, []string{history.StatusFailed, history.StatusSuccess}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			marker := filepath.Join(t.TempDir(), "second")
+			hooks := []string{"echo 'no disk' >&2; exit 3", "touch " + marker}
+			cfg := Config{PreFailure: tt.policy}
+			switch tt.event {
+			case PreBackup:
+				cfg.PreBackup = hooks
+			case PreRestore:
+				cfg.PreRestore = hooks
+			case PostBackup:
+				cfg.PostBackup = hooks
+			case OnFailure:
+				cfg.OnFailure = hooks
+			}
+
+			results, err := Run(cfg, Env{Event: tt.event})
+			if got := statuses(results); !reflect.DeepEqual(got, tt.wantStatus) {
+				t.Errorf("statuses = %v, want %v", got, tt.wantStatus)
+			}
+			if !strings.Contains(results[0].Error, "exit status 3: no disk") {
+				t.Errorf("hook error = %q, want the exit status and stderr", results[0].Error)
+			}
+
+			_, statErr := os.Stat(marker)
+			if tt.wantAbort {
+				var hookErr *Error
+				if !errors.As(err, &hookErr) || hookErr.Event != tt.event {
+					t.Errorf("Run() error = %v, want an abort", err)
+				}
+				if statErr == nil {
+					t.Error("the hook after the failed one ran")
+				}
+			} else {
+				if err != nil {
+					t.Errorf("Run() error = %v, want none", err)
+				}
+				if statErr != nil {
+					t.Error("the hook after the failed one didn't run")
+				}
+			}
+		})
+	}
+}
+
+// request is what an HTTP hook sent.
+type request struct {
+	method, contentType string
+	env                 *Env
+}
+
+func hookServer(t *testing.T, status int) (*httptest.Server, chan request) {
+	t.Helper()
+
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{method: r.Method, contentType: r.Header.Get("Content-Type")}
+		if body, _ := io.ReadAll(r.Body); len(body) > 0 {
+			req.env = &Env{}
+			if err := json.Unmarshal(body, req.env); err != nil {
+				t.Errorf("hook body %q: %v", body, err)
+			}
+		}
+		requests <- req
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestRunHTTP(t *testing.T) {
+	env := Env{Event: PostRestore, Operation: "restore", Target: "MySQL shop", Engine: "mysql", Database: "shop", Status: history.StatusSuccess}
+
+	t.Run("POST", func(t *testing.T) {
+		server, requests := hookServer(t, http.StatusNoContent)
+		results, err := Run(Config{PostRestore: []string{server.URL + "/hook?token=abc"}}, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := <-requests
+		if req.method != http.MethodPost || req.contentType != "application/json" {
+			t.Errorf("sent %s with %q, want a JSON POST", req.method, req.contentType)
+		}
+		if req.env == nil || *req.env != env {
+			t.Errorf("sent %+v, want %+v", req.env, env)
+		}
+		if want := "POST " + server.URL + "/hook"; results[0].Hook != want {
+			t.Errorf("hook recorded as %q, want %q without the token", results[0].Hook, want)
+		}
+	})
+
+	t.Run("other methods", func(t *testing.T) {
+		server, requests := hookServer(t, http.StatusOK)
+		_, err := Run(Config{PostRestore: []string{"PUT " + server.URL, "GET " + server.URL}}, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if req := <-requests; req.method != http.MethodPut || req.env == nil {
+			t.Errorf("first hook sent %s with %+v, want a PUT with the run", req.method, req.env)
+		}
+		if req := <-requests; req.method != http.MethodGet || req.env != nil || req.contentType != "" {
+			t.Errorf("second hook sent %s with %+v, want a GET without a body", req.method, req.env)
+		}
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		server, _ := hookServer(t, http.StatusInternalServerError)
+		results, err := Run(Config{PreRestore: []string{server.URL}}, Env{Event: PreRestore})
+		if err == nil || !strings.Contains(err.Error(), "POST returned 500 Internal Server Error") {
+			t.Errorf("Run() error = %v, want the status", err)
+		}
+		if got := statuses(results); !reflect.DeepEqual(got, []string{history.StatusFailed}) {
+			t.Errorf("statuses = %v", got)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		// The request is only cancelled once its body has been read
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+		}))
+		t.Cleanup(server.Close)
+
+		start := time.Now()
+		_, err := Run(Config{PreBackup: []string{server.URL}, Timeout: 200 * time.Millisecond}, Env{Event: PreBackup})
+		if err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+			t.Errorf("Run() error = %v, want a timeout", err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("the call was stopped after %s", elapsed)
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		server, _ := hookServer(t, http.StatusOK)
+		rawURL := strings.Replace(server.URL, "http://", "http://user:secret@", 1) + "/?token=abc"
+		server.Close()
+
+		results, _ := Run(Config{PostBackup: []string{rawURL}}, Env{Event: PostBackup})
+		if len(results) != 1 || results[0].Status != history.StatusFailed {
+			t.Fatalf("results = %+v, want one failed hook", results)
+		}
+		if strings.Contains(results[0].Error, "secret") || strings.Contains(results[0].Error, "abc") {
+			t.Errorf("the error leaks the URL's credentials: %s", results[0].Error)
+		}
+	})
+}
+
+func TestHTTPHook(t *testing.T) {
+	tests := []struct {
+		hook           string
+		method, rawURL string
+		ok             bool
+	}{
+		{"https://hooks.example.com/x", "POST", "https://hooks.example.com/x", true},
+		{"  PUT   http://hooks.example.com/x ", "PUT", "http://hooks.example.com/x", true},
+		{"GET https://hooks.example.com/x", "GET", "https://hooks.example.com/x", true},
+		{"curl https://hooks.example.com/x", "", "", false},
+		{"systemctl stop shop-cron", "", "", false},
+	}
+
+	for _, tt := range tests {
+		method, rawURL, ok := httpHook(tt.hook)
+		if method != tt.method || rawURL != tt.rawURL || ok != tt.ok {
+			t.Errorf("httpHook(%q) = %q, %q, %v, want %q, %q, %v", tt.hook, method, rawURL, ok, tt.method, tt.rawURL, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd)
+
+package hook
+
+import "os/exec"
+
+// killGroup only kills the shell on this platform; WaitDelay stops waiting
+// for the commands it started.
+func killGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+
+package hook
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killGroup makes cmd run in its own process group and kills the whole
+// group when its context ends, so commands the shell started stop too.
+func killGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,153 @@
+// Package manifest records metadata about a backup in a JSON file stored next to it.
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Backup kinds.
+const (
+	KindFull   = "full"
+	KindSchema = "schema"
+	KindData   = "data"
+)
+
+// Suffix is appended to a backup's path to get its manifest path.
+const Suffix = ".manifest.json"
+
+type Manifest struct {
+	Engine    string    `json:"engine"`
+	Database  string    `json:"database,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Source is the file a SQLite backup was taken from.
+	Source string `json:"source,omitempty"`
+	// SHA256 is the checksum of the backup file, where the engine records
+	// one.
+	SHA256 string `json:"sha256,omitempty"`
+	// Kind is KindFull, KindSchema or KindData. Older manifests have none,
+	// which means full.
+	Kind string `json:"kind,omitempty"`
+
+	// Flavor is "mysql" or "mariadb" for MySQL backups, so they are
+	// restored with matching client tools. Older manifests have none.
+	Flavor string `json:"flavor,omitempty"`
+
+	// Binlog is the MySQL binary log position the dump is consistent with.
+	Binlog *BinlogPosition `json:"binlog,omitempty"`
+	// Oplog is set when a MongoDB dump captured the oplog (--oplog).
+	Oplog bool `json:"oplog,omitempty"`
+	// OplogStart is the newest MongoDB oplog entry when the dump started.
+	// Replaying the oplog from here brings the dump forward in time.
+	OplogStart *OplogTimestamp `json:"oplog_start,omitempty"`
+
+	// Filters is set when only part of the database was backed up.
+	Filters *Filters `json:"filters,omitempty"`
+}
+
+// Filters are the table or namespace glob patterns a backup was limited by.
+type Filters struct {
+	Include     []string `json:"include,omitempty"`
+	Exclude     []string `json:"exclude,omitempty"`
+	ExcludeData []string `json:"exclude_data,omitempty"`
+	// Query is the MongoDB document filter a native dump was taken with.
+	Query string `json:"query,omitempty"`
+}
+
+// Partial reports whether the backup doesn't contain the whole database.
+func (m *Manifest) Partial() bool {
+	return m.Filters != nil || (m.Kind != "" && m.Kind != KindFull)
+}
+
+// KindTag returns the name tag that marks schema-only and data-only
+// backups, e.g. ".schema" in mydb_<timestamp>.schema.sql.
+func KindTag(kind string) string {
+	if kind == "" || kind == KindFull {
+		return ""
+	}
+	return "." + kind
+}
+
+type OplogTimestamp struct {
+	T uint32 `json:"t"`
+	I uint32 `json:"i"`
+}
+
+type BinlogPosition struct {
+	File     string `json:"file"`
+	Position int64  `json:"position"`
+}
+
+// Path returns the manifest path for a backup file or directory.
+func Path(backupPath string) string {
+	return backupPath + Suffix
+}
+
+func Write(backupPath string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(Path(backupPath), append(data, '\n'), 0644)
+}
+
+// Read loads a backup's manifest. Backups created before manifests existed
+// have none, in which case the returned error satisfies os.IsNotExist.
+func Read(backupPath string) (*Manifest, error) {
+	data, err := os.ReadFile(Path(backupPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Remove deletes a backup's manifest, if it has one.
+func Remove(backupPath string) error {
+	err := os.Remove(Path(backupPath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Latest returns the newest backup in dir whose manifest matches engine,
+// database and kind, or "" if there is none. Backups without a manifest
+// are ignored.
+func Latest(dir, engine, database, kind string) string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+Suffix))
+	if err != nil {
+		return ""
+	}
+
+	var latest string
+	var latestTime time.Time
+	for _, match := range matches {
+		backupPath := strings.TrimSuffix(match, Suffix)
+		m, err := Read(backupPath)
+		if err != nil || m.Engine != engine || m.Database != database || fullIfEmpty(m.Kind) != fullIfEmpty(kind) {
+			continue
+		}
+		if _, err := os.Stat(backupPath); err != nil {
+			continue
+		}
+		if m.CreatedAt.After(latestTime) {
+			latest, latestTime = backupPath, m.CreatedAt
+		}
+	}
+	return latest
+}
+
+func fullIfEmpty(kind string) string {
+	if kind == "" {
+		return KindFull
+	}
+	return kind
+}
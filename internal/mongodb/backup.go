@@ -1,15 +1,73 @@
 package mongodb
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"os/exec"
+	"os"
 	"time"
 
+	"github.com/tiyfiy/BackItUp/internal/backend"
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+	"github.com/tiyfiy/BackItUp/internal/tool"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
-func Backup(client *mongo.Client, uri string) {
+// BackupOptions controls how mongodump writes the backup.
+type BackupOptions struct {
+	// Archive writes a single archive file instead of a directory tree.
+	Archive bool
+	// Gzip compresses the archive, or each file in a directory dump.
+	Gzip bool
+	// Oplog captures oplog entries written during the dump so the
+	// backup is consistent to a single point in time. Replica sets only.
+	Oplog bool
+	// Include and Exclude are namespace glob patterns such as "shop.*"
+	// or "*.audit_log".
+	Include []string
+	Exclude []string
+
+	// Native dumps through the driver instead of mongodump.
+	Native bool
+	// Query is an extended JSON filter, e.g. {"status": "active"}, that
+	// limits the documents dumped from every collection. Native dumps only.
+	Query string
+
+	// Exec is where mongodump runs, this machine when nil. Tools in a
+	// container can only write archives, which are streamed back here.
+	Exec backend.Backend
+}
+
+// Backend returns where mongodump runs.
+func (o BackupOptions) Backend() backend.Backend {
+	if o.Exec == nil {
+		return backend.Local
+	}
+	return o.Exec
+}
+
+// Dumpers a backup can be taken with.
+const (
+	DumperMongodump = "mongodump"
+	DumperNative    = "native"
+)
+
+// ValidDumper reports whether dumper is a known dumper.
+func ValidDumper(dumper string) bool {
+	return dumper == DumperMongodump || dumper == DumperNative
+}
+
+// Backup dumps the instance with mongodump, or through the driver when
+// opts.Native is set, and returns the path of the backup. If the dump
+// fails, the partial backup is removed; mongodump's errors carry its stderr.
+func Backup(client *mongo.Client, uri string, opts BackupOptions) (string, error) {
+	err := os.MkdirAll("BACKUP/mongo", 0755)
+	if err != nil {
+		return "", err
+	}
+
 	// Add timestamp to directory name
 	now := time.Now()
 	timestamp := fmt.Sprintf("%d-%02d-%02d_%02d-%02d-%02d",
@@ -17,11 +75,159 @@ func Backup(client *mongo.Client, uri string) {
 		now.Hour(), now.Minute(), now.Second())
 	path := fmt.Sprintf("BACKUP/mongo/backup_%s", timestamp)
 
-	cmd := exec.Command("mongodump", "--uri", uri, "--out", path)
-	err := cmd.Run()
+	tools := opts.Backend()
+	if !tools.Local() && !opts.Archive && !opts.Native {
+		return "", fmt.Errorf("mongodump in %s can't write a directory dump here, use --archive", tools)
+	}
+
+	// Archives from a container come back on stdout
+	var args []string
+	if opts.Archive {
+		path += ArchiveExtension(opts.Gzip)
+		if tools.Local() {
+			args = append(args, "--archive="+path)
+		} else {
+			args = append(args, "--archive")
+		}
+	} else {
+		args = append(args, "--out", path)
+	}
+	if opts.Gzip {
+		args = append(args, "--gzip")
+	}
+	if opts.Oplog {
+		args = append(args, "--oplog")
+	}
+
+	if opts.Query != "" && !opts.Native {
+		return "", fmt.Errorf("document queries need the native dumper")
+	}
+	if opts.Native && client == nil {
+		return "", fmt.Errorf("the native dumper needs a working connection")
+	}
+
+	runs := [][]string{args}
+	filtered := len(opts.Include) > 0 || len(opts.Exclude) > 0 || opts.Query != ""
+	if filtered && !opts.Native {
+		runs, err = filteredRuns(client, args, opts)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Remember where the oplog was so oplog-follow and PITR restores know
+	// where this dump starts. Standalone servers have no oplog.
+	var oplogStart *manifest.OplogTimestamp
+	if client != nil {
+		if ts, err := LatestOplogTimestamp(context.Background(), client); err == nil {
+			oplogStart = &manifest.OplogTimestamp{T: ts.T, I: ts.I}
+		}
+	}
+
+	// Estimate the dump size from the previous backup, or from the size of
+	// the databases when there is none yet. mongodump writes the output
+	// itself, so progress is the size of what it has written so far.
+	total := progress.SizeOf(manifest.Latest("BACKUP/mongo", "mongodb", "", manifest.KindFull))
+	if total == 0 && client != nil {
+		if result, err := client.ListDatabases(context.Background(), bson.D{}); err == nil {
+			total = result.TotalSize
+		}
+	}
+
+	if opts.Native {
+		meter := progress.Watch("Dumped", total, path)
+		err = dumpNative(context.Background(), client, path, opts)
+		meter.Finish()
+	} else if !tools.Local() {
+		err = dumpArchive(tools, uri, path, total, runs[0])
+	} else {
+		meter := progress.Watch("Dumped", total, path)
+		for _, runArgs := range runs {
+			if err = tool.Run(ToolCommand(tools, uri, "mongodump", runArgs...)); err != nil {
+				break
+			}
+		}
+		meter.Finish()
+	}
 	if err != nil {
-		log.Fatal(err)
+		os.RemoveAll(path)
+		return "", err
+	}
+
+	m := &manifest.Manifest{
+		Engine:     "mongodb",
+		CreatedAt:  now,
+		Oplog:      opts.Oplog,
+		OplogStart: oplogStart,
+	}
+	if filtered {
+		m.Filters = &manifest.Filters{Include: opts.Include, Exclude: opts.Exclude, Query: opts.Query}
+	}
+	if err := manifest.Write(path, m); err != nil {
+		log.Printf("Warning: failed to write manifest: %v", err)
 	}
 
 	fmt.Printf("✅ Backup completed: %s\n", path)
+	return path, nil
+}
+
+// dumpArchive runs mongodump in tools and writes the archive it streams
+// to stdout into path. Archives hold at most one run, see filteredRuns.
+func dumpArchive(tools backend.Backend, uri, path string, total int64, args []string) error {
+	output, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	cmd := ToolCommand(tools, uri, "mongodump", args...)
+	meter := progress.Start("Dumped", total)
+	defer meter.Finish()
+	cmd.Stdout = meter.Writer(output)
+
+	if err := tool.Run(cmd); err != nil {
+		return err
+	}
+	return output.Close()
+}
+
+// filteredRuns expands namespace filters into one mongodump run per
+// selected database, all writing into the same output directory.
+func filteredRuns(client *mongo.Client, args []string, opts BackupOptions) ([][]string, error) {
+	if client == nil {
+		return nil, fmt.Errorf("namespace filters need a working connection to resolve")
+	}
+	if opts.Oplog {
+		return nil, fmt.Errorf("--oplog dumps the whole instance and can't be combined with namespace filters")
+	}
+
+	plans, err := planFilteredDump(context.Background(), client, opts.Include, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("no collections match the namespace filters")
+	}
+	if opts.Archive && len(plans) > 1 {
+		return nil, fmt.Errorf("archive backups with filters can only cover one database (%d match), use directory mode", len(plans))
+	}
+
+	var runs [][]string
+	for _, plan := range plans {
+		runArgs := append([]string{}, args...)
+		runArgs = append(runArgs, "--db", plan.Database)
+		for _, collection := range plan.Exclude {
+			runArgs = append(runArgs, "--excludeCollection", collection)
+		}
+		runs = append(runs, runArgs)
+	}
+	return runs, nil
+}
+
+// ArchiveExtension returns the file extension of an archive backup.
+func ArchiveExtension(gzip bool) string {
+	if gzip {
+		return ".archive.gz"
+	}
+	return ".archive"
 }
@@ -4,6 +4,7 @@ package mongodb
 import (
 	"context"
 
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
@@ -20,3 +21,12 @@ func Connection(uri string) (*mongo.Client, error) {
 
 	return client, nil
 }
+
+// ServerVersion returns the server's version string, e.g. "7.0.5".
+func ServerVersion(client *mongo.Client) (string, error) {
+	var info struct {
+		Version string `bson:"version"`
+	}
+	err := client.Database("admin").RunCommand(context.TODO(), bson.D{{Key: "buildInfo", Value: 1}}).Decode(&info)
+	return info.Version, err
+}
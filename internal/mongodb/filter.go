@@ -0,0 +1,65 @@
+package mongodb
+
+import (
+	"context"
+	"path"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// dumpPlan is one mongodump run limited to a database, skipping some of
+// its collections.
+type dumpPlan struct {
+	Database string
+	Exclude  []string
+}
+
+// planFilteredDump resolves namespace glob patterns ("shop.*",
+// "*.audit_log") against the server. mongodump can only be limited to one
+// database at a time, so the result is one run per database that has any
+// selected collection, excluding the collections that weren't selected.
+func planFilteredDump(ctx context.Context, client *mongo.Client, include, exclude []string) ([]dumpPlan, error) {
+	databases, err := client.ListDatabaseNames(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []dumpPlan
+	for _, database := range databases {
+		if database == "admin" || database == "local" || database == "config" {
+			continue
+		}
+
+		collections, err := client.Database(database).ListCollectionNames(ctx, bson.D{})
+		if err != nil {
+			return nil, err
+		}
+
+		plan := dumpPlan{Database: database}
+		selected := 0
+		for _, collection := range collections {
+			ns := database + "." + collection
+			if (len(include) == 0 || matchAny(include, ns)) && !matchAny(exclude, ns) {
+				selected++
+			} else {
+				plan.Exclude = append(plan.Exclude, collection)
+			}
+		}
+
+		if selected > 0 {
+			plans = append(plans, plan)
+		}
+	}
+
+	return plans, nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
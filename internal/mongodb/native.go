@@ -0,0 +1,515 @@
+package mongodb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tiyfiy/BackItUp/internal/progress"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Limits for one insertMany batch during a native restore, well below the
+// server's 48 MB message size.
+const (
+	restoreBatchDocs  = 1000
+	restoreBatchBytes = 16 << 20
+)
+
+// collectionMetadata is the .metadata.json file mongodump writes next to
+// each collection's .bson file.
+type collectionMetadata struct {
+	Indexes        []bson.D `bson:"indexes"`
+	UUID           string   `bson:"uuid,omitempty"`
+	CollectionName string   `bson:"collectionName"`
+	Type           string   `bson:"type,omitempty"`
+	Options        bson.D   `bson:"options"`
+}
+
+// dumpNative writes every selected collection into dir in mongodump's
+// directory layout: <db>/<collection>.bson with the documents and
+// <db>/<collection>.metadata.json with the options and indexes, gzipped
+// when opts.Gzip is set. Views get only their metadata.
+func dumpNative(ctx context.Context, client *mongo.Client, dir string, opts BackupOptions) error {
+	if opts.Archive {
+		return fmt.Errorf("the native dumper writes directories only, turn off --archive")
+	}
+	if opts.Oplog {
+		return fmt.Errorf("the native dumper can't capture the oplog, turn off --oplog")
+	}
+
+	var filter any = bson.D{}
+	if opts.Query != "" {
+		if err := bson.UnmarshalExtJSON([]byte(opts.Query), false, &filter); err != nil {
+			return fmt.Errorf("invalid query %s: %w", opts.Query, err)
+		}
+	}
+
+	databases, err := client.ListDatabaseNames(ctx, bson.D{})
+	if err != nil {
+		return err
+	}
+
+	dumped := 0
+	for _, database := range databases {
+		if database == "admin" || database == "local" || database == "config" {
+			continue
+		}
+
+		cursor, err := client.Database(database).ListCollections(ctx, bson.D{})
+		if err != nil {
+			return err
+		}
+		var collections []struct {
+			Name    string `bson:"name"`
+			Type    string `bson:"type"`
+			Options bson.D `bson:"options"`
+			Info    struct {
+				UUID bson.Binary `bson:"uuid"`
+			} `bson:"info"`
+		}
+		if err := cursor.All(ctx, &collections); err != nil {
+			return err
+		}
+
+		for _, collection := range collections {
+			ns := database + "." + collection.Name
+			if strings.HasPrefix(collection.Name, "system.") {
+				continue
+			}
+			if len(opts.Include) > 0 && !matchAny(opts.Include, ns) || matchAny(opts.Exclude, ns) {
+				continue
+			}
+
+			// mongorestore expects objects and arrays here, never null
+			metadata := collectionMetadata{
+				CollectionName: collection.Name,
+				Type:           collection.Type,
+				Options:        bson.D{},
+				Indexes:        []bson.D{},
+			}
+			if collection.Options != nil {
+				metadata.Options = collection.Options
+			}
+			if len(collection.Info.UUID.Data) > 0 {
+				metadata.UUID = fmt.Sprintf("%x", collection.Info.UUID.Data)
+			}
+
+			coll := client.Database(database).Collection(collection.Name)
+			if collection.Type != "view" {
+				indexes, err := coll.Indexes().List(ctx)
+				if err != nil {
+					return fmt.Errorf("%s: %w", ns, err)
+				}
+				if err := indexes.All(ctx, &metadata.Indexes); err != nil {
+					return fmt.Errorf("%s: %w", ns, err)
+				}
+			}
+
+			base := filepath.Join(dir, database, collection.Name)
+			if err := writeMetadata(base+".metadata.json", metadata, opts.Gzip); err != nil {
+				return fmt.Errorf("%s: %w", ns, err)
+			}
+			if collection.Type != "view" {
+				if err := dumpCollection(ctx, coll, filter, base+".bson", opts.Gzip); err != nil {
+					return fmt.Errorf("%s: %w", ns, err)
+				}
+			}
+			dumped++
+		}
+	}
+
+	if dumped == 0 && (len(opts.Include) > 0 || len(opts.Exclude) > 0) {
+		return fmt.Errorf("no collections match the namespace filters")
+	}
+	return nil
+}
+
+// dumpCollection streams the documents matching filter into a .bson file,
+// one raw BSON document after another.
+func dumpCollection(ctx context.Context, coll *mongo.Collection, filter any, path string, compress bool) error {
+	w, closeFile, err := createDumpFile(path, compress)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		closeFile()
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		if _, err := w.Write(cursor.Current); err != nil {
+			closeFile()
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		closeFile()
+		return err
+	}
+	return closeFile()
+}
+
+func writeMetadata(path string, metadata collectionMetadata, compress bool) error {
+	data, err := bson.MarshalExtJSON(metadata, true, false)
+	if err != nil {
+		return err
+	}
+
+	w, closeFile, err := createDumpFile(path, compress)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		closeFile()
+		return err
+	}
+	return closeFile()
+}
+
+// createDumpFile creates path (with a .gz suffix when compressing) and its
+// directory. The returned function flushes and closes it.
+func createDumpFile(path string, compress bool) (io.Writer, func() error, error) {
+	if compress {
+		path += ".gz"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buffered := bufio.NewWriterSize(file, 1<<20)
+	if !compress {
+		return buffered, func() error {
+			if err := buffered.Flush(); err != nil {
+				file.Close()
+				return err
+			}
+			return file.Close()
+		}, nil
+	}
+
+	zw := gzip.NewWriter(buffered)
+	return zw, func() error {
+		err := zw.Close()
+		if err == nil {
+			err = buffered.Flush()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+// RestoreOptions selects and renames the namespaces a native restore
+// loads.
+type RestoreOptions struct {
+	// Include and Exclude are namespace patterns such as "shop.*".
+	Include []string
+	Exclude []string
+	// From and To rename namespaces, in mongorestore's --nsFrom/--nsTo
+	// syntax: "shop.$coll$" to "shop_copy.$coll$".
+	From []string
+	To   []string
+}
+
+// RestoreNative loads a directory backup in mongodump layout, from
+// mongodump or the native dumper, through the driver. Each collection is
+// dropped and recreated with its options, its documents are inserted and
+// its indexes rebuilt, like mongorestore --drop.
+func RestoreNative(client *mongo.Client, dir string, opts RestoreOptions) error {
+	ctx := context.Background()
+
+	if info, err := os.Stat(dir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("the native restore reads directory backups only, restore archives with mongorestore")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "oplog.bson")); err == nil {
+		return fmt.Errorf("the backup has a captured oplog, restore it with mongorestore to replay it")
+	}
+
+	rename, err := namespaceRenamer(opts.From, opts.To)
+	if err != nil {
+		return err
+	}
+
+	metadataFiles, err := filepath.Glob(filepath.Join(dir, "*", "*.metadata.json*"))
+	if err != nil {
+		return err
+	}
+
+	meter := progress.Start("Restored", progress.SizeOf(dir))
+	defer meter.Finish()
+
+	// Collections first and views after, since views select from them
+	var views []string
+	for _, metadataFile := range metadataFiles {
+		metadata, err := readMetadata(metadataFile)
+		if err != nil {
+			return fmt.Errorf("%s: %w", metadataFile, err)
+		}
+		if metadata.Type == "view" {
+			views = append(views, metadataFile)
+			continue
+		}
+		if err := restoreCollection(ctx, client, metadataFile, metadata, opts, rename, meter); err != nil {
+			return err
+		}
+	}
+	for _, metadataFile := range views {
+		metadata, _ := readMetadata(metadataFile)
+		if err := restoreCollection(ctx, client, metadataFile, metadata, opts, rename, meter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreCollection recreates one collection or view from its metadata and
+// .bson file.
+func restoreCollection(ctx context.Context, client *mongo.Client, metadataFile string, metadata collectionMetadata, opts RestoreOptions, rename func(string) string, meter *progress.Meter) error {
+	database := filepath.Base(filepath.Dir(metadataFile))
+	name := metadata.CollectionName
+	if name == "" {
+		name = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(metadataFile), ".gz"), ".metadata.json")
+	}
+
+	source := database + "." + name
+	if len(opts.Include) > 0 && !matchAny(opts.Include, source) || matchAny(opts.Exclude, source) {
+		return nil
+	}
+
+	target := rename(source)
+	targetDB, targetColl, _ := strings.Cut(target, ".")
+	db := client.Database(targetDB)
+	fmt.Printf("   %s -> %s\n", source, target)
+
+	if err := db.Collection(targetColl).Drop(ctx); err != nil {
+		return fmt.Errorf("%s: %w", target, err)
+	}
+
+	// Recreate with the original options (capped, validator, collation,
+	// view definition, ...)
+	create := append(bson.D{{Key: "create", Value: targetColl}}, metadata.Options...)
+	if err := db.RunCommand(ctx, create).Err(); err != nil {
+		return fmt.Errorf("%s: %w", target, err)
+	}
+	if metadata.Type == "view" {
+		return nil
+	}
+
+	bsonFile := strings.Replace(metadataFile, ".metadata.json", ".bson", 1)
+	if err := insertDocuments(ctx, db.Collection(targetColl), bsonFile, meter); err != nil {
+		return fmt.Errorf("%s: %w", target, err)
+	}
+
+	var indexes []bson.D
+	for _, index := range metadata.Indexes {
+		spec := bson.D{}
+		isID := false
+		for _, field := range index {
+			switch field.Key {
+			case "v", "ns":
+				// Set by the server
+			case "name":
+				isID = field.Value == "_id_"
+				spec = append(spec, field)
+			default:
+				spec = append(spec, field)
+			}
+		}
+		if !isID {
+			indexes = append(indexes, spec)
+		}
+	}
+	if len(indexes) > 0 {
+		command := bson.D{{Key: "createIndexes", Value: targetColl}, {Key: "indexes", Value: indexes}}
+		if err := db.RunCommand(ctx, command).Err(); err != nil {
+			return fmt.Errorf("%s: creating indexes: %w", target, err)
+		}
+	}
+	return nil
+}
+
+// insertDocuments reads a .bson file (gzipped or not) and inserts its
+// documents in batches. A missing file means an empty collection.
+func insertDocuments(ctx context.Context, coll *mongo.Collection, path string, meter *progress.Meter) error {
+	r, closeFile, err := openDumpFile(path, meter)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+
+	var batch []any
+	batchBytes := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := coll.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+		batch, batchBytes = nil, 0
+		return err
+	}
+
+	for {
+		doc, err := readDocument(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		batch = append(batch, doc)
+		batchBytes += len(doc)
+		if len(batch) >= restoreBatchDocs || batchBytes >= restoreBatchBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// readDocument reads the next length-prefixed BSON document.
+func readDocument(r io.Reader) (bson.Raw, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	length := int(binary.LittleEndian.Uint32(size[:]))
+	if length < 5 {
+		return nil, fmt.Errorf("corrupt BSON document length %d", length)
+	}
+
+	doc := make([]byte, length)
+	copy(doc, size[:])
+	if _, err := io.ReadFull(r, doc[4:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return doc, nil
+}
+
+func readMetadata(path string) (collectionMetadata, error) {
+	var metadata collectionMetadata
+
+	r, closeFile, err := openDumpFile(path, nil)
+	if err != nil {
+		return metadata, err
+	}
+	defer closeFile()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return metadata, err
+	}
+	err = bson.UnmarshalExtJSON(data, false, &metadata)
+	return metadata, err
+}
+
+// openDumpFile opens a dump file, or its .gz variant, decompressing it on
+// the fly and counting the bytes read on meter if there is one.
+func openDumpFile(path string, meter *progress.Meter) (io.Reader, func(), error) {
+	compressed := strings.HasSuffix(path, ".gz")
+	file, err := os.Open(path)
+	if os.IsNotExist(err) && !compressed {
+		path += ".gz"
+		compressed = true
+		file, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var r io.Reader = bufio.NewReaderSize(file, 1<<20)
+	if meter != nil {
+		r = meter.Reader(r)
+	}
+	if !compressed {
+		return r, func() { file.Close() }, nil
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return zr, func() {
+		zr.Close()
+		file.Close()
+	}, nil
+}
+
+// namespaceVariable matches the $name$ wildcards of --nsFrom/--nsTo.
+var namespaceVariable = regexp.MustCompile(`\$([^$]+)\$`)
+
+// namespaceRenamer builds a function applying mongorestore-style renames.
+// Namespaces no rename matches are kept.
+func namespaceRenamer(from, to []string) (func(string) string, error) {
+	if len(from) != len(to) {
+		return nil, fmt.Errorf("every namespace rename needs both a from and a to pattern")
+	}
+
+	type rename struct {
+		pattern *regexp.Regexp
+		to      string
+	}
+	var renames []rename
+	for i := range from {
+		var expr strings.Builder
+		expr.WriteString("^")
+		last := 0
+		for _, m := range namespaceVariable.FindAllStringSubmatchIndex(from[i], -1) {
+			expr.WriteString(regexp.QuoteMeta(from[i][last:m[0]]))
+			expr.WriteString("(?P<" + from[i][m[2]:m[3]] + ">.*?)")
+			last = m[1]
+		}
+		expr.WriteString(regexp.QuoteMeta(from[i][last:]) + "$")
+
+		pattern, err := regexp.Compile(expr.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid namespace pattern %q: %w", from[i], err)
+		}
+		renames = append(renames, rename{pattern: pattern, to: to[i]})
+	}
+
+	return func(ns string) string {
+		for _, r := range renames {
+			m := r.pattern.FindStringSubmatch(ns)
+			if m == nil {
+				continue
+			}
+			return namespaceVariable.ReplaceAllStringFunc(r.to, func(variable string) string {
+				index := r.pattern.SubexpIndex(strings.Trim(variable, "$"))
+				if index < 0 {
+					return variable
+				}
+				return m[index]
+			})
+		}
+		return ns
+	}, nil
+}
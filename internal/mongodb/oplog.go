@@ -0,0 +1,402 @@
+package mongodb
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// OplogDir is where FollowOplog stores oplog chunks and its resume state.
+const OplogDir = "BACKUP/mongo/oplog"
+
+const (
+	resumeFile   = "resume.json"
+	chunkPrefix  = "oplog_"
+	chunkSuffix  = ".bson.gz"
+	partialChunk = ".partial"
+)
+
+// OplogOptions controls when FollowOplog starts a new chunk file.
+type OplogOptions struct {
+	// ChunkSize is the uncompressed size at which a chunk is rotated.
+	ChunkSize int64
+	// ChunkInterval is the longest time a chunk stays open.
+	ChunkInterval time.Duration
+}
+
+// oplogChunk is the chunk file currently being written. It holds every
+// oplog entry after from, up to last.
+type oplogChunk struct {
+	file   *os.File
+	gz     *gzip.Writer
+	from   bson.Timestamp
+	first  bson.Timestamp
+	last   bson.Timestamp
+	size   int64
+	opened time.Time
+}
+
+// LatestOplogTimestamp returns the timestamp of the newest oplog entry.
+func LatestOplogTimestamp(ctx context.Context, client *mongo.Client) (bson.Timestamp, error) {
+	return oplogEdge(ctx, client, -1)
+}
+
+// oplogEdge returns the newest (direction -1) or oldest (1) oplog timestamp.
+func oplogEdge(ctx context.Context, client *mongo.Client, direction int) (bson.Timestamp, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "$natural", Value: direction}}).SetProjection(bson.D{{Key: "ts", Value: 1}})
+
+	raw, err := client.Database("local").Collection("oplog.rs").FindOne(ctx, bson.D{}, opts).Raw()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return bson.Timestamp{}, fmt.Errorf("local.oplog.rs is empty or missing, is this a replica set?")
+		}
+		return bson.Timestamp{}, err
+	}
+
+	t, i := raw.Lookup("ts").Timestamp()
+	return bson.Timestamp{T: t, I: i}, nil
+}
+
+// FollowOplog tails local.oplog.rs from just after start and writes the
+// entries into rotating, gzip-compressed chunk files in OplogDir. After each
+// chunk is safely on disk its last timestamp is persisted, so a restart
+// resumes without gaps. It returns nil once ctx is cancelled.
+func FollowOplog(ctx context.Context, client *mongo.Client, start bson.Timestamp, opts OplogOptions) error {
+	err := os.MkdirAll(OplogDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	// Chunks left unfinished by an earlier run are re-read from the oplog,
+	// since the resume point only moves once a chunk is finished
+	if err := removePartialChunks(); err != nil {
+		return err
+	}
+
+	// from is where the next chunk's entries start. When the oplog has been
+	// truncated past start, the chunks only cover what is left of it
+	from := start
+	if oldest, err := oplogEdge(ctx, client, 1); err == nil && oldest.After(start) {
+		fmt.Printf("⚠️  The oplog no longer reaches back to %s; changes up to %s are missing\n",
+			timestampString(start), timestampString(oldest))
+		from = oldest
+	}
+
+	filter := bson.D{{Key: "ts", Value: bson.D{{Key: "$gt", Value: start}}}}
+	findOpts := options.Find().SetCursorType(options.TailableAwait).SetMaxAwaitTime(time.Second)
+
+	cursor, err := client.Database("local").Collection("oplog.rs").Find(ctx, filter, findOpts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.Background())
+
+	var chunk *oplogChunk
+	for {
+		if ctx.Err() != nil {
+			return chunk.finish()
+		}
+
+		if cursor.TryNext(ctx) {
+			if chunk == nil {
+				chunk, err = newOplogChunk(from)
+				if err != nil {
+					return err
+				}
+			}
+			if err := chunk.write(cursor.Current); err != nil {
+				return err
+			}
+			if chunk.full(opts) {
+				if err := chunk.finish(); err != nil {
+					return err
+				}
+				from = chunk.last
+				chunk = nil
+			}
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return chunk.finish()
+		}
+		if err := cursor.Err(); err != nil {
+			chunk.finish()
+			return err
+		}
+		if cursor.ID() == 0 {
+			chunk.finish()
+			return fmt.Errorf("oplog cursor closed by the server")
+		}
+
+		if chunk != nil && chunk.full(opts) {
+			if err := chunk.finish(); err != nil {
+				return err
+			}
+			from = chunk.last
+			chunk = nil
+		}
+	}
+}
+
+// removePartialChunks deletes the chunk files an interrupted FollowOplog
+// left behind.
+func removePartialChunks() error {
+	partials, err := filepath.Glob(filepath.Join(OplogDir, chunkPrefix+"*"+partialChunk))
+	if err != nil {
+		return err
+	}
+	for _, partial := range partials {
+		fmt.Printf("   Removing unfinished chunk %s\n", filepath.Base(partial))
+		if err := os.Remove(partial); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newOplogChunk(from bson.Timestamp) (*oplogChunk, error) {
+	file, err := os.CreateTemp(OplogDir, chunkPrefix+"*"+partialChunk)
+	if err != nil {
+		return nil, err
+	}
+	return &oplogChunk{file: file, gz: gzip.NewWriter(file), from: from, opened: time.Now()}, nil
+}
+
+// full reports whether the chunk has reached its size or age limit. A busy
+// oplog never leaves the cursor idle, so both are checked after every entry.
+func (c *oplogChunk) full(opts OplogOptions) bool {
+	if opts.ChunkSize > 0 && c.size >= opts.ChunkSize {
+		return true
+	}
+	return opts.ChunkInterval > 0 && time.Since(c.opened) >= opts.ChunkInterval
+}
+
+func (c *oplogChunk) write(entry bson.Raw) error {
+	t, i := entry.Lookup("ts").Timestamp()
+	ts := bson.Timestamp{T: t, I: i}
+	if c.first.IsZero() {
+		c.first = ts
+	}
+	c.last = ts
+
+	n, err := c.gz.Write(entry)
+	c.size += int64(n)
+	return err
+}
+
+// finish closes the chunk, gives it its final name and records its last
+// timestamp as the resume point. Calling it on a nil chunk is a no-op.
+func (c *oplogChunk) finish() error {
+	if c == nil {
+		return nil
+	}
+	if err := c.gz.Close(); err != nil {
+		return err
+	}
+	if err := c.file.Sync(); err != nil {
+		return err
+	}
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+
+	name := chunkName(c.from, c.last)
+	if err := os.Rename(c.file.Name(), filepath.Join(OplogDir, name)); err != nil {
+		return err
+	}
+
+	fmt.Printf("   📝 %s (%s → %s)\n", name, timestampString(c.first), timestampString(c.last))
+	return SaveResumeTimestamp(c.last)
+}
+
+// LoadResumeTimestamp returns the last oplog timestamp FollowOplog stored.
+func LoadResumeTimestamp() (bson.Timestamp, bool) {
+	data, err := os.ReadFile(filepath.Join(OplogDir, resumeFile))
+	if err != nil {
+		return bson.Timestamp{}, false
+	}
+
+	var ts bson.Timestamp
+	if err := json.Unmarshal(data, &ts); err != nil {
+		return bson.Timestamp{}, false
+	}
+	return ts, !ts.IsZero()
+}
+
+// SaveResumeTimestamp atomically records where FollowOplog should resume.
+func SaveResumeTimestamp(ts bson.Timestamp) error {
+	data, err := json.Marshal(ts)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(OplogDir, resumeFile)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// ExtractOplog writes the collected oplog entries after the given timestamp
+// and up to until (inclusive) to w as a mongorestore-compatible oplog.bson.
+// It returns the number of entries written. It fails when the collected
+// chunks don't cover everything from after to until without gaps.
+func ExtractOplog(w io.Writer, after bson.Timestamp, until time.Time) (int, error) {
+	limit := uint32(until.Unix())
+	chunks, err := neededChunks(after, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, chunk := range chunks {
+		n, err := extractChunk(w, filepath.Join(OplogDir, chunk.name), after, limit)
+		count += n
+		if err != nil {
+			return count, fmt.Errorf("%s: %w", chunk.name, err)
+		}
+	}
+
+	return count, nil
+}
+
+// CheckOplog reports whether the collected oplog covers everything after
+// the given timestamp up to until, so a restore can stop before it starts.
+func CheckOplog(after bson.Timestamp, until time.Time) error {
+	_, err := neededChunks(after, uint32(until.Unix()))
+	return err
+}
+
+// chunkFile is a finished chunk, holding every oplog entry after from, up
+// to last.
+type chunkFile struct {
+	name       string
+	from, last bson.Timestamp
+}
+
+// neededChunks returns the chunks holding the entries after the given
+// timestamp up to the end of the second limit, in order. Every entry in
+// that second must have been collected, so the last chunk has to reach
+// past it.
+func neededChunks(after bson.Timestamp, limit uint32) ([]chunkFile, error) {
+	entries, err := os.ReadDir(OplogDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []chunkFile
+	for _, entry := range entries {
+		if from, last, ok := parseChunkName(entry.Name()); ok {
+			chunks = append(chunks, chunkFile{name: entry.Name(), from: from, last: last})
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no oplog chunks have been collected in %s", OplogDir)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].from.Before(chunks[j].from)
+	})
+
+	var needed []chunkFile
+	for _, chunk := range chunks {
+		if !chunk.last.After(after) {
+			continue
+		}
+		needed = append(needed, chunk)
+		if chunk.last.T > limit {
+			break
+		}
+	}
+
+	newest := chunks[len(chunks)-1].last
+	if len(needed) > 0 {
+		newest = needed[len(needed)-1].last
+	}
+	if len(needed) == 0 || newest.T <= limit {
+		return nil, fmt.Errorf("the collected oplog ends at %s, before the end of %s; keep oplog-follow running until a later chunk is written",
+			timestampString(newest), time.Unix(int64(limit), 0).Format("2006-01-02 15:04:05"))
+	}
+	if needed[0].from.After(after) {
+		return nil, fmt.Errorf("the collected oplog starts at %s, after the backup's oplog position %s",
+			timestampString(needed[0].from), timestampString(after))
+	}
+	for i := 1; i < len(needed); i++ {
+		if !needed[i].from.Equal(needed[i-1].last) {
+			return nil, fmt.Errorf("oplog chunks %s and %s are not contiguous, changes between them are missing",
+				needed[i-1].name, needed[i].name)
+		}
+	}
+
+	return needed, nil
+}
+
+func extractChunk(w io.Writer, path string, after bson.Timestamp, limit uint32) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, err
+	}
+	defer gz.Close()
+
+	count := 0
+	for {
+		entry, err := bson.ReadDocument(gz)
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		t, i := entry.Lookup("ts").Timestamp()
+		ts := bson.Timestamp{T: t, I: i}
+		if !ts.After(after) {
+			continue
+		}
+		if ts.T > limit {
+			return count, nil
+		}
+
+		if _, err := w.Write(entry); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// chunkName names the chunk holding the entries after from, up to last.
+func chunkName(from, last bson.Timestamp) string {
+	return fmt.Sprintf("%s%d-%d_%d-%d%s", chunkPrefix, from.T, from.I, last.T, last.I, chunkSuffix)
+}
+
+// parseChunkName parses a name written by chunkName.
+func parseChunkName(name string) (from, last bson.Timestamp, ok bool) {
+	if !strings.HasPrefix(name, chunkPrefix) || !strings.HasSuffix(name, chunkSuffix) {
+		return from, last, false
+	}
+	_, err := fmt.Sscanf(strings.TrimPrefix(name, chunkPrefix), "%d-%d_%d-%d", &from.T, &from.I, &last.T, &last.I)
+	return from, last, err == nil
+}
+
+func timestampString(ts bson.Timestamp) string {
+	return time.Unix(int64(ts.T), 0).Format("2006-01-02 15:04:05")
+}
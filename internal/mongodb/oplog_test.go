@@ -0,0 +1,207 @@
+package mongodb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func ts(sec, inc uint32) bson.Timestamp {
+	return bson.Timestamp{T: sec, I: inc}
+}
+
+// writeChunk writes a finished chunk with one no-op entry per timestamp.
+func writeChunk(t *testing.T, from bson.Timestamp, entries ...bson.Timestamp) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for _, entry := range entries {
+		doc, err := bson.Marshal(bson.D{{Key: "ts", Value: entry}, {Key: "op", Value: "n"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := gz.Write(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	name := chunkName(from, entries[len(entries)-1])
+	if err := os.WriteFile(filepath.Join(OplogDir, name), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChunkName(t *testing.T) {
+	name := chunkName(ts(1760700000, 3), ts(1760700300, 12))
+	if name != "oplog_1760700000-3_1760700300-12.bson.gz" {
+		t.Errorf("chunkName() = %q", name)
+	}
+
+	from, last, ok := parseChunkName(name)
+	if !ok || !from.Equal(ts(1760700000, 3)) || !last.Equal(ts(1760700300, 12)) {
+		t.Errorf("parseChunkName(%q) = %v, %v, %v", name, from, last, ok)
+	}
+
+	for _, name := range []string{"resume.json", "oplog_123.partial", "oplog_x-1_2-3.bson.gz", "oplog_1-2_3-4.bson"} {
+		if _, _, ok := parseChunkName(name); ok {
+			t.Errorf("parseChunkName(%q) accepted a name chunkName doesn't write", name)
+		}
+	}
+}
+
+func TestExtractOplog(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(OplogDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeChunk(t, ts(100, 0), ts(100, 1), ts(110, 1), ts(120, 1))
+	writeChunk(t, ts(120, 1), ts(130, 1), ts(140, 1))
+	writeChunk(t, ts(140, 1), ts(150, 1), ts(160, 1))
+	if err := os.WriteFile(filepath.Join(OplogDir, "oplog_1234.partial"), []byte("unfinished"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	count, err := ExtractOplog(&buf, ts(110, 1), time.Unix(140, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Errorf("ExtractOplog() = %d entries, want 3", count)
+	}
+
+	var got []bson.Timestamp
+	for buf.Len() > 0 {
+		entry, err := bson.ReadDocument(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsT, tsI := entry.Lookup("ts").Timestamp()
+		got = append(got, ts(tsT, tsI))
+	}
+	want := []bson.Timestamp{ts(120, 1), ts(130, 1), ts(140, 1)}
+	if len(got) != len(want) {
+		t.Fatalf("extracted %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("entry %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestNeededChunks(t *testing.T) {
+	tests := []struct {
+		name    string
+		chunks  [][]bson.Timestamp // the chunk's from, then its entries
+		after   bson.Timestamp
+		limit   uint32
+		want    []string
+		wantErr string
+	}{
+		{
+			name:   "one chunk",
+			chunks: [][]bson.Timestamp{{ts(100, 0), ts(101, 1), ts(150, 1)}},
+			after:  ts(100, 0),
+			limit:  120,
+			want:   []string{chunkName(ts(100, 0), ts(150, 1))},
+		},
+		{
+			name: "skips chunks outside the range",
+			chunks: [][]bson.Timestamp{
+				{ts(100, 0), ts(110, 1)},
+				{ts(110, 1), ts(120, 1)},
+				{ts(120, 1), ts(130, 1)},
+				{ts(130, 1), ts(140, 1)},
+			},
+			after: ts(115, 0),
+			limit: 125,
+			want:  []string{chunkName(ts(110, 1), ts(120, 1)), chunkName(ts(120, 1), ts(130, 1))},
+		},
+		{
+			name:    "nothing collected",
+			after:   ts(100, 0),
+			limit:   120,
+			wantErr: "no oplog chunks",
+		},
+		{
+			name:    "starts after the backup",
+			chunks:  [][]bson.Timestamp{{ts(105, 0), ts(106, 1), ts(150, 1)}},
+			after:   ts(100, 0),
+			limit:   120,
+			wantErr: "starts at",
+		},
+		{
+			name: "gap between chunks",
+			chunks: [][]bson.Timestamp{
+				{ts(100, 0), ts(110, 1)},
+				{ts(112, 1), ts(130, 1)},
+			},
+			after:   ts(100, 0),
+			limit:   120,
+			wantErr: "not contiguous",
+		},
+		{
+			name:    "ends before the target",
+			chunks:  [][]bson.Timestamp{{ts(100, 0), ts(110, 1)}},
+			after:   ts(100, 0),
+			limit:   120,
+			wantErr: "ends at",
+		},
+		{
+			name:    "ends within the target second",
+			chunks:  [][]bson.Timestamp{{ts(100, 0), ts(120, 1)}},
+			after:   ts(100, 0),
+			limit:   120,
+			wantErr: "ends at",
+		},
+		{
+			name:    "all chunks before the backup",
+			chunks:  [][]bson.Timestamp{{ts(90, 0), ts(95, 1)}},
+			after:   ts(100, 0),
+			limit:   120,
+			wantErr: "ends at",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if err := os.MkdirAll(OplogDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, chunk := range tt.chunks {
+				writeChunk(t, chunk[0], chunk[1:]...)
+			}
+
+			chunks, err := neededChunks(tt.after, tt.limit)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("neededChunks() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, chunk := range chunks {
+				got = append(got, chunk.name)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("neededChunks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
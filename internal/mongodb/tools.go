@@ -0,0 +1,33 @@
+package mongodb
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/tiyfiy/BackItUp/internal/backend"
+)
+
+// uriConfig runs the tool "$0" with the connection string from
+// $MONGODB_URI. The URI holds the password, so it goes into a private
+// config file rather than onto the command line, where anyone in the
+// container could read it from the process list.
+const uriConfig = `f=$(mktemp) || exit 1
+trap 'rm -f "$f"' EXIT
+trap 'exit 1' HUP INT TERM
+printf 'uri: "%s"\n' "$MONGODB_URI" > "$f" || exit 1
+"$0" --config="$f" "$@"`
+
+// ToolCommand returns the command that runs the MongoDB tool name with
+// args in b, connected to uri.
+func ToolCommand(b backend.Backend, uri, name string, args ...string) *exec.Cmd {
+	if b == nil || b.Local() {
+		return exec.Command(name, append([]string{"--uri", uri}, args...)...)
+	}
+
+	cmd := b.Command([]string{"MONGODB_URI=" + uri}, "sh", append([]string{"-c", uriConfig, name}, args...)...)
+	if strings.ContainsAny(uri, `"\`) && cmd.Err == nil {
+		cmd.Err = fmt.Errorf("can't pass the URI to %s in %s: percent-encode its quotes and backslashes", name, b)
+	}
+	return cmd
+}
@@ -0,0 +1,76 @@
+package mongodb
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tiyfiy/BackItUp/internal/backend"
+)
+
+// container runs the tools on this machine but reports them as remote, so
+// the wrapper for containers can be tested without one.
+type container struct{}
+
+func (container) Command(env []string, name string, args ...string) *exec.Cmd {
+	return backend.Local.Command(env, name, args...)
+}
+
+func (container) LookPath(name string) error { return backend.Local.LookPath(name) }
+func (container) Local() bool                { return false }
+func (container) String() string             { return "test container" }
+
+func TestToolCommandLocal(t *testing.T) {
+	cmd := ToolCommand(backend.Local, "mongodb://localhost", "mongodump", "--archive")
+	want := []string{"mongodump", "--uri", "mongodb://localhost", "--archive"}
+	if !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("args = %q, want %q", cmd.Args, want)
+	}
+}
+
+func TestToolCommandInContainer(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed")
+	}
+
+	// The fake tool prints the config it was given and its other arguments
+	dir := t.TempDir()
+	fake := filepath.Join(dir, "mongodump")
+	script := "#!/bin/sh\nconfig=${1#--config=}\ncat \"$config\"\nshift\necho \"$@\"\necho \"$config\" > " + filepath.Join(dir, "config-path") + "\n"
+	if err := os.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	uri := "mongodb://backup:s3cr%24t@db:27017/?authSource=admin"
+	cmd := ToolCommand(container{}, uri, fake, "--archive", "--gzip")
+	if strings.Contains(strings.Join(cmd.Args, " "), "s3cr") {
+		t.Errorf("the URI is on the command line: %q", cmd.Args)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "uri: \"" + uri + "\"\n--archive --gzip\n"
+	if string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	configPath, err := os.ReadFile(filepath.Join(dir, "config-path"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(strings.TrimSpace(string(configPath))); !os.IsNotExist(err) {
+		t.Errorf("the config file was left behind: %v", err)
+	}
+}
+
+func TestToolCommandRefusesQuotes(t *testing.T) {
+	cmd := ToolCommand(container{}, `mongodb://backup:a"b@db`, "mongodump")
+	if cmd.Err == nil {
+		t.Error("a URI with a quote was accepted")
+	}
+}
@@ -0,0 +1,102 @@
+package mongodb
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// TunnelHost returns the host:port of the one server uri names, for an SSH
+// tunnel to forward to. URIs naming a replica set by its members or an SRV
+// record can't be tunneled: the driver and the tools discover the members
+// and connect to each by the name it advertises, which one forwarded port
+// can't cover. TLS can't be checked against the tunnel's address either.
+func TunnelHost(uri string) (string, error) {
+	if strings.HasPrefix(uri, "mongodb+srv://") {
+		return "", fmt.Errorf("mongodb+srv:// URIs name a whole cluster and can't be tunneled, use a mongodb:// URI with one member's host")
+	}
+	_, hosts, _, err := splitURI(uri)
+	if err != nil {
+		return "", err
+	}
+	if len(hosts) != 1 {
+		return "", fmt.Errorf("the URI names %d hosts, but a tunnel forwards to one: use a single host with directConnection=true", len(hosts))
+	}
+
+	options, err := uriOptions(uri)
+	if err != nil {
+		return "", err
+	}
+	if options["directconnection"] == "false" {
+		return "", fmt.Errorf("directConnection=false makes the driver connect to every replica set member, which a tunnel can't reach")
+	}
+	if options["tls"] == "true" || options["ssl"] == "true" {
+		return "", fmt.Errorf("TLS certificates can't be checked against the tunnel's local address")
+	}
+
+	host := hosts[0]
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "27017")
+	}
+	return host, nil
+}
+
+// TunnelURI points uri, which TunnelHost accepted, at addr and makes it a
+// direct connection, so nothing is discovered past the tunnel.
+func TunnelURI(uri, addr string) string {
+	prefix, _, rest, _ := splitURI(uri)
+	options, _ := uriOptions(uri)
+	if options["directconnection"] == "" {
+		switch {
+		case strings.Contains(rest, "?"):
+			rest += "&directConnection=true"
+		case rest == "":
+			rest = "/?directConnection=true"
+		default:
+			rest += "?directConnection=true"
+		}
+	}
+	return prefix + addr + rest
+}
+
+// splitURI splits a mongodb:// URI around its comma-separated hosts:
+// prefix is the scheme and credentials, rest the path and options.
+func splitURI(uri string) (prefix string, hosts []string, rest string, err error) {
+	const scheme = "mongodb://"
+	if !strings.HasPrefix(uri, scheme) {
+		return "", nil, "", fmt.Errorf("invalid MongoDB URI: expected mongodb://")
+	}
+
+	authority := uri[len(scheme):]
+	if i := strings.IndexAny(authority, "/?"); i >= 0 {
+		authority, rest = authority[:i], authority[i:]
+	}
+	prefix = scheme
+	if i := strings.LastIndex(authority, "@"); i >= 0 {
+		prefix += authority[:i+1]
+		authority = authority[i+1:]
+	}
+	if authority == "" {
+		return "", nil, "", fmt.Errorf("invalid MongoDB URI: no host")
+	}
+	return prefix, strings.Split(authority, ","), rest, nil
+}
+
+// uriOptions returns the options of a URI, with lowercase names and
+// values as the spec makes them case-insensitive.
+func uriOptions(uri string) (map[string]string, error) {
+	options := make(map[string]string)
+	_, query, found := strings.Cut(uri, "?")
+	if !found {
+		return options, nil
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MongoDB URI options: %w", err)
+	}
+	for name, value := range values {
+		options[strings.ToLower(name)] = strings.ToLower(value[len(value)-1])
+	}
+	return options, nil
+}
@@ -1,20 +1,63 @@
 package mysql
 
 import (
+	"bufio"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/backend"
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+	"github.com/tiyfiy/BackItUp/internal/tool"
 )
 
-func Backup(db *sql.DB, host, port, user, password, database string) {
+// consistentDumpFlags take an online, consistent snapshot of InnoDB tables
+// and include the stored programs mysqldump skips by default.
+var consistentDumpFlags = []string{
+	"--single-transaction",
+	"--quick",
+	"--routines",
+	"--triggers",
+	"--events",
+	"--hex-blob",
+}
+
+// binlogCoordinates matches the commented CHANGE MASTER/REPLICATION SOURCE
+// statement written by --master-data=2 or --source-data=2.
+var binlogCoordinates = regexp.MustCompile(`(?:MASTER|SOURCE)_LOG_FILE='([^']+)',\s*(?:MASTER|SOURCE)_LOG_POS=(\d+)`)
+
+// BackupOptions controls what the dump includes in the backup.
+type BackupOptions struct {
+	// Kind is manifest.KindFull (the default), KindSchema or KindData.
+	Kind string
+	// Include and Exclude are table glob patterns such as "audit_*".
+	Include []string
+	Exclude []string
+
+	// Native dumps through the database connection instead of mysqldump.
+	Native bool
+
+	// Conn holds the connection settings for mysqldump.
+	Conn ConnOptions
+}
+
+// Backup dumps a database with mysqldump (mariadb-dump for MariaDB), or the
+// native dumper when opts.Native is set, and returns the path of the dump.
+// If the dump fails, the partial dump is removed; the dump tool's errors
+// carry its stderr.
+func Backup(db *sql.DB, host, port, user, password, database string, opts BackupOptions) (string, error) {
 	path := "BACKUP/mysql"
 
 	err := os.MkdirAll(path, 0755)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
 	// Add timestamp to filename to prevent overwrites
@@ -22,27 +65,176 @@ func Backup(db *sql.DB, host, port, user, password, database string) {
 	timestamp := fmt.Sprintf("%d-%02d-%02d_%02d-%02d-%02d",
 		now.Year(), now.Month(), now.Day(),
 		now.Hour(), now.Minute(), now.Second())
-	outfile := fmt.Sprintf("%s/%s_%s.sql", path, database, timestamp)
+	if opts.Kind == "" {
+		opts.Kind = manifest.KindFull
+	}
+	outfile := fmt.Sprintf("%s/%s_%s%s.sql", path, database, timestamp, manifest.KindTag(opts.Kind))
 
-	cmd := exec.Command("mysqldump",
-		"-h", host,
-		"-P", port,
-		"-u", user,
-		fmt.Sprintf("-p%s", password),
-		database,
-	)
+	// The flavor decides which tools dump the backup and restore it later
+	flavor, err := ServerFlavor(db)
+	if err != nil {
+		return "", err
+	}
+
+	var cmd *exec.Cmd
+	if !opts.Native {
+		cmd, err = dumpCommand(db, flavor, host, port, user, password, database, opts)
+		if err != nil {
+			return "", err
+		}
+	}
 
 	output, err := os.Create(outfile)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	defer output.Close()
 
-	cmd.Stdout = output
-	err = cmd.Run()
+	// Estimate the dump size from the previous backup, or from the size of
+	// the table data when there is none yet
+	total := progress.SizeOf(manifest.Latest(path, "mysql", database, opts.Kind))
+	if total == 0 && opts.Kind != manifest.KindSchema {
+		total = dataSize(db, database)
+	}
+
+	meter := progress.Start("Dumped", total)
+	if opts.Native {
+		err = dumpNative(db, database, meter.Writer(output), opts)
+	} else {
+		cmd.Stdout = meter.Writer(output)
+		err = tool.Run(cmd)
+	}
+	meter.Finish()
 	if err != nil {
-		log.Fatal(err)
+		output.Close()
+		os.Remove(outfile)
+		return "", err
+	}
+
+	m := &manifest.Manifest{
+		Engine:    "mysql",
+		Database:  database,
+		CreatedAt: now,
+		Kind:      opts.Kind,
+		Flavor:    flavor,
+	}
+	if len(opts.Include) > 0 || len(opts.Exclude) > 0 {
+		m.Filters = &manifest.Filters{Include: opts.Include, Exclude: opts.Exclude}
+	}
+	if position, err := readBinlogPosition(outfile); err == nil {
+		m.Binlog = position
+	}
+	if err := manifest.Write(outfile, m); err != nil {
+		log.Printf("Warning: failed to write manifest: %v", err)
 	}
 
 	fmt.Printf("✅ Backup completed: %s\n", outfile)
+	if m.Binlog != nil {
+		fmt.Printf("   Binlog position: %s:%d\n", m.Binlog.File, m.Binlog.Position)
+	}
+	return outfile, nil
+}
+
+// Dumpers a backup can be taken with.
+const (
+	DumperMysqldump = "mysqldump"
+	DumperNative    = "native"
+)
+
+// ValidDumper reports whether dumper is a known dumper.
+func ValidDumper(dumper string) bool {
+	return dumper == DumperMysqldump || dumper == DumperNative
+}
+
+// dumpCommand builds the mysqldump (or mariadb-dump) command for a backup.
+func dumpCommand(db *sql.DB, flavor, host, port, user, password, database string, opts BackupOptions) (*exec.Cmd, error) {
+	tools := opts.Conn.Backend()
+	dumpTool := ToolsFor(flavor, tools).Dump
+
+	args := ClientArgs(dumpTool, host, port, user, password, opts.Conn)
+	args = append(args, consistentDumpFlags...)
+	args = append(args, kindFlags(opts.Kind)...)
+	if flavor == FlavorMariaDB {
+		args = append(args, mariadbDumpFlags(db, tools, dumpTool, database)...)
+	}
+
+	// Binlog coordinates are only available when binary logging is on
+	if binlogEnabled(db) {
+		args = append(args, sourceDataFlag(tools, dumpTool))
+	}
+	args = append(args, database)
+
+	filter, err := tableArgs(db, database, opts.Include, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, filter...)
+
+	return tools.Command(ClientEnv(password, opts.Conn), dumpTool, args...), nil
+}
+
+// kindFlags limits the dump to table definitions and stored programs
+// (schema) or to table rows (data).
+func kindFlags(kind string) []string {
+	switch kind {
+	case manifest.KindSchema:
+		return []string{"--no-data"}
+	case manifest.KindData:
+		return []string{"--no-create-info", "--skip-triggers", "--skip-routines", "--skip-events"}
+	}
+	return nil
+}
+
+// dataSize returns the size of the table data in a database, or 0 if it
+// can't be queried.
+func dataSize(db *sql.DB, database string) int64 {
+	var size int64
+	err := db.QueryRow("SELECT COALESCE(SUM(data_length), 0) FROM information_schema.tables WHERE table_schema = ?", database).Scan(&size)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+func binlogEnabled(db *sql.DB) bool {
+	var enabled int
+	if err := db.QueryRow("SELECT @@log_bin").Scan(&enabled); err != nil {
+		return false
+	}
+	return enabled == 1
+}
+
+// sourceDataFlag picks --source-data=2 on clients that have it (MySQL
+// 8.0.26+) and falls back to the deprecated --master-data=2 otherwise.
+// mariadb-dump only has --master-data.
+func sourceDataFlag(b backend.Backend, dumpTool string) string {
+	if strings.Contains(toolHelp(b, dumpTool), "--source-data") {
+		return "--source-data=2"
+	}
+	return "--master-data=2"
+}
+
+// readBinlogPosition finds the binlog coordinates near the top of a dump.
+func readBinlogPosition(dumpPath string) (*manifest.BinlogPosition, error) {
+	file, err := os.Open(dumpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lines := 0; scanner.Scan() && lines < 100; lines++ {
+		match := binlogCoordinates.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		position, err := strconv.ParseInt(match[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &manifest.BinlogPosition{File: match[1], Position: position}, nil
+	}
+
+	return nil, fmt.Errorf("no binlog coordinates in %s", dumpPath)
 }
@@ -0,0 +1,230 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tiyfiy/BackItUp/internal/backend"
+	"github.com/tiyfiy/BackItUp/internal/tool"
+)
+
+// BinlogDir is where FollowBinlogs stores raw binary log files.
+const BinlogDir = "BACKUP/mysql/binlog"
+
+// FollowBinlogs continuously copies binary logs from the server into
+// BinlogDir with mysqlbinlog --raw. It resumes from the newest binlog
+// already collected, or from the server's oldest binlog on first run, and
+// runs until mysqlbinlog exits.
+func FollowBinlogs(db *sql.DB, host, port, user, password string, opts ConnOptions) {
+	err := os.MkdirAll(BinlogDir, 0755)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	start, err := resumeBinlog(db)
+	if err != nil {
+		log.Fatal("Failed to find a binlog to start from:", err)
+	}
+
+	flavor, err := ServerFlavor(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("📜 Collecting binary logs from %s into %s (starting at %s)\n", host, BinlogDir, start)
+
+	// mysqlbinlog writes the binlogs into BinlogDir, so it always runs here
+	opts.Exec = nil
+	binlogTool := ToolsFor(flavor, backend.Local).Binlog
+	args := []string{
+		"--read-from-remote-server",
+		"--raw",
+		"--stop-never",
+	}
+	args = append(args, ClientArgs(binlogTool, host, port, user, password, opts)...)
+	args = append(args,
+		"--result-file", BinlogDir+string(os.PathSeparator),
+		start,
+	)
+
+	cmd := exec.Command(binlogTool, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = tool.Run(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// resumeBinlog returns the newest collected binlog, which mysqlbinlog
+// downloads again in full, or the oldest binlog still on the server.
+func resumeBinlog(db *sql.DB) (string, error) {
+	if collected := CollectedBinlogs(""); len(collected) > 0 {
+		return filepath.Base(collected[len(collected)-1]), nil
+	}
+
+	rows, err := db.Query("SHOW BINARY LOGS")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	for rows.Next() {
+		// The column count differs between server versions
+		values := make([]sql.RawBytes, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		return string(values[0]), nil
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("binary logging is not enabled on the server")
+}
+
+// CollectedBinlogs lists collected binlog files in order, starting at the
+// file named from (or all of them when from is empty).
+func CollectedBinlogs(from string) []string {
+	entries, err := os.ReadDir(BinlogDir)
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".index") {
+			continue
+		}
+		if from != "" && binlogLess(name, from) {
+			continue
+		}
+		files = append(files, filepath.Join(BinlogDir, name))
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return binlogLess(filepath.Base(files[i]), filepath.Base(files[j]))
+	})
+	return files
+}
+
+// binlogLess orders binlog names by their sequence number. It is only
+// zero-padded to six digits, so binlog.1000000 follows binlog.999999.
+func binlogLess(a, b string) bool {
+	baseA, seqA := binlogSequence(a)
+	baseB, seqB := binlogSequence(b)
+	if baseA != baseB || seqA < 0 || seqB < 0 {
+		return a < b
+	}
+	return seqA < seqB
+}
+
+// binlogSequence splits a binlog name into its base name and sequence
+// number, which is -1 when the name doesn't end in one.
+func binlogSequence(name string) (string, int64) {
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		return name, -1
+	}
+	seq, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil {
+		return name, -1
+	}
+	return name[:i], seq
+}
+
+// ReplayBinlogs applies collected binlogs to the database, starting at the
+// given position and stopping at stopDatetime (mysqlbinlog format,
+// "2006-01-02 15:04:05"). flavor picks the client tools. When targetDB
+// differs from database, only the changes to database are replayed, into
+// targetDB instead.
+func ReplayBinlogs(flavor, host, port, user, password string, opts ConnOptions, startFile string, startPosition int64, stopDatetime, database, targetDB string) error {
+	files := CollectedBinlogs(startFile)
+	if len(files) == 0 || filepath.Base(files[0]) != startFile {
+		return fmt.Errorf("binlog %s has not been collected, run: ./BackItUp mysql binlog-follow", startFile)
+	}
+
+	// The binlogs are decoded here and applied where the client tools run
+	binlogTool := ToolsFor(flavor, backend.Local).Binlog
+	client := ToolsFor(flavor, opts.Backend()).Client
+	decode := exec.Command(binlogTool, replayArgs(flavor, startPosition, stopDatetime, database, targetDB, files)...)
+	apply := opts.Backend().Command(ClientEnv(password, opts), client, ClientArgs(client, host, port, user, password, opts)...)
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	decode.Stdout = writer
+	decode.Stderr = os.Stderr
+	backend.SetStdin(apply, reader)
+	apply.Stdout = os.Stdout
+	apply.Stderr = os.Stderr
+	decodeOutput := tool.Capture(decode)
+	applyOutput := tool.Capture(apply)
+
+	if err := apply.Start(); err != nil {
+		reader.Close()
+		writer.Close()
+		return err
+	}
+
+	// With kubectl the reader is copied in after the variables rather than
+	// handed over, so it stays open until mysql is done. Closing it then
+	// stops mysqlbinlog if mysql exits early.
+	applied := make(chan error, 1)
+	go func() {
+		err := apply.Wait()
+		reader.Close()
+		applied <- err
+	}()
+
+	decodeErr := decode.Run()
+	// Closing our end lets mysql see EOF once mysqlbinlog is done
+	writer.Close()
+	applyErr := <-applied
+
+	if decodeErr != nil {
+		return decodeOutput.Check(decodeErr)
+	}
+	return applyOutput.Check(applyErr)
+}
+
+// replayArgs returns the mysqlbinlog arguments that decode the changes to
+// database from files, renamed into targetDB when it differs. The other
+// schemas' events are left out.
+func replayArgs(flavor string, startPosition int64, stopDatetime, database, targetDB string, files []string) []string {
+	args := []string{
+		fmt.Sprintf("--start-position=%d", startPosition),
+		fmt.Sprintf("--stop-datetime=%s", stopDatetime),
+	}
+	if flavor == FlavorMySQL {
+		// The server has already executed these GTIDs and would skip the
+		// replayed transactions, so they are replayed as new ones
+		args = append(args, "--skip-gtids")
+	}
+	if targetDB != database {
+		// --database filters on the rewritten name
+		args = append(args, fmt.Sprintf("--rewrite-db=%s->%s", database, targetDB))
+	}
+	args = append(args, "--database="+targetDB)
+	return append(args, files...)
+}
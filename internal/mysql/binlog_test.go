@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBinlogLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"binlog.000001", "binlog.000002", true},
+		{"binlog.000002", "binlog.000001", false},
+		{"binlog.999999", "binlog.1000000", true},
+		{"binlog.1000000", "binlog.999999", false},
+		{"binlog.000001", "binlog.000001", false},
+		{"mysql-bin.000009", "mysql-bin.000010", true},
+		{"a.000002", "b.000001", true},
+		{"binlog.index", "binlog.000001", false},
+	}
+
+	for _, tt := range tests {
+		if got := binlogLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("binlogLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCollectedBinlogs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(BinlogDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"binlog.1000001", "binlog.999998", "binlog.1000000", "binlog.999999", "binlog.index", ".binlog.1000002.partial"} {
+		if err := os.WriteFile(filepath.Join(BinlogDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		from string
+		want []string
+	}{
+		{"", []string{"binlog.999998", "binlog.999999", "binlog.1000000", "binlog.1000001"}},
+		{"binlog.999999", []string{"binlog.999999", "binlog.1000000", "binlog.1000001"}},
+		{"binlog.1000001", []string{"binlog.1000001"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, file := range CollectedBinlogs(tt.from) {
+			got = append(got, filepath.Base(file))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CollectedBinlogs(%q) = %v, want %v", tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestReplayArgs(t *testing.T) {
+	files := []string{"BACKUP/mysql/binlog/binlog.000002", "BACKUP/mysql/binlog/binlog.000003"}
+
+	tests := []struct {
+		name               string
+		flavor             string
+		database, targetDB string
+		want               []string
+	}{
+		{
+			name:     "same database",
+			flavor:   FlavorMySQL,
+			database: "shop",
+			targetDB: "shop",
+			want: []string{"--start-position=157", "--stop-datetime=2026-10-17 14:32:00", "--skip-gtids",
+				"--database=shop", files[0], files[1]},
+		},
+		{
+			name:     "into a copy",
+			flavor:   FlavorMySQL,
+			database: "shop",
+			targetDB: "shop_copy",
+			want: []string{"--start-position=157", "--stop-datetime=2026-10-17 14:32:00", "--skip-gtids",
+				"--rewrite-db=shop->shop_copy", "--database=shop_copy", files[0], files[1]},
+		},
+		{
+			name:     "mariadb",
+			flavor:   FlavorMariaDB,
+			database: "shop",
+			targetDB: "shop",
+			want: []string{"--start-position=157", "--stop-datetime=2026-10-17 14:32:00",
+				"--database=shop", files[0], files[1]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := replayArgs(tt.flavor, 157, "2026-10-17 14:32:00", tt.database, tt.targetDB, files)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("replayArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,21 +3,173 @@ package mysql
 import (
 	"database/sql"
 	"fmt"
+	"net"
+	"strings"
 
-	_ "github.com/go-sql-driver/mysql"
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/tiyfiy/BackItUp/internal/backend"
+	"github.com/tiyfiy/BackItUp/internal/tlsconf"
 )
 
-func Connection(host, port, user, password, database string) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", user, password, host, port, database)
+// ConnOptions are the connection settings besides the address and
+// credentials. They apply to the driver and, as far as they can, to the
+// client tools.
+type ConnOptions struct {
+	// Socket is the path of a unix socket to connect through instead of
+	// host and port.
+	Socket string
+	TLS    tlsconf.Config
+	// Params are extra driver parameters in DSN form, e.g.
+	// "timeout=5s&charset=utf8mb4". The client tools don't take them.
+	Params string
+	// Exec is where the client tools run, this machine when nil.
+	Exec backend.Backend
+}
 
-	db, err := sql.Open("mysql", dsn)
+// Backend returns where the client tools run.
+func (o ConnOptions) Backend() backend.Backend {
+	if o.Exec == nil {
+		return backend.Local
+	}
+	return o.Exec
+}
+
+func Connection(host, port, user, password, database string, opts ConnOptions) (*sql.DB, error) {
+	cfg := driver.NewConfig()
+	cfg.User = user
+	cfg.Passwd = password
+	cfg.DBName = database
+	if opts.Socket != "" {
+		cfg.Net = "unix"
+		cfg.Addr = opts.Socket
+	} else {
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(host, port)
+	}
+
+	if opts.Params != "" {
+		var err error
+		cfg, err = driver.ParseDSN(cfg.FormatDSN() + "?" + strings.TrimPrefix(opts.Params, "?"))
+		if err != nil {
+			return nil, fmt.Errorf("MySQL parameters: %w", err)
+		}
+	}
+
+	switch opts.TLS.Mode {
+	case "", tlsconf.ModeDisable:
+		// Plain connections unless the parameters asked for TLS
+	default:
+		tlsCfg, err := opts.TLS.Client(host)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLS = tlsCfg
+		cfg.AllowFallbackToPlaintext = opts.TLS.Mode == tlsconf.ModePrefer
+	}
+
+	connector, err := driver.NewConnector(cfg)
 	if err != nil {
 		return nil, err
 	}
+	db := sql.OpenDB(connector)
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// ClientArgs returns the arguments that connect the client tool name
+// (mysqldump, mysql, mysqlbinlog or their mariadb counterparts) to the
+// server. Tools in a container get the password from ClientEnv instead.
+func ClientArgs(name, host, port, user, password string, opts ConnOptions) []string {
+	var args []string
+	if opts.Socket != "" {
+		args = append(args, "--socket", opts.Socket)
+	} else {
+		args = append(args, "-h", host, "-P", port)
+	}
+	args = append(args, "-u", user)
+	if opts.Backend().Local() {
+		args = append(args, fmt.Sprintf("-p%s", password))
+	}
+	return append(args, tlsArgs(opts.Backend(), name, host, opts.TLS)...)
+}
+
+// ClientEnv returns the variables that go with ClientArgs. docker and
+// kubectl would show a -p argument in ps, so tools in a container get the
+// password as MYSQL_PWD.
+func ClientEnv(password string, opts ConnOptions) []string {
+	if opts.Backend().Local() {
+		return nil
+	}
+	return []string{"MYSQL_PWD=" + password}
+}
+
+// tlsArgs maps the TLS settings to the tool's options. MySQL's tools take
+// --ssl-mode; MariaDB's have --ssl and --ssl-verify-server-cert instead.
+// Neither can check the certificate against another name than the host,
+// so with a server name set, verify-full only checks the CA.
+func tlsArgs(b backend.Backend, name, host string, t tlsconf.Config) []string {
+	var args []string
+
+	mode := t.Mode
+	if mode == tlsconf.ModeVerifyFull && t.ServerName != "" && t.ServerName != host {
+		mode = tlsconf.ModeVerifyCA
+	}
+
+	if strings.Contains(toolHelp(b, name), "--ssl-mode") {
+		sslModes := map[string]string{
+			tlsconf.ModeDisable:    "DISABLED",
+			tlsconf.ModePrefer:     "PREFERRED",
+			tlsconf.ModeRequire:    "REQUIRED",
+			tlsconf.ModeVerifyCA:   "VERIFY_CA",
+			tlsconf.ModeVerifyFull: "VERIFY_IDENTITY",
+		}
+		if sslMode, ok := sslModes[mode]; ok {
+			args = append(args, "--ssl-mode="+sslMode)
+		}
+	} else {
+		switch mode {
+		case tlsconf.ModeDisable:
+			args = append(args, "--skip-ssl")
+		case tlsconf.ModeRequire:
+			args = append(args, "--ssl")
+		case tlsconf.ModeVerifyCA, tlsconf.ModeVerifyFull:
+			args = append(args, "--ssl", "--ssl-verify-server-cert")
+		}
+	}
+
+	if t.CA != "" {
+		args = append(args, "--ssl-ca="+t.CA)
+	}
+	if t.Cert != "" {
+		args = append(args, "--ssl-cert="+t.Cert)
+	}
+	if t.Key != "" {
+		args = append(args, "--ssl-key="+t.Key)
+	}
+	return args
+}
+
+// EnsureDatabase creates the database if it doesn't exist yet.
+func EnsureDatabase(host, port, user, password, database string, opts ConnOptions) error {
+	db, err := Connection(host, port, user, password, "", opts)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", strings.ReplaceAll(database, "`", "``")))
+	return err
+}
+
+// ServerVersion returns the server's version string, e.g. "8.0.36" or
+// "10.11.6-MariaDB".
+func ServerVersion(db *sql.DB) (string, error) {
+	var version string
+	err := db.QueryRow("SELECT VERSION()").Scan(&version)
+	return version, err
+}
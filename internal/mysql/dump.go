@@ -0,0 +1,674 @@
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+)
+
+// insertBatchSize is roughly how many bytes of rows go into one INSERT
+// statement, like mysqldump's default net_buffer_length. It stays far below
+// the default max_allowed_packet.
+const insertBatchSize = 1 << 20
+
+// dumpHeader saves and relaxes the session settings for the restore, the
+// way mysqldump does, so dumps from both can be restored the same way.
+// TIMESTAMP values are dumped in UTC.
+const dumpHeader = `/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+/*!40101 SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS */;
+/*!40101 SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION */;
+/*!50503 SET NAMES utf8mb4 */;
+/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;
+/*!40103 SET TIME_ZONE='+00:00' */;
+/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;
+/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;
+/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;
+/*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;
+`
+
+const dumpFooter = `/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;
+
+/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;
+/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;
+/*!40014 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS */;
+/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;
+/*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;
+/*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;
+/*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;
+`
+
+// dumpObject is a table, view or (MariaDB) sequence in the database being
+// dumped.
+type dumpObject struct {
+	name     string
+	view     bool
+	sequence bool
+	// versioned is set for MariaDB system-versioned tables.
+	versioned bool
+}
+
+// dumper writes a mysqldump-compatible script from a single connection
+// holding a consistent snapshot.
+type dumper struct {
+	ctx      context.Context
+	conn     *sql.Conn
+	database string
+	kind     string
+	w        *bufio.Writer
+}
+
+// dumpNative dumps a database without mysqldump, reading everything
+// through db inside one consistent snapshot. The script uses the same
+// layout and section comments as mysqldump, so it restores with the mysql
+// client and works with partial restores. When binary logging is on, the
+// snapshot's binlog coordinates are written as a commented CHANGE MASTER
+// statement, like --source-data=2.
+func dumpNative(db *sql.DB, database string, w io.Writer, opts BackupOptions) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	d := &dumper{
+		ctx:      ctx,
+		conn:     conn,
+		database: database,
+		kind:     opts.Kind,
+		w:        bufio.NewWriterSize(w, 1<<20),
+	}
+
+	for _, statement := range []string{
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"SET NAMES utf8mb4",
+		"SET time_zone = '+00:00'",
+		"USE " + quoteIdent(database),
+	} {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	position, err := d.startSnapshot(binlogEnabled(db))
+	if err != nil {
+		return err
+	}
+	// The snapshot is read-only; rolling back just ends it
+	defer conn.ExecContext(ctx, "ROLLBACK")
+
+	version, _ := ServerVersion(db)
+	fmt.Fprintf(d.w, "-- BackItUp native dump\n--\n-- Database: %s\n-- Server version\t%s\n", database, version)
+	fmt.Fprintf(d.w, "-- ------------------------------------------------------\n\n%s", dumpHeader)
+	if position != nil {
+		fmt.Fprintf(d.w, "\n--\n-- Position to start replication or point-in-time recovery from\n--\n\n")
+		fmt.Fprintf(d.w, "-- CHANGE MASTER TO MASTER_LOG_FILE='%s', MASTER_LOG_POS=%d;\n", position.File, position.Position)
+	}
+
+	objects, err := d.objects(opts.Include, opts.Exclude)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		if object.view {
+			if d.kind != manifest.KindData {
+				if err := d.viewPlaceholder(object.name); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if object.sequence {
+			if err := d.sequence(object.name); err != nil {
+				return fmt.Errorf("sequence %s: %w", object.name, err)
+			}
+			continue
+		}
+		if object.versioned && d.kind != manifest.KindSchema {
+			fmt.Printf("   Note: %s is system-versioned, only its current rows are dumped\n", object.name)
+		}
+		if err := d.table(object.name); err != nil {
+			return fmt.Errorf("table %s: %w", object.name, err)
+		}
+	}
+
+	if d.kind != manifest.KindData {
+		// Stored programs belong to the database as a whole, so they are left
+		// out of dumps limited to some tables
+		if len(opts.Include) == 0 {
+			if err := d.routines(); err != nil {
+				return err
+			}
+			if err := d.events(); err != nil {
+				return err
+			}
+		}
+
+		// Views are created last, once everything they select from exists
+		for _, object := range objects {
+			if object.view {
+				if err := d.view(object.name); err != nil {
+					return fmt.Errorf("view %s: %w", object.name, err)
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(d.w, "%s\n-- Dump completed on %s\n", dumpFooter, time.Now().Format("2006-01-02 15:04:05"))
+	return d.w.Flush()
+}
+
+// startSnapshot starts the transaction every read goes through. With
+// binary logging on, tables are briefly locked so the binlog position
+// matches the snapshot exactly; without the RELOAD privilege the dump goes
+// on without a position.
+func (d *dumper) startSnapshot(binlog bool) (*manifest.BinlogPosition, error) {
+	locked := false
+	if binlog {
+		if _, err := d.conn.ExecContext(d.ctx, "FLUSH TABLES WITH READ LOCK"); err == nil {
+			locked = true
+		} else {
+			fmt.Printf("   Warning: can't lock tables for the binlog position (%v), recording none\n", err)
+		}
+	}
+
+	if _, err := d.conn.ExecContext(d.ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, nil
+	}
+	defer d.conn.ExecContext(d.ctx, "UNLOCK TABLES")
+
+	// SHOW MASTER STATUS was renamed in MySQL 8.4
+	for _, query := range []string{"SHOW BINARY LOG STATUS", "SHOW MASTER STATUS"} {
+		row, err := d.queryRow(query)
+		if err != nil || row == nil {
+			continue
+		}
+		var position int64
+		fmt.Sscan(row["Position"].String, &position)
+		return &manifest.BinlogPosition{File: row["File"].String, Position: position}, nil
+	}
+	return nil, nil
+}
+
+// objects lists the tables and views to dump, after the include and
+// exclude filters.
+func (d *dumper) objects(include, exclude []string) ([]dumpObject, error) {
+	rows, err := d.conn.QueryContext(d.ctx, "SHOW FULL TABLES")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []dumpObject
+	for rows.Next() {
+		var name, tableType string
+		if err := rows.Scan(&name, &tableType); err != nil {
+			return nil, err
+		}
+		if len(include) > 0 && !matchAny(include, name) || matchAny(exclude, name) {
+			continue
+		}
+		objects = append(objects, dumpObject{
+			name:      name,
+			view:      tableType == "VIEW",
+			sequence:  tableType == "SEQUENCE",
+			versioned: tableType == "SYSTEM VERSIONED",
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(objects) == 0 && len(include) > 0 {
+		return nil, fmt.Errorf("no tables in %s match the include filter", d.database)
+	}
+	return objects, nil
+}
+
+// table writes a table's definition, rows and triggers.
+func (d *dumper) table(name string) error {
+	if d.kind != manifest.KindData {
+		row, err := d.queryRow("SHOW CREATE TABLE " + quoteIdent(name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(d.w, "\n--\n-- Table structure for table %s\n--\n\n", quoteIdent(name))
+		fmt.Fprintf(d.w, "DROP TABLE IF EXISTS %s;\n%s;\n", quoteIdent(name), row["Create Table"].String)
+	}
+
+	if d.kind != manifest.KindSchema {
+		if err := d.rows(name); err != nil {
+			return err
+		}
+	}
+
+	if d.kind != manifest.KindData {
+		return d.triggers(name)
+	}
+	return nil
+}
+
+// rows writes a table's rows as batched multi-row INSERTs.
+func (d *dumper) rows(table string) error {
+	columns, complete, err := d.insertColumns(table)
+	if err != nil {
+		return err
+	}
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdent(column)
+	}
+
+	rows, err := d.conn.QueryContext(d.ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), quoteIdent(table)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(d.w, "\n--\n-- Dumping data for table %s\n--\n\n", quoteIdent(table))
+	fmt.Fprintf(d.w, "LOCK TABLES %s WRITE;\n/*!40000 ALTER TABLE %s DISABLE KEYS */;\n", quoteIdent(table), quoteIdent(table))
+
+	insert := "INSERT INTO " + quoteIdent(table)
+	if !complete {
+		// Generated and invisible columns can't or won't be inserted into
+		insert += " (" + strings.Join(quoted, ",") + ")"
+	}
+	insert += " VALUES "
+
+	values := make([]sql.RawBytes, len(columns))
+	scan := make([]any, len(columns))
+	for i := range values {
+		scan[i] = &values[i]
+	}
+
+	var batch strings.Builder
+	for rows.Next() {
+		if err := rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		if batch.Len() == 0 {
+			batch.WriteString(insert)
+		} else {
+			batch.WriteByte(',')
+		}
+		batch.WriteByte('(')
+		for i, value := range values {
+			if i > 0 {
+				batch.WriteByte(',')
+			}
+			writeValue(&batch, value, types[i].DatabaseTypeName())
+		}
+		batch.WriteByte(')')
+
+		if batch.Len() >= insertBatchSize {
+			batch.WriteString(";\n")
+			if _, err := d.w.WriteString(batch.String()); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if batch.Len() > 0 {
+		batch.WriteString(";\n")
+		if _, err := d.w.WriteString(batch.String()); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(d.w, "/*!40000 ALTER TABLE %s ENABLE KEYS */;\nUNLOCK TABLES;\n", quoteIdent(table))
+	return err
+}
+
+// insertColumns returns the columns to dump, leaving out generated ones and
+// the period columns of system-versioned tables, which the server fills in
+// itself, and whether that is every column in table order so the INSERTs
+// can omit the column list.
+func (d *dumper) insertColumns(table string) ([]string, bool, error) {
+	rows, err := d.conn.QueryContext(d.ctx,
+		"SELECT COLUMN_NAME, EXTRA FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION",
+		d.database, table)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	complete := true
+	for rows.Next() {
+		var name, extra string
+		if err := rows.Scan(&name, &extra); err != nil {
+			return nil, false, err
+		}
+		extra = strings.ToUpper(extra)
+		if strings.Contains(extra, "VIRTUAL GENERATED") || strings.Contains(extra, "STORED GENERATED") || strings.Contains(extra, "PERSISTENT GENERATED") ||
+			strings.Contains(extra, "ROW START") || strings.Contains(extra, "ROW END") {
+			complete = false
+			continue
+		}
+		if strings.Contains(extra, "INVISIBLE") {
+			complete = false
+		}
+		columns = append(columns, name)
+	}
+	return columns, complete, rows.Err()
+}
+
+// triggers writes the triggers defined on a table, with the SQL mode they
+// were created under.
+func (d *dumper) triggers(table string) error {
+	rows, err := d.conn.QueryContext(d.ctx,
+		"SELECT TRIGGER_NAME FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = ? AND EVENT_OBJECT_TABLE = ? ORDER BY ACTION_ORDER",
+		d.database, table)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		row, err := d.queryRow("SHOW CREATE TRIGGER " + quoteIdent(name))
+		if err != nil {
+			return err
+		}
+		if err := d.storedProgram(row["sql_mode"].String, row["SQL Original Statement"].String, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// routines writes the database's stored procedures and functions.
+func (d *dumper) routines() error {
+	rows, err := d.conn.QueryContext(d.ctx,
+		"SELECT ROUTINE_TYPE, ROUTINE_NAME FROM information_schema.ROUTINES WHERE ROUTINE_SCHEMA = ? ORDER BY ROUTINE_TYPE, ROUTINE_NAME",
+		d.database)
+	if err != nil {
+		return err
+	}
+	type routine struct{ kind, name string }
+	var routines []routine
+	for rows.Next() {
+		var r routine
+		if err := rows.Scan(&r.kind, &r.name); err != nil {
+			rows.Close()
+			return err
+		}
+		routines = append(routines, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(routines) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(d.w, "\n--\n-- Dumping routines for database '%s'\n--\n", d.database)
+	for _, r := range routines {
+		// "Create Procedure" or "Create Function"
+		column := "Create " + strings.ToUpper(r.kind[:1]) + strings.ToLower(r.kind[1:])
+		row, err := d.queryRow(fmt.Sprintf("SHOW CREATE %s %s", r.kind, quoteIdent(r.name)))
+		if err != nil {
+			return err
+		}
+		if !row[column].Valid {
+			return fmt.Errorf("no privilege to read the definition of %s %s", strings.ToLower(r.kind), r.name)
+		}
+		drop := fmt.Sprintf("DROP %s IF EXISTS %s;\n", r.kind, quoteIdent(r.name))
+		if err := d.storedProgram(row["sql_mode"].String, row[column].String, drop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// events writes the database's scheduled events.
+func (d *dumper) events() error {
+	rows, err := d.conn.QueryContext(d.ctx,
+		"SELECT EVENT_NAME FROM information_schema.EVENTS WHERE EVENT_SCHEMA = ? ORDER BY EVENT_NAME", d.database)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(d.w, "\n--\n-- Dumping events for database '%s'\n--\n", d.database)
+	for _, name := range names {
+		row, err := d.queryRow("SHOW CREATE EVENT " + quoteIdent(name))
+		if err != nil {
+			return err
+		}
+		drop := fmt.Sprintf("DROP EVENT IF EXISTS %s;\n", quoteIdent(name))
+		if err := d.storedProgram(row["sql_mode"].String, row["Create Event"].String, drop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storedProgram writes a trigger, routine or event definition. Their
+// bodies contain semicolons, so the mysql client's delimiter is switched
+// around them. The writer keeps its first error, so the last write reports
+// any of them.
+func (d *dumper) storedProgram(sqlMode, definition, drop string) error {
+	fmt.Fprintf(d.w, "/*!50003 SET @saved_sql_mode = @@sql_mode */;\n")
+	fmt.Fprintf(d.w, "/*!50003 SET sql_mode = %s */;\n", quoteString(sqlMode))
+	d.w.WriteString(drop)
+	fmt.Fprintf(d.w, "DELIMITER ;;\n%s ;;\nDELIMITER ;\n", definition)
+	_, err := fmt.Fprintf(d.w, "/*!50003 SET sql_mode = @saved_sql_mode */;\n")
+	return err
+}
+
+// sequence writes a MariaDB sequence's definition and its next value, the
+// way mariadb-dump does.
+func (d *dumper) sequence(name string) error {
+	if d.kind != manifest.KindData {
+		row, err := d.queryRow("SHOW CREATE SEQUENCE " + quoteIdent(name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(d.w, "\n--\n-- Sequence structure for %s\n--\n\n", quoteIdent(name))
+		fmt.Fprintf(d.w, "DROP SEQUENCE IF EXISTS %s;\n%s;\n", quoteIdent(name), row["Create Table"].String)
+	}
+
+	if d.kind != manifest.KindSchema {
+		var next string
+		err := d.conn.QueryRowContext(d.ctx, "SELECT next_not_cached_value FROM "+quoteIdent(name)).Scan(&next)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(d.w, "DO SETVAL(%s, %s, 0);\n", quoteIdent(name), next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// viewPlaceholder creates a stand-in for a view so views selecting from
+// other views can be created in any order; view replaces it at the end.
+func (d *dumper) viewPlaceholder(name string) error {
+	rows, err := d.conn.QueryContext(d.ctx,
+		"SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION",
+		d.database, name)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return err
+		}
+		columns = append(columns, "1 AS "+quoteIdent(column))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(columns) == 0 {
+		columns = []string{"1 AS `placeholder`"}
+	}
+
+	fmt.Fprintf(d.w, "\n--\n-- Temporary view structure for view %s\n--\n\n", quoteIdent(name))
+	fmt.Fprintf(d.w, "DROP TABLE IF EXISTS %s;\n/*!50001 DROP VIEW IF EXISTS %s*/;\n", quoteIdent(name), quoteIdent(name))
+	_, err = fmt.Fprintf(d.w, "/*!50001 CREATE VIEW %s AS SELECT %s */;\n", quoteIdent(name), strings.Join(columns, ", "))
+	return err
+}
+
+// view writes the final definition of a view.
+func (d *dumper) view(name string) error {
+	row, err := d.queryRow("SHOW CREATE VIEW " + quoteIdent(name))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(d.w, "\n--\n-- Final view structure for view %s\n--\n\n", quoteIdent(name))
+	fmt.Fprintf(d.w, "/*!50001 DROP VIEW IF EXISTS %s*/;\n", quoteIdent(name))
+	_, err = fmt.Fprintf(d.w, "%s;\n", row["Create View"].String)
+	return err
+}
+
+// queryRow runs a SHOW statement and returns its first row by column name,
+// or nil if it returned no rows. SHOW statements differ in their columns
+// between versions, so they aren't scanned by position.
+func (d *dumper) queryRow(query string) (map[string]sql.NullString, error) {
+	rows, err := d.conn.QueryContext(d.ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]sql.NullString, len(columns))
+	scan := make([]any, len(columns))
+	for i := range values {
+		scan[i] = &values[i]
+	}
+	if err := rows.Scan(scan...); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row, nil
+}
+
+// writeValue writes a column value as an SQL literal: numbers as they are,
+// binary data as hex and everything else as an escaped string.
+func writeValue(b *strings.Builder, value sql.RawBytes, typeName string) {
+	if value == nil {
+		b.WriteString("NULL")
+		return
+	}
+
+	switch strings.TrimPrefix(typeName, "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+		b.Write(value)
+	case "BIT":
+		b.WriteString("b'")
+		for _, c := range value {
+			fmt.Fprintf(b, "%08b", c)
+		}
+		b.WriteByte('\'')
+	case "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "GEOMETRY", "VECTOR":
+		if len(value) == 0 {
+			b.WriteString("''")
+			return
+		}
+		b.WriteString("0x")
+		b.WriteString(hex.EncodeToString(value))
+	default:
+		b.WriteString(quoteString(string(value)))
+	}
+}
+
+// quoteString quotes a string literal, escaping it like
+// mysql_real_escape_string.
+func quoteString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\'':
+			b.WriteString(`\'`)
+		case '"':
+			b.WriteString(`\"`)
+		case 0x1a:
+			b.WriteString(`\Z`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// quoteIdent quotes an identifier with backticks.
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
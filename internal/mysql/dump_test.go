@@ -0,0 +1,124 @@
+package mysql
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    sql.RawBytes
+		typeName string
+		want     string
+	}{
+		{"NULL", nil, "VARCHAR", "NULL"},
+		{"NULL number", nil, "INT", "NULL"},
+		{"empty string", sql.RawBytes{}, "VARCHAR", "''"},
+		{"int", sql.RawBytes("-42"), "INT", "-42"},
+		{"unsigned bigint", sql.RawBytes("18446744073709551615"), "UNSIGNED BIGINT", "18446744073709551615"},
+		{"decimal", sql.RawBytes("12.50"), "DECIMAL", "12.50"},
+		{"double", sql.RawBytes("1.5e-7"), "DOUBLE", "1.5e-7"},
+		{"year", sql.RawBytes("2026"), "YEAR", "2026"},
+		{"bit", sql.RawBytes{0x05}, "BIT", "b'00000101'"},
+		{"bit(16)", sql.RawBytes{0x01, 0x80}, "BIT", "b'0000000110000000'"},
+		{"binary", sql.RawBytes{0x00, 0x27, 0x5c, 0xff}, "VARBINARY", "0x00275cff"},
+		{"blob", sql.RawBytes("it's"), "BLOB", "0x69742773"},
+		{"empty blob", sql.RawBytes{}, "LONGBLOB", "''"},
+		{"geometry", sql.RawBytes{0x00, 0x00, 0x00, 0x00, 0x01}, "GEOMETRY", "0x0000000001"},
+		{"json", sql.RawBytes(`{"name": "O'Brien", "path": "C:\\tmp"}`), "JSON", `'{\"name\": \"O\'Brien\", \"path\": \"C:\\\\tmp\"}'`},
+		{"datetime", sql.RawBytes("2026-10-17 14:32:00"), "DATETIME", "'2026-10-17 14:32:00'"},
+		{"text with special characters", sql.RawBytes("a\x00b\nc\r\x1a"), "TEXT", `'a\0b\nc\r\Z'`},
+		{"utf-8", sql.RawBytes("größe ✓"), "VARCHAR", "'größe ✓'"},
+		{"enum", sql.RawBytes("it's"), "ENUM", `'it\'s'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			writeValue(&b, tt.value, tt.typeName)
+			if got := b.String(); got != tt.want {
+				t.Errorf("writeValue(%q, %s) = %s, want %s", tt.value, tt.typeName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "''"},
+		{"plain", "'plain'"},
+		{"it's", `'it\'s'`},
+		{`say "hi"`, `'say \"hi\"'`},
+		{`back\slash`, `'back\\slash'`},
+		{"nul\x00byte", `'nul\0byte'`},
+		{"line\nbreak\r", `'line\nbreak\r'`},
+		{"ctrl-z\x1a", `'ctrl-z\Z'`},
+		{"tab\tstays", "'tab\tstays'"},
+		{`\'; DROP TABLE users; --`, `'\\\'; DROP TABLE users; --'`},
+	}
+
+	for _, tt := range tests {
+		if got := quoteString(tt.in); got != tt.want {
+			t.Errorf("quoteString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"orders", "`orders`"},
+		{"order items", "`order items`"},
+		{"we`ird", "`we``ird`"},
+	}
+
+	for _, tt := range tests {
+		if got := quoteIdent(tt.in); got != tt.want {
+			t.Errorf("quoteIdent(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestStoredProgramWriteError(t *testing.T) {
+	d := &dumper{w: bufio.NewWriterSize(failingWriter{}, 16)}
+
+	err := d.storedProgram("STRICT_TRANS_TABLES", "CREATE TRIGGER t BEFORE INSERT ON orders FOR EACH ROW SET NEW.total = 0", "")
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("storedProgram() error = %v, want the write error", err)
+	}
+}
+
+func TestStoredProgram(t *testing.T) {
+	var out strings.Builder
+	d := &dumper{w: bufio.NewWriter(&out)}
+
+	err := d.storedProgram("NO_ZERO_DATE", "CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "DROP PROCEDURE IF EXISTS `p`;\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "/*!50003 SET @saved_sql_mode = @@sql_mode */;\n" +
+		"/*!50003 SET sql_mode = 'NO_ZERO_DATE' */;\n" +
+		"DROP PROCEDURE IF EXISTS `p`;\n" +
+		"DELIMITER ;;\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END ;;\nDELIMITER ;\n" +
+		"/*!50003 SET sql_mode = @saved_sql_mode */;\n"
+	if out.String() != want {
+		t.Errorf("storedProgram() wrote:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
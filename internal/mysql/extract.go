@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Section markers mysqldump writes before each object. Everything up to the
+// first marker is the dump header (session settings) and is always kept.
+const (
+	tableStructureMarker = "-- Table structure for table `"
+	tableDataMarker      = "-- Dumping data for table `"
+)
+
+// otherSectionMarkers start sections that don't belong to a single table
+// (views, sequences, routines, events). Partial restores skip them.
+var otherSectionMarkers = []string{
+	"-- Temporary view structure for view `",
+	"-- Temporary table structure for view `",
+	"-- Final view structure for view `",
+	"-- Sequence structure for `",
+	"-- Dumping routines for database ",
+	"-- Dumping events for database ",
+}
+
+// ExtractTables copies the statements for the given tables (glob patterns
+// such as "audit_*" are allowed) from a mysqldump script to w, along with
+// the dump's header and footer settings. Triggers are kept with their
+// table. The dump is streamed line by line, so it works on dumps of any
+// size.
+func ExtractTables(r io.Reader, w io.Writer, tables []string) error {
+	reader := bufio.NewReaderSize(r, 1<<20)
+	writer := bufio.NewWriterSize(w, 1<<20)
+
+	keep := true
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if table, ok := sectionTable(line); ok {
+				keep = table != "" && matchAny(tables, table)
+			}
+
+			// The footer restores the settings saved in the header
+			if keep || isFooterLine(line) {
+				if _, werr := writer.WriteString(line); werr != nil {
+					return werr
+				}
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// sectionTable reports whether line starts a new dump section, and the table
+// the section belongs to ("" for views, sequences, routines and events).
+func sectionTable(line string) (string, bool) {
+	for _, marker := range []string{tableStructureMarker, tableDataMarker} {
+		if strings.HasPrefix(line, marker) {
+			name := strings.TrimPrefix(line, marker)
+			if end := strings.LastIndex(name, "`"); end >= 0 {
+				name = name[:end]
+			}
+			return strings.ReplaceAll(name, "``", "`"), true
+		}
+	}
+
+	for _, marker := range otherSectionMarkers {
+		if strings.HasPrefix(line, marker) {
+			return "", true
+		}
+	}
+
+	return "", false
+}
+
+func isFooterLine(line string) bool {
+	return (strings.HasPrefix(line, "/*!") && strings.Contains(line, "=@OLD_")) ||
+		strings.HasPrefix(line, "-- Dump completed")
+}
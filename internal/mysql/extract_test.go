@@ -0,0 +1,62 @@
+package mysql
+
+import (
+	"strings"
+	"testing"
+)
+
+const extractDump = "/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;\n" +
+	"\n--\n-- Table structure for table `orders`\n--\n\n" +
+	"CREATE TABLE `orders` (`id` int);\n" +
+	"\n--\n-- Dumping data for table `orders`\n--\n\n" +
+	"INSERT INTO `orders` VALUES (1);\n" +
+	"\n--\n-- Sequence structure for `order_ids`\n--\n\n" +
+	"CREATE SEQUENCE `order_ids` start with 1;\n" +
+	"SELECT SETVAL(`order_ids`, 42, 0);\n" +
+	"\n--\n-- Table structure for table `customers`\n--\n\n" +
+	"CREATE TABLE `customers` (`id` int);\n" +
+	"\n--\n-- Final view structure for view `recent`\n--\n\n" +
+	"CREATE VIEW `recent` AS SELECT 1;\n" +
+	"/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;\n" +
+	"-- Dump completed on 2026-10-17 14:00:00\n"
+
+func TestExtractTables(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables []string
+		keep   []string
+		skip   []string
+	}{
+		{
+			name:   "table before a sequence",
+			tables: []string{"orders"},
+			keep:   []string{"CREATE TABLE `orders`", "INSERT INTO `orders`", "SET @OLD_CHARACTER_SET_CLIENT", "=@OLD_CHARACTER_SET_CLIENT", "-- Dump completed"},
+			skip:   []string{"CREATE SEQUENCE", "SETVAL", "CREATE TABLE `customers`", "CREATE VIEW"},
+		},
+		{
+			name:   "pattern",
+			tables: []string{"cust*"},
+			keep:   []string{"CREATE TABLE `customers`"},
+			skip:   []string{"CREATE TABLE `orders`", "CREATE SEQUENCE", "CREATE VIEW"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := ExtractTables(strings.NewReader(extractDump), &out, tt.tables); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.keep {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output lacks %q:\n%s", want, out.String())
+				}
+			}
+			for _, unwanted := range tt.skip {
+				if strings.Contains(out.String(), unwanted) {
+					t.Errorf("output contains %q:\n%s", unwanted, out.String())
+				}
+			}
+		})
+	}
+}
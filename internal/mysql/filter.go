@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+)
+
+// tableArgs turns include and exclude glob patterns (e.g. "audit_*") into
+// mysqldump arguments: explicit table names after the database when
+// includes are given, or --ignore-table options otherwise. mysqldump has no
+// pattern support of its own, so the patterns are matched against the
+// database's current tables.
+func tableArgs(db *sql.DB, database string, include, exclude []string) ([]string, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	tables, err := listTables(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	if len(include) > 0 {
+		for _, table := range tables {
+			if matchAny(include, table) && !matchAny(exclude, table) {
+				args = append(args, table)
+			}
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("no tables in %s match the include filter", database)
+		}
+		return args, nil
+	}
+
+	for _, table := range tables {
+		if matchAny(exclude, table) {
+			args = append(args, fmt.Sprintf("--ignore-table=%s.%s", database, table))
+		}
+	}
+	return args, nil
+}
+
+func listTables(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SHOW TABLES")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
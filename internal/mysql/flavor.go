@@ -0,0 +1,109 @@
+package mysql
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+
+	"github.com/tiyfiy/BackItUp/internal/backend"
+)
+
+// Server flavors. MariaDB forked from MySQL 5.5 and has diverged since:
+// its client tools are called mariadb-dump, mariadb and mariadb-binlog
+// from 10.5 on, and it has objects MySQL lacks, such as sequences and
+// system-versioned tables.
+const (
+	FlavorMySQL   = "mysql"
+	FlavorMariaDB = "mariadb"
+)
+
+// FlavorOf returns the flavor of a server from its version string, e.g.
+// "10.11.6-MariaDB" or "8.0.36".
+func FlavorOf(version string) string {
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		return FlavorMariaDB
+	}
+	return FlavorMySQL
+}
+
+// ServerFlavor asks the server for its version and returns its flavor.
+func ServerFlavor(db *sql.DB) (string, error) {
+	version, err := ServerVersion(db)
+	if err != nil {
+		return "", err
+	}
+	return FlavorOf(version), nil
+}
+
+// Tools are the client programs used with a server.
+type Tools struct {
+	Dump   string
+	Client string
+	Binlog string
+}
+
+// ToolsFor returns the client tools matching a flavor where b runs them.
+// MariaDB 11 no longer ships mysqldump, while MariaDB before 10.5 only has
+// the mysql names, so each tool falls back to the other flavor's when it
+// isn't installed.
+func ToolsFor(flavor string, b backend.Backend) Tools {
+	mysqlTools := Tools{Dump: "mysqldump", Client: "mysql", Binlog: "mysqlbinlog"}
+	mariadbTools := Tools{Dump: "mariadb-dump", Client: "mariadb", Binlog: "mariadb-binlog"}
+
+	preferred, fallback := mysqlTools, mariadbTools
+	if flavor == FlavorMariaDB {
+		preferred, fallback = mariadbTools, mysqlTools
+	}
+	return Tools{
+		Dump:   installed(b, preferred.Dump, fallback.Dump),
+		Client: installed(b, preferred.Client, fallback.Client),
+		Binlog: installed(b, preferred.Binlog, fallback.Binlog),
+	}
+}
+
+// installed returns preferred, unless only fallback can be run.
+func installed(b backend.Backend, preferred, fallback string) string {
+	if b.LookPath(preferred) != nil && b.LookPath(fallback) == nil {
+		return fallback
+	}
+	return preferred
+}
+
+var helpCache sync.Map
+
+// toolHelp returns a tool's --help output, which lists the options this
+// build of it supports.
+func toolHelp(b backend.Backend, name string) string {
+	key := b.String() + "\x00" + name
+	if help, ok := helpCache.Load(key); ok {
+		return help.(string)
+	}
+	out, _ := b.Command(nil, name, "--help").Output()
+	helpCache.Store(key, string(out))
+	return string(out)
+}
+
+// mariadbDumpFlags are the options a MariaDB server needs on top of the
+// usual ones. mysqldump 8 asks for column statistics MariaDB doesn't
+// have, and rows of system-versioned tables only include their history
+// with --dump-history (mariadb-dump 10.11 and later).
+func mariadbDumpFlags(db *sql.DB, b backend.Backend, dumpTool, database string) []string {
+	help := toolHelp(b, dumpTool)
+
+	var flags []string
+	if strings.Contains(help, "--column-statistics") {
+		flags = append(flags, "--column-statistics=0")
+	}
+	if strings.Contains(help, "--dump-history") && hasVersionedTables(db, database) {
+		flags = append(flags, "--dump-history")
+	}
+	return flags
+}
+
+// hasVersionedTables reports whether a MariaDB database has
+// system-versioned tables.
+func hasVersionedTables(db *sql.DB, database string) bool {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'SYSTEM VERSIONED'", database).Scan(&count)
+	return err == nil && count > 0
+}
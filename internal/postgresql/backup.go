@@ -5,39 +5,329 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+	"github.com/tiyfiy/BackItUp/internal/tool"
+)
+
+// Dump formats supported by pg_dump.
+const (
+	FormatPlain     = "plain"
+	FormatCustom    = "custom"
+	FormatDirectory = "directory"
 )
 
-func Backup(db *sql.DB, host, port, user, password, database string) {
+// GlobalsFile is the name of the roles and tablespaces dump inside a
+// cluster backup set.
+const GlobalsFile = "globals.sql"
+
+// BackupOptions controls how pg_dump writes the backup.
+type BackupOptions struct {
+	// Format is one of FormatPlain, FormatCustom or FormatDirectory.
+	// An empty format means plain SQL.
+	Format string
+	// Kind is manifest.KindFull (the default), KindSchema or KindData.
+	Kind string
+	// Jobs is the number of parallel dump jobs. Only the directory
+	// format supports more than one.
+	Jobs int
+	// Include, Exclude and ExcludeData are table patterns passed to
+	// pg_dump's -t, -T and --exclude-table-data, which understand
+	// wildcards ("audit_*") and schema qualification ("billing.*").
+	Include     []string
+	Exclude     []string
+	ExcludeData []string
+
+	// Conn holds the connection settings for the client tools.
+	Conn ConnOptions
+}
+
+// Backup dumps a database with pg_dump and returns the path of the dump.
+// If pg_dump fails, the partial dump is removed and the error carries
+// pg_dump's stderr.
+func Backup(db *sql.DB, host, port, user, password, database string, opts BackupOptions) (string, error) {
 	path := "BACKUP/postgresql"
 
-	err := os.MkdirAll(path, 0755)
+	opts, err := normalizeOptions(opts)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
+	}
+
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return "", err
 	}
 
-	// Add timestamp to filename to prevent overwrites
 	now := time.Now()
-	timestamp := fmt.Sprintf("%d-%02d-%02d_%02d-%02d-%02d",
-		now.Year(), now.Month(), now.Day(),
-		now.Hour(), now.Minute(), now.Second())
-	outfile := fmt.Sprintf("%s/%s_%s.sql", path, database, timestamp)
+	outfile := fmt.Sprintf("%s/%s_%s%s%s", path, database, timestamp(), manifest.KindTag(opts.Kind), Extension(opts.Format))
+
+	// Estimate the dump size from the previous backup, or from the size of
+	// the database when there is none yet
+	total := progress.SizeOf(manifest.Latest(path, "postgresql", database, opts.Kind))
+	if total == 0 && opts.Kind != manifest.KindSchema {
+		total = databaseSize(db, database)
+	}
+
+	err = dump(host, port, user, password, database, outfile, total, opts, filterArgs(opts)...)
+	if err != nil {
+		os.RemoveAll(outfile)
+		return "", err
+	}
+
+	writeManifest(outfile, database, now, opts)
+
+	fmt.Printf("✅ Backup completed: %s\n", outfile)
+	return outfile, nil
+}
+
+// BackupCluster backs up a whole PostgreSQL cluster into one backup set:
+// roles and tablespaces from pg_dumpall --globals-only, followed by a dump
+// of every non-template database. Each database dump includes CREATE
+// DATABASE so it can be restored into an empty cluster. Exclude and
+// ExcludeData apply to every database; Include isn't supported, since
+// pg_dump fails on a database without matching tables.
+func BackupCluster(db *sql.DB, host, port, user, password string, opts BackupOptions) (string, error) {
+	opts, err := normalizeOptions(opts)
+	if err != nil {
+		return "", err
+	}
+	if opts.Kind != manifest.KindFull {
+		return "", fmt.Errorf("cluster backups are always full, %s-only isn't supported", opts.Kind)
+	}
+	if len(opts.Include) > 0 {
+		return "", fmt.Errorf("cluster backups can't be limited with --include, back up the databases one at a time instead")
+	}
 
-	cmd := exec.Command("pg_dump",
+	databases, err := Databases(db)
+	if err != nil {
+		return "", fmt.Errorf("failed to list databases: %w", err)
+	}
+
+	now := time.Now()
+	outdir := fmt.Sprintf("BACKUP/postgresql/cluster_%s", timestamp())
+	err = os.MkdirAll(outdir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Println("   Dumping roles and tablespaces...")
+	err = dumpGlobals(host, port, user, password, filepath.Join(outdir, GlobalsFile), opts.Conn)
+	if err != nil {
+		os.RemoveAll(outdir)
+		return "", err
+	}
+
+	for _, database := range databases {
+		fmt.Printf("   Dumping database %s...\n", database)
+		outfile := filepath.Join(outdir, database+Extension(opts.Format))
+
+		// Plain scripts can't be cleaned at restore time, so they carry
+		// their own DROP DATABASE IF EXISTS.
+		extraArgs := []string{"--create"}
+		if opts.Format == FormatPlain {
+			extraArgs = append(extraArgs, "--clean", "--if-exists")
+		}
+		extraArgs = append(extraArgs, filterArgs(opts)...)
+
+		err = dump(host, port, user, password, database, outfile, databaseSize(db, database), opts, extraArgs...)
+		if err != nil {
+			os.RemoveAll(outdir)
+			return "", fmt.Errorf("failed to dump %s: %w", database, err)
+		}
+	}
+
+	// The set has no single database; its manifest records the filters
+	writeManifest(outdir, "", now, opts)
+
+	fmt.Printf("✅ Cluster backup completed: %s (%d databases)\n", outdir, len(databases))
+	return outdir, nil
+}
+
+// writeManifest records a finished backup, with the table filters it was
+// taken with.
+func writeManifest(backupPath, database string, createdAt time.Time, opts BackupOptions) {
+	m := &manifest.Manifest{
+		Engine:    "postgresql",
+		Database:  database,
+		CreatedAt: createdAt,
+		Kind:      opts.Kind,
+	}
+	if len(opts.Include) > 0 || len(opts.Exclude) > 0 || len(opts.ExcludeData) > 0 {
+		m.Filters = &manifest.Filters{Include: opts.Include, Exclude: opts.Exclude, ExcludeData: opts.ExcludeData}
+	}
+	if err := manifest.Write(backupPath, m); err != nil {
+		log.Printf("Warning: failed to write manifest: %v", err)
+	}
+}
+
+// dumpGlobals writes the roles and tablespaces to outfile. pg_dumpall
+// writes to stdout, so the file is created here even when it runs in a
+// container.
+func dumpGlobals(host, port, user, password, outfile string, conn ConnOptions) error {
+	args := []string{
 		"-h", host,
 		"-p", port,
 		"-U", user,
-		"-d", database,
-		"-f", outfile,
-	)
+		"--globals-only",
+	}
+	if conninfo := Conninfo(host, "", conn); conninfo != "" {
+		args = append(args, "-d", conninfo)
+	}
+	cmd := conn.Backend().Command([]string{"PGPASSWORD=" + password}, "pg_dumpall", args...)
 
-	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", password))
+	output, err := os.Create(outfile)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	cmd.Stdout = output
+
+	return tool.Run(cmd)
+}
 
-	err = cmd.Run()
+// Databases lists every database in the cluster that accepts connections,
+// excluding templates.
+func Databases(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT datname FROM pg_database WHERE NOT datistemplate AND datallowconn ORDER BY datname")
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	fmt.Printf("✅ Backup completed: %s\n", outfile)
+	var databases []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		databases = append(databases, name)
+	}
+
+	return databases, rows.Err()
+}
+
+// dump runs pg_dump into outfile, showing progress against total (the
+// expected size in bytes, 0 if unknown).
+func dump(host, port, user, password, database, outfile string, total int64, opts BackupOptions, extraArgs ...string) error {
+	tools := opts.Conn.Backend()
+	if opts.Format == FormatDirectory && !tools.Local() {
+		return fmt.Errorf("pg_dump in %s can't write a directory dump here, use the custom format", tools)
+	}
+
+	args := []string{
+		"-h", host,
+		"-p", port,
+		"-U", user,
+		"-d", Conninfo(host, database, opts.Conn),
+		"-F", opts.Format,
+	}
+
+	// Directory dumps are written by pg_dump's parallel workers, the other
+	// formats go through stdout so the bytes can be counted
+	if opts.Format == FormatDirectory {
+		args = append(args, "-f", outfile)
+	}
+	if opts.Jobs > 1 {
+		args = append(args, "-j", strconv.Itoa(opts.Jobs))
+	}
+	switch opts.Kind {
+	case manifest.KindSchema:
+		args = append(args, "-s")
+	case manifest.KindData:
+		args = append(args, "-a")
+	}
+	args = append(args, extraArgs...)
+
+	cmd := tools.Command([]string{"PGPASSWORD=" + password}, "pg_dump", args...)
+
+	if opts.Format == FormatDirectory {
+		meter := progress.Watch("Dumped", total, outfile)
+		defer meter.Finish()
+		return tool.Run(cmd)
+	}
+
+	output, err := os.Create(outfile)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	meter := progress.Start("Dumped", total)
+	defer meter.Finish()
+	cmd.Stdout = meter.Writer(output)
+
+	return tool.Run(cmd)
+}
+
+// databaseSize returns the on-disk size of a database, or 0 if it can't be
+// queried.
+func databaseSize(db *sql.DB, database string) int64 {
+	var size int64
+	if err := db.QueryRow("SELECT pg_database_size($1)", database).Scan(&size); err != nil {
+		return 0
+	}
+	return size
+}
+
+func filterArgs(opts BackupOptions) []string {
+	var args []string
+	for _, pattern := range opts.Include {
+		args = append(args, "-t", pattern)
+	}
+	for _, pattern := range opts.Exclude {
+		args = append(args, "-T", pattern)
+	}
+	for _, pattern := range opts.ExcludeData {
+		args = append(args, "--exclude-table-data", pattern)
+	}
+	return args
+}
+
+// normalizeOptions fills in the default format and kind, and checks that
+// the options can be combined.
+func normalizeOptions(opts BackupOptions) (BackupOptions, error) {
+	if opts.Format == "" {
+		opts.Format = FormatPlain
+	}
+	if opts.Kind == "" {
+		opts.Kind = manifest.KindFull
+	}
+	if opts.Jobs > 1 && opts.Format != FormatDirectory {
+		return opts, fmt.Errorf("parallel jobs require the directory format (got %s)", opts.Format)
+	}
+	return opts, nil
+}
+
+// Add timestamp to filename to prevent overwrites
+func timestamp() string {
+	now := time.Now()
+	return fmt.Sprintf("%d-%02d-%02d_%02d-%02d-%02d",
+		now.Year(), now.Month(), now.Day(),
+		now.Hour(), now.Minute(), now.Second())
+}
+
+// Extension returns the file extension used for a dump format. Directory
+// format backups are plain directories without an extension.
+func Extension(format string) string {
+	switch format {
+	case FormatCustom:
+		return ".dump"
+	case FormatDirectory:
+		return ""
+	default:
+		return ".sql"
+	}
+}
+
+// ValidFormat reports whether format is a supported pg_dump format.
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatPlain, FormatCustom, FormatDirectory:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,24 @@
+package postgresql
+
+import (
+	"testing"
+
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+)
+
+func TestNormalizeOptions(t *testing.T) {
+	opts, err := normalizeOptions(BackupOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Format != FormatPlain || opts.Kind != manifest.KindFull {
+		t.Errorf("normalizeOptions() = format %q, kind %q, want plain and full", opts.Format, opts.Kind)
+	}
+
+	if _, err := normalizeOptions(BackupOptions{Format: FormatDirectory, Jobs: 4}); err != nil {
+		t.Errorf("normalizeOptions() refused parallel jobs for a directory dump: %v", err)
+	}
+	if _, err := normalizeOptions(BackupOptions{Format: FormatCustom, Jobs: 4}); err == nil {
+		t.Error("normalizeOptions() accepted parallel jobs for a custom format dump")
+	}
+}
@@ -2,23 +2,165 @@ package postgresql
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/tiyfiy/BackItUp/internal/backend"
+	"github.com/tiyfiy/BackItUp/internal/tlsconf"
 )
 
-func Connection(host, port, user, password, database string) (*sql.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, database)
+// ConnOptions are the connection settings besides the address and
+// credentials. They apply to the driver and the client tools alike.
+type ConnOptions struct {
+	// Socket is the directory holding the server's unix socket, used
+	// instead of host.
+	Socket string
+	TLS    tlsconf.Config
+	// Params are extra libpq parameters, e.g.
+	// "connect_timeout=5 application_name=backitup".
+	Params string
+	// Exec is where the client tools run, this machine when nil.
+	Exec backend.Backend
+}
+
+// Backend returns where the client tools run.
+func (o ConnOptions) Backend() backend.Backend {
+	if o.Exec == nil {
+		return backend.Local
+	}
+	return o.Exec
+}
+
+func Connection(host, port, user, password, database string, opts ConnOptions) (*sql.DB, error) {
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s",
+		quote(host), quote(port), quote(user), quote(password), quote(database))
+
+	// lib/pq has no sslmode=prefer, so it's tried with TLS first
+	sslMode := opts.TLS.Mode
+	switch sslMode {
+	case "":
+		sslMode = tlsconf.ModeDisable
+	case tlsconf.ModePrefer:
+		sslMode = tlsconf.ModeRequire
+	}
+	settings := append([]string{"sslmode=" + sslMode}, opts.settings(host, false)...)
+
+	db, err := open(dsn + " " + strings.Join(settings, " "))
+	if errors.Is(err, pq.ErrSSLNotSupported) && opts.TLS.Mode == tlsconf.ModePrefer {
+		settings[0] = "sslmode=" + tlsconf.ModeDisable
+		db, err = open(dsn + " " + strings.Join(settings, " "))
+	}
+	return db, err
+}
 
+func open(dsn string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// Conninfo returns what to pass as -d (--dbname) to the client tools to
+// connect to database on host: the database name alone, or a libpq
+// connection string when there are more settings. Its settings take
+// precedence over -h, -p and -U.
+func Conninfo(host, database string, opts ConnOptions) string {
+	settings := opts.settings(host, true)
+	if len(settings) == 0 {
+		return database
+	}
+	if database != "" {
+		settings = append([]string{"dbname=" + quote(database)}, settings...)
+	}
+	return strings.Join(settings, " ")
+}
+
+// settings returns the libpq parameters for the options. sslmode is only
+// included for the tools; the driver sets its own.
+//
+// A server name different from host can't be given to libpq directly, so
+// the connection goes to host's address (hostaddr) while the certificate
+// is checked against the name (host).
+func (o ConnOptions) settings(host string, withMode bool) []string {
+	var settings []string
+	if o.Socket != "" {
+		settings = append(settings, "host="+quote(o.Socket))
+	} else if o.TLS.ServerName != "" && o.TLS.ServerName != host {
+		if addr := hostAddr(host); addr != "" {
+			settings = append(settings, "host="+quote(o.TLS.ServerName), "hostaddr="+addr)
+		}
+	}
+
+	if withMode && o.TLS.Mode != "" {
+		settings = append(settings, "sslmode="+o.TLS.Mode)
+	}
+	if o.TLS.CA != "" {
+		settings = append(settings, "sslrootcert="+quote(o.TLS.CA))
+	}
+	if o.TLS.Cert != "" {
+		settings = append(settings, "sslcert="+quote(o.TLS.Cert))
+	}
+	if o.TLS.Key != "" {
+		settings = append(settings, "sslkey="+quote(o.TLS.Key))
+	}
+
+	if o.Params != "" {
+		settings = append(settings, o.Params)
+	}
+	return settings
+}
+
+// hostAddr returns the IP address of host, or "" if it can't be resolved.
+func hostAddr(host string) string {
+	if ip := net.ParseIP(host); ip != nil {
+		return host
+	}
+	addrs, err := net.LookupHost(host)
+	if err != nil || len(addrs) == 0 {
+		return ""
+	}
+	return addrs[0]
+}
+
+// quote quotes a libpq connection string value.
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// EnsureDatabase creates the database if it doesn't exist yet, connecting
+// through the postgres maintenance database.
+func EnsureDatabase(host, port, user, password, database string, opts ConnOptions) error {
+	db, err := Connection(host, port, user, password, "postgres", opts)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", database).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec("CREATE DATABASE " + pq.QuoteIdentifier(database))
+	return err
+}
+
+// ServerVersion returns the server's version string, e.g. "16.2".
+func ServerVersion(db *sql.DB) (string, error) {
+	var version string
+	err := db.QueryRow("SHOW server_version").Scan(&version)
+	return version, err
+}
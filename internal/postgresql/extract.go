@@ -0,0 +1,252 @@
+package postgresql
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ExtractOptions selects what ExtractTables copies out of a plain SQL dump.
+type ExtractOptions struct {
+	// Tables to keep, as "name" or "schema.name". Glob patterns are allowed.
+	Tables []string
+
+	// IntoSchema moves the extracted objects into this schema instead of
+	// their original one.
+	IntoSchema string
+
+	// Clean drops each extracted table before recreating it.
+	Clean bool
+}
+
+// entryHeader matches the comment pg_dump writes before every object, e.g.
+// "-- Name: orders; Type: TABLE; Schema: public; Owner: app" or
+// "-- Data for Name: orders; Type: TABLE DATA; Schema: public; Owner: app".
+var entryHeader = regexp.MustCompile(`^-- (?:Data for )?Name: (.*); Type: ([A-Z ]+); Schema: ([^;]*);`)
+
+// indexTable finds the table an index is built on.
+var indexTable = regexp.MustCompile(`\bON (?:ONLY )?((?:"[^"]+"|[\w$]+)\.)?("[^"]+"|[\w$]+)`)
+
+// dumpEntry is one object in a pg_dump script: its header comment and
+// statements, up to the next header.
+type dumpEntry struct {
+	name, kind, schema string
+	lines              []string
+}
+
+// ExtractTables copies the statements for the selected tables from a plain
+// pg_dump script (or the output of pg_restore -f -) to w: table definitions,
+// data, defaults, constraints, indexes, triggers and the tables' serial
+// sequences. The dump's session settings are kept; other objects such as
+// functions and views are skipped.
+//
+// Data sections are streamed, so the dump can be far larger than memory.
+func ExtractTables(r io.Reader, w io.Writer, opts ExtractOptions) error {
+	reader := bufio.NewReaderSize(r, 1<<20)
+	writer := bufio.NewWriterSize(w, 1<<20)
+
+	if opts.IntoSchema != "" {
+		fmt.Fprintf(writer, "CREATE SCHEMA IF NOT EXISTS %s;\n\n", quoteName(opts.IntoSchema))
+	}
+
+	var entry *dumpEntry
+	inHeader := true
+	inCopy := false
+	keepCopy := false
+
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			switch {
+			case inCopy:
+				// COPY data rows are passed through untouched
+				if keepCopy {
+					if _, werr := writer.WriteString(line); werr != nil {
+						return werr
+					}
+				}
+				if line == "\\.\n" || line == "\\." {
+					inCopy = false
+				}
+
+			case entryHeader.MatchString(line):
+				if werr := flushEntry(writer, entry, opts); werr != nil {
+					return werr
+				}
+				inHeader = false
+
+				m := entryHeader.FindStringSubmatch(line)
+				entry = &dumpEntry{name: m[1], kind: m[2], schema: m[3], lines: []string{line}}
+
+			case inHeader:
+				if _, werr := writer.WriteString(line); werr != nil {
+					return werr
+				}
+
+			case entry != nil && entry.kind == "TABLE DATA" && strings.HasPrefix(line, "COPY "):
+				// Decide on the data now so the rows don't have to be buffered
+				keepCopy = keepEntry(entry, opts.Tables)
+				if keepCopy {
+					entry.lines = append(entry.lines, line)
+					if werr := flushEntry(writer, entry, opts); werr != nil {
+						return werr
+					}
+				}
+				entry = nil
+				inCopy = true
+
+			case entry != nil:
+				entry.lines = append(entry.lines, line)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := flushEntry(writer, entry, opts); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// flushEntry writes a buffered entry if it belongs to a selected table.
+func flushEntry(w *bufio.Writer, entry *dumpEntry, opts ExtractOptions) error {
+	if entry == nil || !keepEntry(entry, opts.Tables) {
+		return nil
+	}
+
+	if opts.Clean && entry.kind == "TABLE" {
+		table := quoteName(targetSchema(entry.schema, opts)) + "." + quoteName(entry.name)
+		if _, err := fmt.Fprintf(w, "DROP TABLE IF EXISTS %s;\n", table); err != nil {
+			return err
+		}
+	}
+
+	rename := func(line string) string { return line }
+	if opts.IntoSchema != "" {
+		rename = schemaRenamer(entry.schema, opts.IntoSchema)
+	}
+
+	for _, line := range entry.lines {
+		if !strings.HasPrefix(line, "--") {
+			line = rename(line)
+		}
+		if _, err := w.WriteString(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepEntry reports whether an entry belongs to one of the selected tables.
+func keepEntry(entry *dumpEntry, tables []string) bool {
+	var table string
+
+	switch entry.kind {
+	case "TABLE", "TABLE DATA":
+		table = entry.name
+	case "DEFAULT", "CONSTRAINT", "FK CONSTRAINT", "TRIGGER", "POLICY", "ROW SECURITY":
+		// Named "<table> <object>"
+		table, _, _ = strings.Cut(entry.name, " ")
+	case "INDEX":
+		for _, line := range entry.lines {
+			if m := indexTable.FindStringSubmatch(line); m != nil {
+				table = strings.Trim(m[2], `"`)
+				break
+			}
+		}
+	case "SEQUENCE", "SEQUENCE OWNED BY", "SEQUENCE SET":
+		// Serial and identity sequences are named "<table>_<column>_seq"
+		for _, pattern := range tables {
+			if matchTable(pattern, entry.schema, sequenceTable(entry.name, pattern)) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+
+	for _, pattern := range tables {
+		if matchTable(pattern, entry.schema, table) {
+			return true
+		}
+	}
+	return false
+}
+
+// sequenceTable guesses the table a sequence belongs to by taking the
+// pattern's table name as a prefix. It returns "" when it doesn't fit.
+func sequenceTable(sequence, pattern string) string {
+	if !strings.HasSuffix(sequence, "_seq") {
+		return ""
+	}
+	name := pattern
+	if i := strings.LastIndex(pattern, "."); i >= 0 {
+		name = pattern[i+1:]
+	}
+
+	// For glob patterns, try every "<prefix>_" split of the sequence name
+	for i := len(sequence) - len("_seq"); i > 0; i-- {
+		if sequence[i] != '_' {
+			continue
+		}
+		if ok, _ := path.Match(name, sequence[:i]); ok {
+			return sequence[:i]
+		}
+	}
+	return ""
+}
+
+// matchTable matches "name" patterns against the table name and
+// "schema.name" patterns against the qualified name.
+func matchTable(pattern, schema, table string) bool {
+	if table == "" {
+		return false
+	}
+	name := table
+	if strings.Contains(pattern, ".") {
+		name = schema + "." + table
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func targetSchema(schema string, opts ExtractOptions) string {
+	if opts.IntoSchema != "" {
+		return opts.IntoSchema
+	}
+	return schema
+}
+
+// schemaRenamer returns a function that rewrites schema-qualified names in a
+// statement, e.g. public.orders and 'public.orders_id_seq', to use schema to.
+func schemaRenamer(from, to string) func(string) string {
+	qualifier := regexp.MustCompile(`(^|[^\w$"])` + regexp.QuoteMeta(quoteName(from)) + `\.`)
+	replacement := "${1}" + strings.ReplaceAll(quoteName(to), "$", "$$") + "."
+	return func(line string) string {
+		return qualifier.ReplaceAllString(line, replacement)
+	}
+}
+
+// plainIdentifier matches names pg_dump writes without quotes. It quotes
+// any name with a "$", though PostgreSQL would accept it bare.
+var plainIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// quoteName quotes an identifier the way pg_dump does: only when needed.
+func quoteName(name string) string {
+	if plainIdentifier.MatchString(name) {
+		return name
+	}
+	return pq.QuoteIdentifier(name)
+}
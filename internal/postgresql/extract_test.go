@@ -0,0 +1,440 @@
+package postgresql
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// shopDump is a trimmed pg_dump script of a schema with three tables.
+const shopDump = `--
+-- PostgreSQL database dump
+--
+
+SET statement_timeout = 0;
+SET client_encoding = 'UTF8';
+SELECT pg_catalog.set_config('search_path', '', false);
+
+--
+-- Name: order_total(integer); Type: FUNCTION; Schema: public; Owner: app
+--
+
+CREATE FUNCTION public.order_total(id integer) RETURNS integer
+    LANGUAGE sql
+    AS $$SELECT 1$$;
+
+--
+-- Name: customers; Type: TABLE; Schema: public; Owner: app
+--
+
+CREATE TABLE public.customers (
+    id integer NOT NULL
+);
+
+--
+-- Name: orders; Type: TABLE; Schema: public; Owner: app
+--
+
+CREATE TABLE public.orders (
+    id integer NOT NULL,
+    customer_id integer
+);
+
+--
+-- Name: orders_id_seq; Type: SEQUENCE; Schema: public; Owner: app
+--
+
+CREATE SEQUENCE public.orders_id_seq
+    AS integer;
+
+--
+-- Name: orders_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: app
+--
+
+ALTER SEQUENCE public.orders_id_seq OWNED BY public.orders.id;
+
+--
+-- Name: order_items; Type: TABLE; Schema: public; Owner: app
+--
+
+CREATE TABLE public.order_items (
+    id integer NOT NULL,
+    order_id integer
+);
+
+--
+-- Name: order_items_id_seq; Type: SEQUENCE; Schema: public; Owner: app
+--
+
+CREATE SEQUENCE public.order_items_id_seq
+    AS integer;
+
+--
+-- Name: orders id; Type: DEFAULT; Schema: public; Owner: app
+--
+
+ALTER TABLE ONLY public.orders ALTER COLUMN id SET DEFAULT nextval('public.orders_id_seq'::regclass);
+
+--
+-- Data for Name: customers; Type: TABLE DATA; Schema: public; Owner: app
+--
+
+COPY public.customers (id) FROM stdin;
+41
+\.
+
+
+--
+-- Data for Name: orders; Type: TABLE DATA; Schema: public; Owner: app
+--
+
+COPY public.orders (id, customer_id) FROM stdin;
+1	41
+2	41
+\.
+
+
+--
+-- Name: orders_id_seq; Type: SEQUENCE SET; Schema: public; Owner: app
+--
+
+SELECT pg_catalog.setval('public.orders_id_seq', 2, true);
+
+--
+-- Name: customers customers_pkey; Type: CONSTRAINT; Schema: public; Owner: app
+--
+
+ALTER TABLE ONLY public.customers
+    ADD CONSTRAINT customers_pkey PRIMARY KEY (id);
+
+--
+-- Name: orders orders_pkey; Type: CONSTRAINT; Schema: public; Owner: app
+--
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_pkey PRIMARY KEY (id);
+
+--
+-- Name: orders_customer_idx; Type: INDEX; Schema: public; Owner: app
+--
+
+CREATE INDEX orders_customer_idx ON public.orders USING btree (customer_id);
+
+--
+-- Name: customers_id_idx; Type: INDEX; Schema: public; Owner: app
+--
+
+CREATE INDEX customers_id_idx ON ONLY public.customers USING btree (id);
+
+--
+-- Name: orders orders_customer_fkey; Type: FK CONSTRAINT; Schema: public; Owner: app
+--
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_customer_fkey FOREIGN KEY (customer_id) REFERENCES public.customers(id);
+
+--
+-- PostgreSQL database dump complete
+--
+`
+
+// Statements of each object in shopDump, to check what was extracted.
+var (
+	customersTable = "CREATE TABLE public.customers ("
+	customersData  = "COPY public.customers (id) FROM stdin;\n41\n\\.\n"
+	customersPkey  = "ADD CONSTRAINT customers_pkey"
+	customersIndex = "CREATE INDEX customers_id_idx"
+	ordersTable    = "CREATE TABLE public.orders ("
+	ordersSeq      = "CREATE SEQUENCE public.orders_id_seq"
+	ordersOwnedBy  = "ALTER SEQUENCE public.orders_id_seq OWNED BY"
+	ordersDefault  = "SET DEFAULT nextval('public.orders_id_seq'::regclass)"
+	ordersData     = "COPY public.orders (id, customer_id) FROM stdin;\n1\t41\n2\t41\n\\.\n"
+	ordersSetval   = "pg_catalog.setval('public.orders_id_seq', 2, true)"
+	ordersPkey     = "ADD CONSTRAINT orders_pkey"
+	ordersIndex    = "CREATE INDEX orders_customer_idx"
+	ordersFkey     = "ADD CONSTRAINT orders_customer_fkey"
+	itemsTable     = "CREATE TABLE public.order_items ("
+	itemsSeq       = "CREATE SEQUENCE public.order_items_id_seq"
+	function       = "CREATE FUNCTION"
+)
+
+func TestExtractTables(t *testing.T) {
+	orders := []string{ordersTable, ordersSeq, ordersOwnedBy, ordersDefault, ordersData, ordersSetval, ordersPkey, ordersIndex, ordersFkey}
+	customers := []string{customersTable, customersData, customersPkey, customersIndex}
+
+	tests := []struct {
+		name     string
+		tables   []string
+		want     []string
+		dontWant []string
+	}{
+		{
+			name:     "one table",
+			tables:   []string{"orders"},
+			want:     orders,
+			dontWant: append([]string{itemsTable, itemsSeq, function}, customers...),
+		},
+		{
+			// orders_customer_idx and orders_customer_fkey mention customers
+			// but belong to orders
+			name:     "index and constraint attribution",
+			tables:   []string{"public.customers"},
+			want:     customers,
+			dontWant: append([]string{itemsTable, itemsSeq, function}, orders...),
+		},
+		{
+			name:     "glob pattern with serial sequences",
+			tables:   []string{"order*"},
+			want:     append([]string{itemsTable, itemsSeq}, orders...),
+			dontWant: append([]string{function}, customers...),
+		},
+		{
+			name:     "qualified glob pattern",
+			tables:   []string{"public.order_*"},
+			want:     []string{itemsTable, itemsSeq},
+			dontWant: append([]string{function}, append(orders, customers...)...),
+		},
+		{
+			name:     "other schema",
+			tables:   []string{"sales.orders"},
+			dontWant: append([]string{itemsTable, itemsSeq, function}, append(orders, customers...)...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := ExtractTables(strings.NewReader(shopDump), &out, ExtractOptions{Tables: tt.tables}); err != nil {
+				t.Fatal(err)
+			}
+			got := out.String()
+
+			// The session settings are always kept
+			if !strings.HasPrefix(got, "--\n-- PostgreSQL database dump\n--\n\nSET statement_timeout = 0;") {
+				t.Errorf("the dump's settings are missing:\n%s", got)
+			}
+			for _, statement := range tt.want {
+				if !strings.Contains(got, statement) {
+					t.Errorf("missing %q in:\n%s", statement, got)
+				}
+			}
+			for _, statement := range tt.dontWant {
+				if strings.Contains(got, statement) {
+					t.Errorf("unexpected %q in:\n%s", statement, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSequenceTable(t *testing.T) {
+	tests := []struct {
+		sequence, pattern, want string
+	}{
+		{"orders_id_seq", "orders", "orders"},
+		{"orders_id_seq", "public.orders", "orders"},
+		{"order_items_id_seq", "orders", ""},
+		{"order_items_id_seq", "order_items", "order_items"},
+		{"order_items_id_seq", "order_*", "order_items_id"},
+		{"order_items_id_seq", "*_items", "order_items"},
+		{"orders_id", "orders", ""},
+		{"invoice_number_seq", "orders", ""},
+	}
+
+	for _, tt := range tests {
+		if got := sequenceTable(tt.sequence, tt.pattern); got != tt.want {
+			t.Errorf("sequenceTable(%q, %q) = %q, want %q", tt.sequence, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// quotedDump has a table in a schema whose name needs quotes.
+const quotedDump = `SET client_encoding = 'UTF8';
+
+--
+-- Name: Orders; Type: TABLE; Schema: My Shop; Owner: app
+--
+
+CREATE TABLE "My Shop"."Orders" (
+    id integer NOT NULL,
+    note text
+);
+
+--
+-- Name: Orders_id_seq; Type: SEQUENCE; Schema: My Shop; Owner: app
+--
+
+CREATE SEQUENCE "My Shop"."Orders_id_seq"
+    AS integer;
+
+--
+-- Name: Orders id; Type: DEFAULT; Schema: My Shop; Owner: app
+--
+
+ALTER TABLE ONLY "My Shop"."Orders" ALTER COLUMN id SET DEFAULT nextval('"My Shop"."Orders_id_seq"'::regclass);
+
+--
+-- Data for Name: Orders; Type: TABLE DATA; Schema: My Shop; Owner: app
+--
+
+COPY "My Shop"."Orders" (id, note) FROM stdin;
+1	moved from "My Shop".legacy
+\.
+
+
+--
+-- Name: Orders_note_idx; Type: INDEX; Schema: My Shop; Owner: app
+--
+
+CREATE INDEX "Orders_note_idx" ON "My Shop"."Orders" USING btree (note);
+
+--
+-- Name: Orders Orders_legacy_fkey; Type: FK CONSTRAINT; Schema: My Shop; Owner: app
+--
+
+ALTER TABLE ONLY "My Shop"."Orders"
+    ADD CONSTRAINT "Orders_legacy_fkey" FOREIGN KEY (id) REFERENCES "My Shop Archive"."Orders"(id);
+`
+
+func TestExtractTablesIntoSchema(t *testing.T) {
+	var out bytes.Buffer
+	opts := ExtractOptions{Tables: []string{"Orders"}, IntoSchema: "side copy", Clean: true}
+	if err := ExtractTables(strings.NewReader(quotedDump), &out, opts); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+
+	want := []string{
+		"CREATE SCHEMA IF NOT EXISTS \"side copy\";\n",
+		"DROP TABLE IF EXISTS \"side copy\".\"Orders\";\n",
+		"CREATE TABLE \"side copy\".\"Orders\" (",
+		"CREATE SEQUENCE \"side copy\".\"Orders_id_seq\"",
+		"nextval('\"side copy\".\"Orders_id_seq\"'::regclass)",
+		"COPY \"side copy\".\"Orders\" (id, note) FROM stdin;\n",
+		"CREATE INDEX \"Orders_note_idx\" ON \"side copy\".\"Orders\"",
+		"ALTER TABLE ONLY \"side copy\".\"Orders\"\n",
+		// Other schemas that start the same, header comments and the rows
+		// are left alone
+		"REFERENCES \"My Shop Archive\".\"Orders\"(id)",
+		"-- Name: Orders; Type: TABLE; Schema: My Shop; Owner: app\n",
+		"1\tmoved from \"My Shop\".legacy\n",
+	}
+	for _, s := range want {
+		if !strings.Contains(got, s) {
+			t.Errorf("missing %q in:\n%s", s, got)
+		}
+	}
+	if n := strings.Count(got, `"My Shop".`); n != 1 {
+		t.Errorf("%d references to \"My Shop\" left, want only the one in the data:\n%s", n, got)
+	}
+}
+
+func TestSchemaRenamer(t *testing.T) {
+	tests := []struct {
+		from, to, line, want string
+	}{
+		{"public", "side", "CREATE TABLE public.orders (", "CREATE TABLE side.orders ("},
+		{"public", "side", "SELECT setval('public.orders_id_seq', 2)", "SELECT setval('side.orders_id_seq', 2)"},
+		{"public", "side", "REFERENCES mypublic.orders(id)", "REFERENCES mypublic.orders(id)"},
+		{"public", "side", `ON "public"."Orders"`, `ON "public"."Orders"`},
+		{"public", "Side Copy", "ALTER TABLE public.orders", `ALTER TABLE "Side Copy".orders`},
+		{"My Shop", "side", `ON "My Shop"."Orders"`, `ON side."Orders"`},
+		{"sales", "sales$v2", "CREATE TABLE sales.orders", `CREATE TABLE "sales$v2".orders`},
+	}
+
+	for _, tt := range tests {
+		if got := schemaRenamer(tt.from, tt.to)(tt.line); got != tt.want {
+			t.Errorf("renaming %s to %s in %q = %q, want %q", tt.from, tt.to, tt.line, got, tt.want)
+		}
+	}
+}
+
+// copyReader serves a dump whose skipped table has rows rows, one of which
+// looks like an entry header, followed by a small kept table. It records
+// how much had been written to out when the last row was read.
+type copyReader struct {
+	rows      int
+	out       *countingWriter
+	writtenAt int64
+	buf       bytes.Buffer
+	row       int
+	done      bool
+}
+
+func (r *copyReader) Read(p []byte) (int, error) {
+	for r.buf.Len() < len(p) && !r.done {
+		switch {
+		case r.row == 0:
+			r.buf.WriteString("--\n-- Data for Name: events; Type: TABLE DATA; Schema: public; Owner: app\n--\n\n")
+			r.buf.WriteString("COPY public.events (id, body) FROM stdin;\n")
+		case r.row == 1:
+			r.buf.WriteString("-- Name: events; Type: TABLE; Schema: public; Owner: app\n")
+		case r.row <= r.rows+1:
+			fmt.Fprintf(&r.buf, "%d\tevent number %d\n", r.row, r.row)
+		default:
+			r.writtenAt = r.out.n
+			r.buf.WriteString("\\.\n\n--\n-- Data for Name: orders; Type: TABLE DATA; Schema: public; Owner: app\n--\n\n")
+			r.buf.WriteString("COPY public.orders (id) FROM stdin;\n1\n\\.\n")
+			r.done = true
+		}
+		r.row++
+	}
+	if r.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return r.buf.Read(p)
+}
+
+type countingWriter struct {
+	n    int64
+	data bytes.Buffer
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return w.data.Write(p)
+}
+
+func TestExtractTablesStreamsCopy(t *testing.T) {
+	t.Run("kept data", func(t *testing.T) {
+		out := &countingWriter{}
+		r := &copyReader{rows: 200000, out: out}
+		if err := ExtractTables(r, out, ExtractOptions{Tables: []string{"events", "orders"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Several MB of rows: they have to reach the writer before the
+		// section ends, or they were buffered
+		if r.writtenAt == 0 {
+			t.Error("nothing was written before the COPY section ended")
+		}
+		got := out.data.String()
+		if n := strings.Count(got, "\tevent number "); n != 200000 {
+			t.Errorf("extracted %d rows, want 200000", n)
+		}
+		if !strings.Contains(got, "COPY public.events (id, body) FROM stdin;\n-- Name: events;") {
+			t.Error("the row that looks like a header wasn't passed through as data")
+		}
+		if !strings.HasSuffix(got, "COPY public.orders (id) FROM stdin;\n1\n\\.\n") {
+			t.Errorf("the table after the COPY section is missing:\n%s", got[max(0, len(got)-200):])
+		}
+	})
+
+	t.Run("skipped data", func(t *testing.T) {
+		out := &countingWriter{}
+		r := &copyReader{rows: 1000, out: out}
+		if err := ExtractTables(r, out, ExtractOptions{Tables: []string{"orders"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		got := out.data.String()
+		if strings.Contains(got, "event") {
+			t.Errorf("rows of a skipped table were extracted:\n%s", got)
+		}
+		if !strings.Contains(got, "COPY public.orders (id) FROM stdin;\n1\n\\.\n") {
+			t.Errorf("the table after the skipped COPY section is missing:\n%s", got)
+		}
+	})
+}
@@ -0,0 +1,379 @@
+package postgresql
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/tool"
+)
+
+// WALDir is where wal-archive stores WAL segments, next to the dumps.
+const WALDir = "BACKUP/postgresql/wal"
+
+// BaseBackupPrefix prefixes the directory name of every physical backup.
+const BaseBackupPrefix = "base_"
+
+// BackupPhysical takes a physical base backup of the whole cluster with
+// pg_basebackup in tar format, streaming the WAL needed to make it
+// consistent. The user needs the REPLICATION privilege.
+func BackupPhysical(host, port, user, password string, opts ConnOptions) (string, error) {
+	if !opts.Backend().Local() {
+		return "", fmt.Errorf("pg_basebackup writes the backup directly, it can't run in %s", opts.Backend())
+	}
+
+	outdir := fmt.Sprintf("BACKUP/postgresql/%s%s", BaseBackupPrefix, timestamp())
+
+	err := os.MkdirAll(filepath.Dir(outdir), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{
+		"-h", host,
+		"-p", port,
+		"-U", user,
+		"-D", outdir,
+		"-F", "t",
+		"-X", "stream",
+		"-z",
+		"--checkpoint=fast",
+	}
+	if conninfo := Conninfo(host, "", opts); conninfo != "" {
+		args = append(args, "-d", conninfo)
+	}
+	cmd := exec.Command("pg_basebackup", args...)
+
+	cmd.Env = append(os.Environ(), fmt.Sprintf("PGPASSWORD=%s", password))
+
+	err = tool.Run(cmd)
+	if err != nil {
+		os.RemoveAll(outdir)
+		return "", err
+	}
+
+	fmt.Printf("✅ Physical backup completed: %s\n", outdir)
+	return outdir, nil
+}
+
+// IsPhysicalBackup reports whether path is a pg_basebackup tar backup.
+func IsPhysicalBackup(path string) bool {
+	return findTar(path, "base") != ""
+}
+
+// BaseBackupTime returns the time encoded in a physical backup's name.
+func BaseBackupTime(path string) (time.Time, error) {
+	name := strings.TrimPrefix(filepath.Base(path), BaseBackupPrefix)
+	return time.ParseInLocation("2006-01-02_15-04-05", name, time.Local)
+}
+
+// ArchiveWAL copies a WAL segment into walDir. It is meant to be called from
+// PostgreSQL's archive_command, so it never overwrites an archived segment
+// with different contents and only reports success once the copy is durable.
+func ArchiveWAL(src, name, walDir string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid WAL file name %q", name)
+	}
+
+	err := os.MkdirAll(walDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	dst := filepath.Join(walDir, name)
+	if _, err := os.Stat(dst); err == nil {
+		// PostgreSQL may retry a segment it already archived
+		same, err := sameContents(src, dst)
+		if err != nil {
+			return err
+		}
+		if same {
+			return nil
+		}
+		return fmt.Errorf("%s is already archived with different contents", name)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(walDir, "."+name+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return err
+	}
+	// The rename is only durable once the directory entry is on disk
+	return syncDir(walDir)
+}
+
+// syncDir flushes a directory's entries to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// PrepareRecovery lays out a PostgreSQL data directory from a physical
+// backup and configures it to replay archived WAL from walDir. With a
+// non-empty targetTime, recovery stops at that time and the server is
+// promoted; otherwise it replays all archived WAL.
+//
+// Tablespaces (the <oid>.tar files next to base.tar) are extracted into
+// TablespaceDir(dataDir) rather than their original locations, which may
+// still be in use, and tablespace_map is pointed at them.
+func PrepareRecovery(backupPath, dataDir, walDir, targetTime string) error {
+	if entries, err := os.ReadDir(dataDir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("data directory %s is not empty", dataDir)
+	}
+
+	tablespaces, err := tablespaceTars(backupPath)
+	if err != nil {
+		return err
+	}
+	tablespaceDir := TablespaceDir(dataDir)
+	if len(tablespaces) > 0 {
+		if entries, err := os.ReadDir(tablespaceDir); err == nil && len(entries) > 0 {
+			return fmt.Errorf("tablespace directory %s is not empty", tablespaceDir)
+		}
+	}
+
+	baseTar := findTar(backupPath, "base")
+	if baseTar == "" {
+		return fmt.Errorf("%s is not a physical backup", backupPath)
+	}
+
+	err = os.MkdirAll(dataDir, 0700)
+	if err != nil {
+		return err
+	}
+	// PostgreSQL refuses to start on a group or world accessible data directory
+	if err := os.Chmod(dataDir, 0700); err != nil {
+		return err
+	}
+
+	if err := extractTar(baseTar, dataDir); err != nil {
+		return fmt.Errorf("extracting %s: %w", baseTar, err)
+	}
+
+	if walTar := findTar(backupPath, "pg_wal"); walTar != "" {
+		if err := extractTar(walTar, filepath.Join(dataDir, "pg_wal")); err != nil {
+			return fmt.Errorf("extracting %s: %w", walTar, err)
+		}
+	}
+
+	if len(tablespaces) > 0 {
+		if err := restoreTablespaces(tablespaces, dataDir, tablespaceDir); err != nil {
+			return err
+		}
+	}
+
+	absWALDir, err := filepath.Abs(walDir)
+	if err != nil {
+		return err
+	}
+
+	settings := fmt.Sprintf("\n# Added by BackItUp restore\nrestore_command = 'cp \"%s/%%f\" \"%%p\"'\n", absWALDir)
+	if targetTime != "" {
+		settings += fmt.Sprintf("recovery_target_time = '%s'\nrecovery_target_action = 'promote'\n", targetTime)
+	}
+
+	autoConf, err := os.OpenFile(filepath.Join(dataDir, "postgresql.auto.conf"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := autoConf.WriteString(settings); err != nil {
+		autoConf.Close()
+		return err
+	}
+	if err := autoConf.Close(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dataDir, "recovery.signal"), nil, 0600)
+}
+
+// TablespaceDir returns where PrepareRecovery extracts the tablespaces of
+// a physical backup restored into dataDir.
+func TablespaceDir(dataDir string) string {
+	return filepath.Clean(dataDir) + "_tablespaces"
+}
+
+// tablespaceTars maps the OID of every tablespace in a physical backup to
+// its tar file.
+func tablespaceTars(backupPath string) (map[string]string, error) {
+	entries, err := os.ReadDir(backupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tars := make(map[string]string)
+	for _, entry := range entries {
+		name := strings.TrimSuffix(strings.TrimSuffix(entry.Name(), ".gz"), ".tar")
+		if name == entry.Name() || name == "" || strings.Trim(name, "0123456789") != "" {
+			continue
+		}
+		tars[name] = filepath.Join(backupPath, entry.Name())
+	}
+	return tars, nil
+}
+
+// restoreTablespaces extracts every tablespace into tablespaceDir/<oid> and
+// rewrites the tablespace_map in dataDir, from which PostgreSQL recreates
+// the pg_tblspc links when recovery starts.
+func restoreTablespaces(tars map[string]string, dataDir, tablespaceDir string) error {
+	absDir, err := filepath.Abs(tablespaceDir)
+	if err != nil {
+		return err
+	}
+
+	mapPath := filepath.Join(dataDir, "tablespace_map")
+	data, err := os.ReadFile(mapPath)
+	if err != nil {
+		return fmt.Errorf("the backup has tablespaces but no tablespace_map: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	mapped := make(map[string]bool)
+	for i, line := range lines {
+		oid, _, ok := strings.Cut(line, " ")
+		tarPath, found := tars[oid]
+		if !ok || !found {
+			continue
+		}
+
+		location := filepath.Join(absDir, oid)
+		if err := extractTar(tarPath, location); err != nil {
+			return fmt.Errorf("extracting %s: %w", tarPath, err)
+		}
+		fmt.Printf("   Tablespace %s: %s\n", oid, location)
+		lines[i] = oid + " " + location
+		mapped[oid] = true
+	}
+
+	for oid := range tars {
+		if !mapped[oid] {
+			return fmt.Errorf("tablespace %s is missing from tablespace_map", oid)
+		}
+	}
+
+	return os.WriteFile(mapPath, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+}
+
+// findTar returns the path of name.tar or name.tar.gz inside dir.
+func findTar(dir, name string) string {
+	for _, candidate := range []string{name + ".tar.gz", name + ".tar"} {
+		path := filepath.Join(dir, candidate)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func extractTar(path, dest string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	if err := os.MkdirAll(dest, 0700); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dest, header.Name)
+		if !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) && target != filepath.Clean(dest) {
+			return fmt.Errorf("illegal path in archive: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(header.Mode)&0700)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func sameContents(a, b string) (bool, error) {
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	dataB, err := os.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(dataA, dataB), nil
+}
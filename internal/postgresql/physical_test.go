@@ -0,0 +1,338 @@
+package postgresql
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTar writes files as a tar archive at path, gzipped when path ends
+// in .gz.
+func writeTar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var gz *gzip.Writer
+	tw := tar.NewWriter(file)
+	if strings.HasSuffix(path, ".gz") {
+		gz = gzip.NewWriter(file)
+		tw = tar.NewWriter(gz)
+	}
+
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPrepareRecovery(t *testing.T) {
+	root := t.TempDir()
+	backup := filepath.Join(root, "base_2026-10-17_14-00-00")
+	if err := os.Mkdir(backup, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTar(t, filepath.Join(backup, "base.tar.gz"), map[string]string{
+		"PG_VERSION":        "16\n",
+		"global/pg_control": "control",
+	})
+	writeTar(t, filepath.Join(backup, "pg_wal.tar.gz"), map[string]string{
+		"000000010000000000000002": "wal",
+	})
+
+	dataDir := filepath.Join(root, "restore")
+	err := PrepareRecovery(backup, dataDir, filepath.Join(root, "wal"), "2026-10-17 14:32:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, filepath.Join(dataDir, "global", "pg_control")); got != "control" {
+		t.Errorf("pg_control = %q, want %q", got, "control")
+	}
+	if got := readFile(t, filepath.Join(dataDir, "pg_wal", "000000010000000000000002")); got != "wal" {
+		t.Errorf("WAL segment = %q, want %q", got, "wal")
+	}
+	autoConf := readFile(t, filepath.Join(dataDir, "postgresql.auto.conf"))
+	for _, want := range []string{"restore_command = ", "recovery_target_time = '2026-10-17 14:32:00'", "recovery_target_action = 'promote'"} {
+		if !strings.Contains(autoConf, want) {
+			t.Errorf("postgresql.auto.conf lacks %q:\n%s", want, autoConf)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "recovery.signal")); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(TablespaceDir(dataDir)); !os.IsNotExist(err) {
+		t.Errorf("tablespace directory created for a backup without tablespaces: %v", err)
+	}
+}
+
+func TestPrepareRecoveryTablespaces(t *testing.T) {
+	root := t.TempDir()
+	backup := filepath.Join(root, "base_2026-10-17_14-00-00")
+	if err := os.Mkdir(backup, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTar(t, filepath.Join(backup, "base.tar"), map[string]string{
+		"PG_VERSION":     "16\n",
+		"tablespace_map": "16400 /mnt/fast/pg\n16401 /mnt/slow/pg\n",
+	})
+	writeTar(t, filepath.Join(backup, "16400.tar.gz"), map[string]string{
+		"PG_16_202307071/5/16384": "fast",
+	})
+	writeTar(t, filepath.Join(backup, "16401.tar"), map[string]string{
+		"PG_16_202307071/5/16385": "slow",
+	})
+
+	dataDir := filepath.Join(root, "restore")
+	if err := PrepareRecovery(backup, dataDir, filepath.Join(root, "wal"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tablespaceDir := TablespaceDir(dataDir)
+	tests := []struct {
+		oid, file, want string
+	}{
+		{"16400", "PG_16_202307071/5/16384", "fast"},
+		{"16401", "PG_16_202307071/5/16385", "slow"},
+	}
+	for _, tt := range tests {
+		if got := readFile(t, filepath.Join(tablespaceDir, tt.oid, tt.file)); got != tt.want {
+			t.Errorf("tablespace %s: %s = %q, want %q", tt.oid, tt.file, got, tt.want)
+		}
+	}
+
+	want := "16400 " + filepath.Join(tablespaceDir, "16400") + "\n16401 " + filepath.Join(tablespaceDir, "16401") + "\n"
+	if got := readFile(t, filepath.Join(dataDir, "tablespace_map")); got != want {
+		t.Errorf("tablespace_map = %q, want %q", got, want)
+	}
+}
+
+func TestPrepareRecoveryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]map[string]string
+		wantErr string
+	}{
+		{
+			name:    "not a physical backup",
+			files:   map[string]map[string]string{"other.tar": {"x": "y"}},
+			wantErr: "is not a physical backup",
+		},
+		{
+			name: "tablespace without map",
+			files: map[string]map[string]string{
+				"base.tar":  {"PG_VERSION": "16\n"},
+				"16400.tar": {"PG_16/5/1": "x"},
+			},
+			wantErr: "no tablespace_map",
+		},
+		{
+			name: "tablespace missing from map",
+			files: map[string]map[string]string{
+				"base.tar":  {"tablespace_map": "16400 /mnt/fast/pg\n"},
+				"16400.tar": {"PG_16/5/1": "x"},
+				"16401.tar": {"PG_16/5/2": "y"},
+			},
+			wantErr: "tablespace 16401 is missing from tablespace_map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			backup := filepath.Join(root, "backup")
+			if err := os.Mkdir(backup, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, files := range tt.files {
+				writeTar(t, filepath.Join(backup, name), files)
+			}
+
+			err := PrepareRecovery(backup, filepath.Join(root, "restore"), filepath.Join(root, "wal"), "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("PrepareRecovery() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrepareRecoveryNonEmptyDataDir(t *testing.T) {
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "restore")
+	if err := os.Mkdir(dataDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "PG_VERSION"), []byte("16\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := PrepareRecovery(root, dataDir, filepath.Join(root, "wal"), "")
+	if err == nil || !strings.Contains(err.Error(), "is not empty") {
+		t.Errorf("PrepareRecovery() error = %v, want a non-empty data directory error", err)
+	}
+}
+
+func TestArchiveWAL(t *testing.T) {
+	root := t.TempDir()
+	walDir := filepath.Join(root, "wal")
+	segment := filepath.Join(root, "000000010000000000000003")
+	if err := os.WriteFile(segment, []byte("wal"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ArchiveWAL(segment, "000000010000000000000003", walDir); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(walDir, "000000010000000000000003")); got != "wal" {
+		t.Errorf("archived segment = %q, want %q", got, "wal")
+	}
+
+	// PostgreSQL retries segments it isn't sure were archived
+	if err := ArchiveWAL(segment, "000000010000000000000003", walDir); err != nil {
+		t.Errorf("archiving the same segment again: %v", err)
+	}
+
+	if err := os.WriteFile(segment, []byte("other"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := ArchiveWAL(segment, "000000010000000000000003", walDir)
+	if err == nil || !strings.Contains(err.Error(), "different contents") {
+		t.Errorf("ArchiveWAL() error = %v, want a different contents error", err)
+	}
+
+	if err := ArchiveWAL(segment, "../escape", walDir); err == nil {
+		t.Error("a WAL file name with a slash was accepted")
+	}
+
+	entries, err := os.ReadDir(walDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("WAL directory holds %d files, want only the segment", len(entries))
+	}
+}
+
+// pgCluster is a throwaway PostgreSQL server listening on a socket in dir.
+type pgCluster struct {
+	dataDir, socketDir string
+}
+
+const pgTestPort = "54329"
+
+func (c pgCluster) start(t *testing.T, options string) {
+	t.Helper()
+
+	opts := "-p " + pgTestPort + " -k " + c.socketDir + " -c listen_addresses='' " + options
+	out, err := exec.Command("pg_ctl", "-D", c.dataDir, "-o", opts, "-l", c.dataDir+".log", "-w", "start").CombinedOutput()
+	if err != nil {
+		log, _ := os.ReadFile(c.dataDir + ".log")
+		t.Fatalf("pg_ctl start: %v\n%s\n%s", err, out, log)
+	}
+	t.Cleanup(func() { c.stop() })
+}
+
+func (c pgCluster) stop() {
+	exec.Command("pg_ctl", "-D", c.dataDir, "-m", "fast", "-w", "stop").Run()
+}
+
+func (c pgCluster) query(t *testing.T, query string) string {
+	t.Helper()
+
+	out, err := exec.Command("psql", "-h", c.socketDir, "-p", pgTestPort, "-U", "postgres", "-d", "postgres", "-Atc", query).CombinedOutput()
+	if err != nil {
+		t.Fatalf("psql -c %q: %v\n%s", query, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// TestPhysicalBackupAndRecovery takes a base backup of a real server,
+// archives its WAL, and recovers it to a point between two inserts.
+func TestPhysicalBackupAndRecovery(t *testing.T) {
+	for _, name := range []string{"initdb", "pg_ctl", "pg_basebackup", "psql"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s not installed", name)
+		}
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("initdb refuses to run as root")
+	}
+
+	root := t.TempDir()
+	t.Chdir(root)
+	walDir := filepath.Join(root, "wal")
+	if err := os.Mkdir(walDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	source := pgCluster{dataDir: filepath.Join(root, "source"), socketDir: root}
+	if out, err := exec.Command("initdb", "-D", source.dataDir, "-U", "postgres", "--auth=trust").CombinedOutput(); err != nil {
+		t.Fatalf("initdb: %v\n%s", err, out)
+	}
+	source.start(t, `-c wal_level=replica -c archive_mode=on -c archive_command='cp %p `+walDir+`/%f'`)
+
+	source.query(t, "CREATE TABLE orders (id int)")
+	source.query(t, "INSERT INTO orders VALUES (1)")
+
+	backupPath, err := BackupPhysical(root, pgTestPort, "postgres", "", ConnOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source.query(t, "INSERT INTO orders VALUES (2)")
+	target := source.query(t, "SELECT clock_timestamp()")
+	time.Sleep(100 * time.Millisecond)
+	source.query(t, "INSERT INTO orders VALUES (3)")
+	source.query(t, "SELECT pg_switch_wal()")
+	source.stop()
+
+	restored := pgCluster{dataDir: filepath.Join(root, "restored"), socketDir: root}
+	if err := PrepareRecovery(backupPath, restored.dataDir, walDir, target); err != nil {
+		t.Fatal(err)
+	}
+	restored.start(t, "-c archive_mode=off")
+
+	deadline := time.Now().Add(30 * time.Second)
+	for restored.query(t, "SELECT pg_is_in_recovery()") == "t" {
+		if time.Now().After(deadline) {
+			t.Fatal("the restored server didn't finish recovery")
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	if got := restored.query(t, "SELECT string_agg(id::text, ',' ORDER BY id) FROM orders"); got != "1,2" {
+		t.Errorf("recovered rows = %s, want 1,2", got)
+	}
+}
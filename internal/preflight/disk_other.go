@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd)
+
+package preflight
+
+import "errors"
+
+// FreeSpace isn't supported on this platform.
+func FreeSpace(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
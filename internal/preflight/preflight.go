@@ -0,0 +1,227 @@
+// Package preflight checks that a backup or restore can work before it
+// starts: the tools it needs are installed, their versions fit the server,
+// and there is room for the backup.
+package preflight
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/backend"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+)
+
+// Status is the outcome of one check.
+type Status int
+
+const (
+	Pass Status = iota
+	Warn
+	Fail
+)
+
+// Result is one check and its outcome.
+type Result struct {
+	Check  string
+	Status Status
+	Detail string
+}
+
+// Report collects the results of the checks for one operation.
+type Report struct {
+	Results []Result
+}
+
+// Pass records a check that passed.
+func (r *Report) Pass(check, format string, args ...any) {
+	r.add(check, Pass, format, args...)
+}
+
+// Warn records a problem that doesn't stop the operation.
+func (r *Report) Warn(check, format string, args ...any) {
+	r.add(check, Warn, format, args...)
+}
+
+// Fail records a problem the operation can't succeed with.
+func (r *Report) Fail(check, format string, args ...any) {
+	r.add(check, Fail, format, args...)
+}
+
+func (r *Report) add(check string, status Status, format string, args ...any) {
+	r.Results = append(r.Results, Result{Check: check, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Err returns an error listing the failed checks, or nil if none failed.
+func (r *Report) Err() error {
+	var failed []string
+	for _, result := range r.Results {
+		if result.Status == Fail {
+			failed = append(failed, result.Check+": "+result.Detail)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.New("preflight failed: " + strings.Join(failed, "; "))
+}
+
+// Version is a dotted version number such as 16.2 or 8.0.36.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether v is older than other.
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+var versionNumber = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion finds the version number in a tool's --version output or a
+// server's version string. Old MySQL and MariaDB clients report
+// "Ver 10.19 Distrib 10.11.6-MariaDB"; the Distrib version is the one that
+// counts.
+func ParseVersion(s string) (Version, bool) {
+	if _, distrib, ok := strings.Cut(s, "Distrib "); ok {
+		s = distrib
+	}
+
+	m := versionNumber.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, false
+	}
+	var v Version
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	return v, true
+}
+
+// Tool checks that a tool is installed and returns its version. ok is false
+// if the tool is missing; a version that can't be parsed is only a warning.
+func (r *Report) Tool(name string) (version Version, ok bool) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		r.Fail(name, "not found in PATH")
+		return Version{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	line := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	version, parsed := ParseVersion(line)
+	if err != nil || !parsed {
+		r.Warn(name, "installed at %s, but its version couldn't be determined", path)
+		return Version{}, true
+	}
+
+	r.Pass(name, "%s (%s)", version, path)
+	return version, true
+}
+
+// ToolIn is Tool for a tool run by b, e.g. inside the database's
+// container.
+func (r *Report) ToolIn(b backend.Backend, name string) (version Version, ok bool) {
+	if b.Local() {
+		return r.Tool(name)
+	}
+
+	cmd := b.Command(nil, name, "--version")
+	out, err := runTimeout(cmd, 10*time.Second)
+	line := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	if err != nil {
+		if line == "" {
+			line = err.Error()
+		}
+		r.Fail(name, "can't run in %s: %s", b, line)
+		return Version{}, false
+	}
+
+	version, parsed := ParseVersion(line)
+	if !parsed {
+		r.Warn(name, "runs in %s, but its version couldn't be determined", b)
+		return Version{}, true
+	}
+
+	r.Pass(name, "%s (in %s)", version, b)
+	return version, true
+}
+
+// runTimeout runs cmd for its combined output, killing it after timeout.
+func runTimeout(cmd *exec.Cmd, timeout time.Duration) ([]byte, error) {
+	var out strings.Builder
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	timer := time.AfterFunc(timeout, func() { cmd.Process.Kill() })
+	defer timer.Stop()
+
+	err := cmd.Wait()
+	return []byte(out.String()), err
+}
+
+// Disk checks that the filesystem holding dir has room for a backup about
+// the size of previous. With no previous backup, it only reports the free
+// space.
+func (r *Report) Disk(dir string, previous int64) {
+	free, err := FreeSpace(dir)
+	if err != nil {
+		r.Warn("disk space", "couldn't check free space in %s: %v", dir, err)
+		return
+	}
+
+	switch {
+	case previous > 0 && free < uint64(previous):
+		r.Fail("disk space", "%s free in %s, but the previous backup was %s",
+			progress.FormatBytes(int64(free)), dir, progress.FormatBytes(previous))
+	case previous > 0 && free < 2*uint64(previous):
+		r.Warn("disk space", "%s free in %s, less than twice the previous backup (%s)",
+			progress.FormatBytes(int64(free)), dir, progress.FormatBytes(previous))
+	case previous > 0:
+		r.Pass("disk space", "%s free in %s (previous backup %s)",
+			progress.FormatBytes(int64(free)), dir, progress.FormatBytes(previous))
+	default:
+		r.Pass("disk space", "%s free in %s", progress.FormatBytes(int64(free)), dir)
+	}
+}
+
+// existingParent returns dir, or its nearest parent that exists, so free
+// space can be checked before the backup directory is created.
+func existingParent(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "."
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
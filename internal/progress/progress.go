@@ -0,0 +1,226 @@
+// Package progress reports how far a long-running restore or dump has come:
+// bytes done, percentage and ETA when the total is known, and throughput.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// How often the progress line is redrawn on a terminal, and how often a log
+// line is printed when output goes to a file or pipe.
+const (
+	ttyInterval = 500 * time.Millisecond
+	logInterval = 10 * time.Second
+)
+
+// Meter counts bytes and periodically prints a progress line until Finish
+// is called.
+type Meter struct {
+	label string
+	total int64
+	done  atomic.Int64
+	start time.Time
+
+	// sample, if set, replaces counting: it returns the bytes done so far
+	sample func() int64
+
+	out  io.Writer
+	tty  bool
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Start begins reporting progress on stdout. total is the expected number of
+// bytes, or 0 if unknown.
+func Start(label string, total int64) *Meter {
+	return start(label, total, nil)
+}
+
+// Watch is like Start for tools that write their output files themselves,
+// e.g. directory dumps: the progress is the size of path, checked on every
+// update.
+func Watch(label string, total int64, path string) *Meter {
+	return start(label, total, func() int64 { return SizeOf(path) })
+}
+
+func start(label string, total int64, sample func() int64) *Meter {
+	m := &Meter{
+		label:  label,
+		total:  total,
+		start:  time.Now(),
+		sample: sample,
+		out:    os.Stdout,
+		tty:    term.IsTerminal(int(os.Stdout.Fd())),
+		stop:   make(chan struct{}),
+	}
+
+	interval := logInterval
+	if m.tty {
+		interval = ttyInterval
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.print()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+
+	return m
+}
+
+// Add records n more bytes.
+func (m *Meter) Add(n int64) {
+	m.done.Add(n)
+}
+
+// Reader returns r with every byte read counted.
+func (m *Meter) Reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, m: m}
+}
+
+// Writer returns w with every byte written counted.
+func (m *Meter) Writer(w io.Writer) io.Writer {
+	return &countingWriter{w: w, m: m}
+}
+
+func (m *Meter) bytes() int64 {
+	if m.sample != nil {
+		m.done.Store(m.sample())
+	}
+	return m.done.Load()
+}
+
+// Finish stops reporting and prints a summary with the average throughput.
+func (m *Meter) Finish() {
+	close(m.stop)
+	m.wg.Wait()
+
+	done := m.bytes()
+	elapsed := time.Since(m.start)
+	if m.tty {
+		fmt.Fprint(m.out, "\r\033[K")
+	}
+	fmt.Fprintf(m.out, "   %s %s in %s (%s/s)\n", m.label, FormatBytes(done), formatDuration(elapsed), FormatBytes(rate(done, elapsed)))
+}
+
+func (m *Meter) print() {
+	done := m.bytes()
+	elapsed := time.Since(m.start)
+	speed := rate(done, elapsed)
+
+	var line string
+	if m.total > 0 {
+		percent := float64(done) / float64(m.total) * 100
+		if percent > 100 {
+			percent = 100
+		}
+
+		eta := "?"
+		if speed > 0 && done < m.total {
+			eta = formatDuration(time.Duration(float64(m.total-done) / float64(speed) * float64(time.Second)))
+		}
+
+		line = fmt.Sprintf("%s %s / %s (%.0f%%)  %s/s  elapsed %s  ETA %s",
+			m.label, FormatBytes(done), FormatBytes(m.total), percent, FormatBytes(speed), formatDuration(elapsed), eta)
+		if m.tty {
+			line = bar(percent) + " " + line
+		}
+	} else {
+		line = fmt.Sprintf("%s %s  %s/s  elapsed %s", m.label, FormatBytes(done), FormatBytes(speed), formatDuration(elapsed))
+	}
+
+	if m.tty {
+		fmt.Fprintf(m.out, "\r\033[K   %s", line)
+	} else {
+		fmt.Fprintf(m.out, "   %s\n", line)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	m *Meter
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.m.Add(int64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	m *Meter
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.m.Add(int64(n))
+	return n, err
+}
+
+// SizeOf returns the size of a file, or the total size of the files in a
+// directory. Missing paths have size 0.
+func SizeOf(path string) int64 {
+	if path == "" {
+		return 0
+	}
+
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func bar(percent float64) string {
+	const width = 25
+	filled := int(percent / 100 * width)
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+// rate returns bytes per second.
+func rate(bytes int64, elapsed time.Duration) int64 {
+	if elapsed < time.Second {
+		elapsed = time.Second
+	}
+	return int64(float64(bytes) / elapsed.Seconds())
+}
+
+// FormatBytes formats a byte count like 1.5 GB.
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
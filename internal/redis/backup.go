@@ -0,0 +1,339 @@
+package redis
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+)
+
+// Dir is where Redis backups are stored.
+const Dir = "BACKUP/redis"
+
+// Methods a snapshot can be taken with.
+const (
+	// MethodSync fetches the snapshot over the replication protocol, as a
+	// replica would. It works remotely and needs no access to the server's
+	// files.
+	MethodSync = "sync"
+	// MethodBGSave runs BGSAVE and copies the dump file the server wrote.
+	// The server's data directory must be readable from here.
+	MethodBGSave = "bgsave"
+)
+
+// ValidMethod reports whether method is a known snapshot method.
+func ValidMethod(method string) bool {
+	return method == MethodSync || method == MethodBGSave
+}
+
+// Backup takes an RDB snapshot of the server, stores it gzipped in
+// BACKUP/redis and returns its path. The snapshot is verified before it is
+// kept, and the SHA-256 of the stored file is recorded in its manifest.
+func Backup(c *Conn, method string) (string, error) {
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	timestamp := fmt.Sprintf("%d-%02d-%02d_%02d-%02d-%02d",
+		now.Year(), now.Month(), now.Day(),
+		now.Hour(), now.Minute(), now.Second())
+	outfile := fmt.Sprintf("%s/redis_%s.rdb.gz", Dir, timestamp)
+
+	var rdb io.Reader
+	var size int64
+	var err error
+	if method == MethodBGSave {
+		var file *os.File
+		file, size, err = bgsave(c)
+		if file != nil {
+			defer file.Close()
+			rdb = file
+		}
+	} else {
+		rdb, size, err = fullSync(c)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	checksum, err := store(rdb, size, outfile)
+	if err == nil {
+		_, err = Verify(outfile)
+	}
+	if err != nil {
+		os.Remove(outfile)
+		return "", err
+	}
+
+	m := &manifest.Manifest{
+		Engine:    "redis",
+		CreatedAt: now,
+		Kind:      manifest.KindFull,
+		SHA256:    checksum,
+	}
+	if err := manifest.Write(outfile, m); err != nil {
+		log.Printf("Warning: failed to write manifest: %v", err)
+	}
+
+	fmt.Printf("✅ Backup completed: %s\n", outfile)
+	return outfile, nil
+}
+
+// fullSync asks the server for a full resynchronization and returns the RDB
+// payload and its size. The connection can't be used for commands
+// afterwards.
+func fullSync(c *Conn) (io.Reader, int64, error) {
+	// Servers older than 2.8 only know SYNC, which answers with the
+	// payload straight away
+	if err := c.send("PSYNC", "?", "-1"); err != nil {
+		return nil, 0, err
+	}
+	line, err := c.payloadLine()
+	if err != nil {
+		return nil, 0, err
+	}
+	if strings.HasPrefix(line, "-") && strings.Contains(strings.ToLower(line), "unknown command") {
+		if err := c.send("SYNC"); err != nil {
+			return nil, 0, err
+		}
+		if line, err = c.payloadLine(); err != nil {
+			return nil, 0, err
+		}
+	} else if strings.HasPrefix(line, "+FULLRESYNC") {
+		if line, err = c.payloadLine(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(line, "-"):
+		return nil, 0, fmt.Errorf("server refused to sync: %s", line[1:])
+	case strings.HasPrefix(line, "$EOF:"):
+		// Only sent to replicas that announced "capa eof", which we don't
+		return nil, 0, errors.New("server sent a diskless snapshot without its size")
+	case strings.HasPrefix(line, "$"):
+		size, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil || size < 0 {
+			return nil, 0, fmt.Errorf("unexpected sync reply %q", line)
+		}
+		return io.LimitReader(c, size), size, nil
+	}
+	return nil, 0, fmt.Errorf("unexpected sync reply %q", line)
+}
+
+// payloadLine reads the next reply line of a sync, skipping the newlines
+// the server sends to keep the connection alive while it saves.
+func (c *Conn) payloadLine() (string, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return "", err
+		}
+		if line != "" {
+			return line, nil
+		}
+	}
+}
+
+// bgsaveTimeout bounds the wait for BGSAVE to write the dump file.
+const bgsaveTimeout = time.Hour
+
+// bgsave runs BGSAVE, waits for it to finish and opens the dump file the
+// server wrote.
+func bgsave(c *Conn) (*os.File, int64, error) {
+	before, err := c.Do("LASTSAVE")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// A save that's already running is just as good. Other refusals, like
+	// an AOF rewrite in progress, mean no save is coming.
+	if _, err := c.Do("BGSAVE"); err != nil && !strings.Contains(err.Error(), "Background save already in progress") {
+		return nil, 0, err
+	}
+
+	fmt.Println("   Waiting for BGSAVE to finish...")
+	deadline := time.Now().Add(bgsaveTimeout)
+	for {
+		if time.Now().After(deadline) {
+			return nil, 0, fmt.Errorf("BGSAVE didn't finish within %s", bgsaveTimeout)
+		}
+		time.Sleep(time.Second)
+		last, err := c.Do("LASTSAVE")
+		if err != nil {
+			return nil, 0, err
+		}
+		if last != before {
+			break
+		}
+		if status, err := c.info("persistence", "rdb_last_bgsave_status"); err == nil && status != "ok" {
+			if saving, _ := c.info("persistence", "rdb_bgsave_in_progress"); saving == "0" {
+				return nil, 0, fmt.Errorf("BGSAVE failed, see the server log")
+			}
+		}
+	}
+
+	path, _, err := DumpPath(c)
+	if err != nil {
+		return nil, 0, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("can't read the dump file the server wrote (use the sync method for remote servers): %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// configValue returns a setting from CONFIG GET.
+func configValue(c *Conn, name string) (string, error) {
+	reply, err := c.Do("CONFIG", "GET", name)
+	if err != nil {
+		return "", err
+	}
+	items, ok := reply.([]any)
+	if !ok || len(items) != 2 {
+		return "", fmt.Errorf("CONFIG GET %s returned no value", name)
+	}
+	value, _ := items[1].(string)
+	return value, nil
+}
+
+// store gzips the snapshot into outfile and returns the SHA-256 of the
+// stored file.
+func store(rdb io.Reader, size int64, outfile string) (string, error) {
+	file, err := os.Create(outfile)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	zw := gzip.NewWriter(io.MultiWriter(file, hash))
+
+	meter := progress.Start("Fetched", size)
+	n, err := io.Copy(zw, meter.Reader(rdb))
+	meter.Finish()
+	if err != nil {
+		return "", err
+	}
+	if n < size {
+		return "", fmt.Errorf("snapshot ended after %d of %d bytes", n, size)
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	if err := file.Sync(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Checksum returns the SHA-256 of a stored backup.
+func Checksum(backupPath string) (string, error) {
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// DumpPath returns where the server loads its RDB file from at startup,
+// from CONFIG GET dir and dbfilename, and whether it loads an append-only
+// file instead.
+func DumpPath(c *Conn) (path string, appendOnly bool, err error) {
+	dir, err := configValue(c, "dir")
+	if err != nil {
+		return "", false, err
+	}
+	dbfilename, err := configValue(c, "dbfilename")
+	if err != nil {
+		return "", false, err
+	}
+	aof, err := configValue(c, "appendonly")
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.Join(dir, dbfilename), aof == "yes", nil
+}
+
+// Stage verifies a backup and writes the RDB file to target, for a server
+// to load on its next start. The file is written next to target and
+// renamed over it, so target is never left half-written.
+func Stage(backupPath, target string) (*RDBInfo, error) {
+	info, err := Verify(backupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+	tmp := target + ".restoring"
+	if err := decompress(backupPath, tmp); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return info, nil
+}
+
+func decompress(backupPath, target string) error {
+	in, err := os.Open(backupPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	meter := progress.Start("Staged", progress.SizeOf(backupPath))
+	defer meter.Finish()
+
+	var r io.Reader = meter.Reader(in)
+	if strings.HasSuffix(backupPath, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, r)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// startServer runs a redis-server on a free port with its data in dir and
+// returns its address. The server is killed when the test ends.
+func startServer(t *testing.T, dir string) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	cmd := exec.Command("redis-server",
+		"--port", strconv.Itoa(port), "--bind", "127.0.0.1",
+		"--dir", dir, "--dbfilename", "dump.rdb",
+		"--save", "", "--appendonly", "no")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		c, err := Connection(addr, "", "")
+		if err == nil {
+			c.Close()
+			return addr
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("redis-server didn't start: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func connect(t *testing.T, addr string) *Conn {
+	t.Helper()
+
+	c, err := Connection(addr, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestBackupAndStage(t *testing.T) {
+	if _, err := exec.LookPath("redis-server"); err != nil {
+		t.Skip("redis-server not installed")
+	}
+
+	source := startServer(t, t.TempDir())
+	c := connect(t, source)
+	for key, value := range map[string]string{"order:1": "pending", "order:2": "shipped"} {
+		if _, err := c.Do("SET", key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, method := range []string{MethodSync, MethodBGSave} {
+		t.Run(method, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if method == MethodBGSave {
+				// LASTSAVE counts in seconds, so a save in the same second
+				// as the server's start or the sync's would go unnoticed
+				time.Sleep(time.Second)
+			}
+
+			// A sync leaves the connection unusable, so each backup gets
+			// its own
+			backupPath, err := Backup(connect(t, source), method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Verify(backupPath); err != nil {
+				t.Fatal(err)
+			}
+
+			dir := t.TempDir()
+			if _, err := Stage(backupPath, filepath.Join(dir, "dump.rdb")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "dump.rdb.restoring")); !os.IsNotExist(err) {
+				t.Errorf("staging file left behind: %v", err)
+			}
+
+			restored := connect(t, startServer(t, dir))
+			for key, want := range map[string]string{"order:1": "pending", "order:2": "shipped"} {
+				if got, err := restored.String("GET", key); err != nil || got != want {
+					t.Errorf("GET %s = %q, %v, want %q", key, got, err, want)
+				}
+			}
+		})
+	}
+}
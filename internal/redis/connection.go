@@ -0,0 +1,184 @@
+// Package redis backs up Redis by fetching RDB snapshots over the
+// replication protocol, and verifies and stages them for restores. It
+// speaks RESP over a plain TCP connection, so no client tools are needed.
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// idleTimeout is how long a read may wait for the server. A master
+// preparing a snapshot for SYNC sends a newline every second, so only a
+// dead connection gets this far.
+const idleTimeout = time.Minute
+
+// Conn is a connection to a Redis server.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Error is an error reply from the server, e.g. "NOAUTH Authentication
+// required.".
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Connection connects to the server at addr (host:port), authenticates
+// when a password is set and checks the connection with PING. user is only
+// needed for Redis 6 ACL users.
+func Connection(addr, user, password string) (*Conn, error) {
+	netConn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{conn: netConn, r: bufio.NewReaderSize(netConn, 1<<20)}
+
+	if password != "" {
+		args := []string{"AUTH", password}
+		if user != "" {
+			args = []string{"AUTH", user, password}
+		}
+		if _, err := c.Do(args...); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if _, err := c.Do("PING"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Do sends a command and returns its reply: a string for simple and bulk
+// strings, an int64 for integers, []any for arrays and nil for null
+// replies. Error replies are returned as Error.
+func (c *Conn) Do(args ...string) (any, error) {
+	if err := c.send(args...); err != nil {
+		return nil, err
+	}
+	return c.reply()
+}
+
+// String sends a command whose reply is a string.
+func (c *Conn) String(args ...string) (string, error) {
+	reply, err := c.Do(args...)
+	if err != nil {
+		return "", err
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected reply to %s: %v", args[0], reply)
+	}
+	return s, nil
+}
+
+func (c *Conn) send(args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(idleTimeout))
+	_, err := io.WriteString(c.conn, b.String())
+	return err
+}
+
+func (c *Conn) reply() (any, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader(), buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.reply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
+
+// readLine reads one CRLF-terminated line without the terminator.
+func (c *Conn) readLine() (string, error) {
+	line, err := c.reader().ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// reader returns the buffered connection with a fresh read deadline.
+func (c *Conn) reader() *bufio.Reader {
+	c.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+	return c.r
+}
+
+// Read reads from the connection, giving every read a fresh deadline so a
+// long transfer doesn't time out as long as data keeps coming.
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.reader().Read(p)
+}
+
+// info returns one field of INFO, e.g. info("server", "redis_version").
+func (c *Conn) info(section, field string) (string, error) {
+	text, err := c.String("INFO", section)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), field+":"); ok {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("INFO %s has no %s", section, field)
+}
+
+// ServerVersion returns the server's version string, e.g. "7.2.4".
+func ServerVersion(c *Conn) (string, error) {
+	return c.info("server", "redis_version")
+}
@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"bufio"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// fakeConn returns a Conn reading the given server output.
+func fakeConn(t *testing.T, output string) *Conn {
+	t.Helper()
+
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go func() {
+		server.Write([]byte(output))
+		server.Close()
+	}()
+	return &Conn{conn: client, r: bufio.NewReader(client)}
+}
+
+func TestReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    any
+		wantErr error
+	}{
+		{name: "simple string", output: "+OK\r\n", want: "OK"},
+		{name: "error", output: "-ERR Background save already in progress\r\n", wantErr: Error("ERR Background save already in progress")},
+		{name: "integer", output: ":1729159200\r\n", want: int64(1729159200)},
+		{name: "negative integer", output: ":-1\r\n", want: int64(-1)},
+		{name: "bulk string", output: "$5\r\nhello\r\n", want: "hello"},
+		{name: "bulk string with CRLF", output: "$7\r\na\r\nb\r\nc\r\n", want: "a\r\nb\r\nc"},
+		{name: "empty bulk string", output: "$0\r\n\r\n", want: ""},
+		{name: "null bulk string", output: "$-1\r\n", want: nil},
+		{name: "array", output: "*2\r\n$3\r\ndir\r\n$14\r\n/var/lib/redis\r\n", want: []any{"dir", "/var/lib/redis"}},
+		{name: "nested array", output: "*2\r\n:1\r\n*1\r\n+x\r\n", want: []any{int64(1), []any{"x"}}},
+		{name: "empty array", output: "*0\r\n", want: []any{}},
+		{name: "null array", output: "*-1\r\n", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fakeConn(t, tt.output).reply()
+			if err != tt.wantErr {
+				t.Fatalf("reply() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reply() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplyMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{name: "empty line", output: "\r\n"},
+		{name: "unknown type", output: "?what\r\n"},
+		{name: "bad integer", output: ":twelve\r\n"},
+		{name: "bad length", output: "$x\r\n"},
+		{name: "short bulk string", output: "$10\r\nhello\r\n"},
+		{name: "short array", output: "*2\r\n+one\r\n"},
+		{name: "closed connection", output: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := fakeConn(t, tt.output).reply(); err == nil {
+				t.Errorf("reply() = %#v, want an error", got)
+			}
+		})
+	}
+}
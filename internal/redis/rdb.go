@@ -0,0 +1,129 @@
+package redis
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+)
+
+// rdbEOF is the opcode that ends an RDB file, before the checksum.
+const rdbEOF = 0xFF
+
+// crcTable is CRC-64/Jones, the checksum Redis appends to RDB files since
+// version 5, in the reflected form hash/crc64 expects.
+var crcTable = crc64.MakeTable(0x95ac9329ac4bc9b5)
+
+// RDBInfo describes a verified RDB file.
+type RDBInfo struct {
+	Version int
+	Size    int64
+	// Checksum is the file's CRC-64, or 0 if the server was configured
+	// with rdbchecksum no.
+	Checksum uint64
+}
+
+// Verify checks that a backup is a complete RDB file: the REDIS header, the
+// EOF opcode and, unless the server had checksums turned off, the CRC-64
+// over the whole file. .gz backups are decompressed on the fly. When the
+// manifest records a SHA-256, the stored file must match it too.
+func Verify(backupPath string) (*RDBInfo, error) {
+	if m, err := manifest.Read(backupPath); err == nil && m.SHA256 != "" {
+		checksum, err := Checksum(backupPath)
+		if err != nil {
+			return nil, err
+		}
+		if checksum != m.SHA256 {
+			return nil, fmt.Errorf("%s: SHA-256 doesn't match the manifest, the file was changed or damaged", backupPath)
+		}
+	}
+
+	file, err := os.Open(backupPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = bufio.NewReaderSize(file, 1<<20)
+	if strings.HasSuffix(backupPath, ".gz") {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", backupPath, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	info, err := verifyRDB(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", backupPath, err)
+	}
+	return info, nil
+}
+
+// verifyRDB reads an RDB stream to the end and checks it.
+func verifyRDB(r io.Reader) (*RDBInfo, error) {
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.New("not an RDB file: too short")
+	}
+	if string(header[:5]) != "REDIS" {
+		return nil, errors.New("not an RDB file: missing REDIS header")
+	}
+	version, err := strconv.Atoi(string(header[5:]))
+	if err != nil {
+		return nil, fmt.Errorf("not an RDB file: bad version %q", header[5:])
+	}
+
+	// Version 5 and later end with the EOF opcode and an 8-byte checksum
+	// of everything before it; older files just end with the opcode
+	trailer := 0
+	if version >= 5 {
+		trailer = 8
+	}
+
+	crc := ^crc64.Update(^uint64(0), crcTable, header)
+	size := int64(len(header))
+	held := make([]byte, 0, trailer+64<<10)
+	var last byte = header[len(header)-1]
+
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := r.Read(buf)
+		held = append(held, buf[:n]...)
+		size += int64(n)
+		if len(held) > trailer {
+			done := held[:len(held)-trailer]
+			crc = ^crc64.Update(^crc, crcTable, done)
+			last = done[len(done)-1]
+			held = append(held[:0], held[len(held)-trailer:]...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(held) < trailer || last != rdbEOF {
+		return nil, errors.New("RDB file is truncated: no EOF marker")
+	}
+
+	info := &RDBInfo{Version: version, Size: size}
+	if trailer > 0 {
+		info.Checksum = binary.LittleEndian.Uint64(held)
+		if info.Checksum != 0 && info.Checksum != crc {
+			return nil, fmt.Errorf("RDB checksum mismatch: file says %016x, content is %016x", info.Checksum, crc)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checksum computes the CRC-64 Redis appends to RDB files.
+func checksum(data []byte) uint64 {
+	return ^crc64.Update(^uint64(0), crcTable, data)
+}
+
+// rdbFile builds an RDB file of the given version around body, ending with
+// the EOF opcode and, from version 5 on, the checksum sum returns.
+func rdbFile(version string, body []byte, sum func([]byte) uint64) []byte {
+	data := append([]byte("REDIS"+version), body...)
+	data = append(data, rdbEOF)
+	if version >= "0005" {
+		data = binary.LittleEndian.AppendUint64(data, sum(data))
+	}
+	return data
+}
+
+func TestChecksum(t *testing.T) {
+	// The check value from Redis' crc64.c
+	if got, want := checksum([]byte("123456789")), uint64(0xe9c6d914c4b8d9ca); got != want {
+		t.Errorf("checksum(123456789) = %016x, want %016x", got, want)
+	}
+}
+
+func TestVerifyRDB(t *testing.T) {
+	body := []byte("\xfa\x09redis-ver\x057.2.4\xfe\x00\xfb\x01\x00\x00\x03key\x05value")
+	large := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	noChecksum := func([]byte) uint64 { return 0 }
+
+	valid := rdbFile("0011", body, checksum)
+	corrupt := bytes.Clone(valid)
+	corrupt[12] ^= 0xff
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantVersion int
+		wantErr     string
+	}{
+		{name: "valid", data: valid, wantVersion: 11},
+		{name: "spans several reads", data: rdbFile("0011", large, checksum), wantVersion: 11},
+		{name: "checksums turned off", data: rdbFile("0011", body, noChecksum), wantVersion: 11},
+		{name: "version 4 without checksum", data: rdbFile("0004", body, nil), wantVersion: 4},
+		{name: "empty", data: nil, wantErr: "too short"},
+		{name: "too short", data: []byte("REDIS"), wantErr: "too short"},
+		{name: "missing header", data: append([]byte("RADIS0011"), valid[9:]...), wantErr: "missing REDIS header"},
+		{name: "bad version", data: append([]byte("REDIS00x1"), valid[9:]...), wantErr: "bad version"},
+		{name: "truncated", data: valid[:len(valid)-9], wantErr: "no EOF marker"},
+		{name: "truncated checksum", data: valid[:len(valid)-4], wantErr: "no EOF marker"},
+		{name: "checksum mismatch", data: corrupt, wantErr: "checksum mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := verifyRDB(bytes.NewReader(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("verifyRDB() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyRDB() error = %v", err)
+			}
+			if info.Version != tt.wantVersion {
+				t.Errorf("Version = %d, want %d", info.Version, tt.wantVersion)
+			}
+			if info.Size != int64(len(tt.data)) {
+				t.Errorf("Size = %d, want %d", info.Size, len(tt.data))
+			}
+		})
+	}
+}
+
+func TestVerifyGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis_2026-10-17_14-00-00.rdb.gz")
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(rdbFile("0011", []byte("\xfe\x00"), checksum))
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := Verify(path)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if info.Version != 11 || info.Checksum == 0 {
+		t.Errorf("Verify() = %+v, want version 11 with a checksum", info)
+	}
+}
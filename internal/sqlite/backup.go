@@ -0,0 +1,276 @@
+// Package sqlite backs up and restores SQLite database files with the
+// sqlite3 command-line shell.
+package sqlite
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tiyfiy/BackItUp/internal/manifest"
+	"github.com/tiyfiy/BackItUp/internal/preflight"
+	"github.com/tiyfiy/BackItUp/internal/progress"
+	"github.com/tiyfiy/BackItUp/internal/tool"
+)
+
+// Dir is where SQLite backups are stored.
+const Dir = "BACKUP/sqlite"
+
+// Backup formats.
+const (
+	// FormatCopy is a compacted, consistent copy of the database file.
+	FormatCopy = "copy"
+	// FormatSQL is a SQL script, like the sqlite3 shell's .dump.
+	FormatSQL = "sql"
+)
+
+// ValidFormat reports whether format is a known backup format.
+func ValidFormat(format string) bool {
+	return format == FormatCopy || format == FormatSQL
+}
+
+// Backup copies the database at source into BACKUP/sqlite and returns the
+// path of the backup. Copies are made with VACUUM INTO (or the online
+// backup API on sqlite3 older than 3.27), so the database can stay in use.
+// Every copy is checked with PRAGMA integrity_check before it is kept.
+func Backup(source, format string) (string, error) {
+	if _, err := os.Stat(source); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	timestamp := fmt.Sprintf("%d-%02d-%02d_%02d-%02d-%02d",
+		now.Year(), now.Month(), now.Day(),
+		now.Hour(), now.Minute(), now.Second())
+	name := Name(source)
+
+	// Estimate the size from the previous backup, or from the database file
+	total := progress.SizeOf(manifest.Latest(Dir, "sqlite", name, manifest.KindFull))
+	if total == 0 {
+		total = progress.SizeOf(source)
+	}
+
+	var outfile string
+	var err error
+	if format == FormatSQL {
+		outfile = fmt.Sprintf("%s/%s_%s.sql", Dir, name, timestamp)
+		err = dump(source, outfile, total)
+	} else {
+		outfile = fmt.Sprintf("%s/%s_%s.db", Dir, name, timestamp)
+		err = copyDatabase(source, outfile, total)
+	}
+	if err == nil {
+		err = Verify(outfile)
+	}
+	if err != nil {
+		os.Remove(outfile)
+		return "", err
+	}
+
+	absSource, _ := filepath.Abs(source)
+	m := &manifest.Manifest{
+		Engine:    "sqlite",
+		Database:  name,
+		Source:    absSource,
+		CreatedAt: now,
+		Kind:      manifest.KindFull,
+	}
+	if err := manifest.Write(outfile, m); err != nil {
+		log.Printf("Warning: failed to write manifest: %v", err)
+	}
+
+	fmt.Printf("✅ Backup completed: %s\n", outfile)
+	return outfile, nil
+}
+
+// Name is what a database's backups are named after: its file name
+// without the extension, e.g. "app" for /srv/app/app.db.
+func Name(source string) string {
+	base := filepath.Base(source)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// vacuumInto is the first sqlite3 version with VACUUM INTO.
+var vacuumInto = preflight.Version{Major: 3, Minor: 27}
+
+func copyDatabase(source, outfile string, total int64) error {
+	out, err := tool.RunOutput(exec.Command("sqlite3", "--version"))
+	if err != nil {
+		return err
+	}
+
+	// Older shells have .backup, which copies the pages as they are
+	statement := "VACUUM INTO " + quote(outfile)
+	if version, ok := preflight.ParseVersion(string(out)); ok && version.Less(vacuumInto) {
+		statement = ".backup " + quote(outfile)
+	}
+
+	meter := progress.Watch("Copied", total, outfile)
+	defer meter.Finish()
+	return tool.Run(exec.Command("sqlite3", "-readonly", source, statement))
+}
+
+func dump(source, outfile string, total int64) error {
+	output, err := os.Create(outfile)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	meter := progress.Start("Dumped", total)
+	cmd := exec.Command("sqlite3", "-readonly", source, ".dump")
+	cmd.Stdout = meter.Writer(output)
+	err = tool.Run(cmd)
+	meter.Finish()
+	return err
+}
+
+// Verify checks a backup with PRAGMA integrity_check. SQL backups are
+// loaded into an in-memory database first, which also catches scripts that
+// don't run.
+func Verify(backupPath string) error {
+	var cmd *exec.Cmd
+	var input io.Reader
+
+	if isSQL(backupPath) {
+		r, closeFile, err := openScript(backupPath)
+		if err != nil {
+			return err
+		}
+		defer closeFile()
+
+		cmd = exec.Command("sqlite3", "-bail", ":memory:")
+		input = io.MultiReader(r, strings.NewReader("\nPRAGMA integrity_check;\n"))
+	} else {
+		cmd = exec.Command("sqlite3", "-readonly", backupPath, "PRAGMA integrity_check;")
+	}
+	cmd.Stdin = input
+
+	out, err := tool.RunOutput(cmd)
+	if err != nil {
+		return err
+	}
+
+	result := strings.TrimSpace(string(out))
+	if result != "ok" {
+		return fmt.Errorf("integrity check of %s failed:\n%s", backupPath, result)
+	}
+	return nil
+}
+
+// Restore writes a backup to target, replacing the database there. The
+// new database is built next to target and renamed over it, so target is
+// never left half-written. Applications using target should be stopped.
+func Restore(backupPath, target string) error {
+	if err := Verify(backupPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	tmp := target + ".restoring"
+	os.Remove(tmp)
+
+	var err error
+	if isSQL(backupPath) {
+		err = load(backupPath, tmp)
+	} else {
+		err = copyFile(backupPath, tmp)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// A leftover write-ahead log from the old database would be applied to
+	// the new one
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(target + suffix); err != nil && !os.IsNotExist(err) {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, target)
+}
+
+// load runs a SQL backup against a new database file.
+func load(backupPath, target string) error {
+	r, closeFile, err := openScript(backupPath)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+
+	meter := progress.Start("Restored", progress.SizeOf(backupPath))
+	defer meter.Finish()
+
+	cmd := exec.Command("sqlite3", "-bail", target)
+	cmd.Stdin = meter.Reader(r)
+	return tool.Run(cmd)
+}
+
+func copyFile(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	meter := progress.Start("Restored", progress.SizeOf(source))
+	_, err = io.Copy(out, meter.Reader(in))
+	meter.Finish()
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// openScript opens a SQL backup, decompressing .sql.gz files.
+func openScript(path string) (io.Reader, func(), error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := bufio.NewReaderSize(file, 1<<20)
+	if !strings.HasSuffix(path, ".gz") {
+		return r, func() { file.Close() }, nil
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return zr, func() {
+		zr.Close()
+		file.Close()
+	}, nil
+}
+
+func isSQL(path string) bool {
+	return strings.HasSuffix(path, ".sql") || strings.HasSuffix(path, ".sql.gz")
+}
+
+// quote quotes a path as a SQL string literal.
+func quote(path string) string {
+	return "'" + strings.ReplaceAll(path, "'", "''") + "'"
+}
@@ -0,0 +1,242 @@
+package sqlite
+
+import (
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func requireSQLite(t *testing.T) {
+	t.Helper()
+
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+}
+
+// run runs statements against the database at path and returns the output.
+func run(t *testing.T, path, statements string) string {
+	t.Helper()
+
+	out, err := exec.Command("sqlite3", "-bail", path, statements).CombinedOutput()
+	if err != nil {
+		t.Fatalf("sqlite3 %s: %v\n%s", path, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// createDatabase creates a database in WAL mode with rows rows in orders.
+func createDatabase(t *testing.T, path string, rows int) {
+	t.Helper()
+
+	run(t, path, `PRAGMA journal_mode = WAL;
+CREATE TABLE orders (id INTEGER PRIMARY KEY, note TEXT);
+WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < `+strconv.Itoa(rows)+`)
+INSERT INTO orders SELECT i, 'it''s order ' || i || printf('%.200c', 'x') FROM n;`)
+}
+
+func TestBackupCopy(t *testing.T) {
+	requireSQLite(t)
+	t.Chdir(t.TempDir())
+
+	createDatabase(t, "app.db", 500)
+
+	backupPath, err := Backup("app.db", FormatCopy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(backupPath, Dir+"/app_") || !strings.HasSuffix(backupPath, ".db") {
+		t.Errorf("backup path = %s", backupPath)
+	}
+	if got := run(t, backupPath, "SELECT count(*) FROM orders;"); got != "500" {
+		t.Errorf("the copy has %s orders, want 500", got)
+	}
+	// VACUUM INTO writes a plain database, not one in WAL mode
+	if got := run(t, backupPath, "PRAGMA journal_mode;"); got != "delete" {
+		t.Errorf("the copy's journal mode = %s, want delete", got)
+	}
+}
+
+func TestBackupCopyWithOldShell(t *testing.T) {
+	requireSQLite(t)
+	real, _ := exec.LookPath("sqlite3")
+	t.Chdir(t.TempDir())
+
+	// A shell that reports a version without VACUUM INTO and logs the
+	// statements it runs
+	bin := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"if [ \"$1\" = --version ]; then echo '3.22.0 2018-01-22 18:45:57'; exit 0; fi\n" +
+		"printf '%s\\n' \"$@\" >> " + filepath.Join(bin, "log") + "\n" +
+		"exec " + real + " \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "sqlite3"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	createDatabase(t, "app.db", 10)
+	backupPath, err := Backup("app.db", FormatCopy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := os.ReadFile(filepath.Join(bin, "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), ".backup '"+backupPath+"'") || strings.Contains(string(log), "VACUUM INTO") {
+		t.Errorf("the old shell wasn't asked for .backup:\n%s", log)
+	}
+	if got := run(t, backupPath, "SELECT count(*) FROM orders;"); got != "10" {
+		t.Errorf("the copy has %s orders, want 10", got)
+	}
+}
+
+func TestBackupSQL(t *testing.T) {
+	requireSQLite(t)
+	t.Chdir(t.TempDir())
+
+	createDatabase(t, "app.db", 3)
+
+	backupPath, err := Backup("app.db", FormatSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(backupPath, ".sql") {
+		t.Errorf("backup path = %s, want a .sql file", backupPath)
+	}
+
+	script, err := os.ReadFile(backupPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"BEGIN TRANSACTION;", "CREATE TABLE orders", "INSERT INTO orders VALUES(3,'it''s order 3", "COMMIT;"} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("the dump doesn't contain %q", want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	requireSQLite(t)
+	dir := t.TempDir()
+
+	good := filepath.Join(dir, "good.db")
+	createDatabase(t, good, 2000)
+	run(t, good, "PRAGMA journal_mode = DELETE;")
+	if err := Verify(good); err != nil {
+		t.Errorf("Verify() of a good database: %v", err)
+	}
+
+	// Overwrite a page in the middle of the table with garbage
+	data, err := os.ReadFile(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := filepath.Join(dir, "corrupt.db")
+	middle := len(data) / 2 / 4096 * 4096
+	copy(data[middle:middle+4096], strings.Repeat("garbage!", 512))
+	if err := os.WriteFile(corrupt, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(corrupt); err == nil {
+		t.Error("Verify() accepted a corrupt database")
+	}
+
+	notDatabase := filepath.Join(dir, "notes.db")
+	if err := os.WriteFile(notDatabase, []byte(strings.Repeat("not a database ", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(notDatabase); err == nil {
+		t.Error("Verify() accepted a file that isn't a database")
+	}
+
+	badScript := filepath.Join(dir, "bad.sql")
+	if err := os.WriteFile(badScript, []byte("CREATE TABLE t (id INTEGER);\nINSERT INTO missing VALUES (1);\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(badScript); err == nil {
+		t.Error("Verify() accepted a script that doesn't run")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	requireSQLite(t)
+
+	tests := []struct {
+		name   string
+		backup func(t *testing.T, dir string) string
+	}{
+		{
+			name: "copy",
+			backup: func(t *testing.T, dir string) string {
+				path := filepath.Join(dir, "backup.db")
+				createDatabase(t, path, 7)
+				run(t, path, "PRAGMA journal_mode = DELETE;")
+				return path
+			},
+		},
+		{
+			name: "gzipped SQL",
+			backup: func(t *testing.T, dir string) string {
+				source := filepath.Join(dir, "source.db")
+				createDatabase(t, source, 7)
+
+				path := filepath.Join(dir, "backup.sql.gz")
+				file, err := os.Create(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gz := gzip.NewWriter(file)
+				gz.Write([]byte(run(t, source, ".dump") + "\n"))
+				if err := gz.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if err := file.Close(); err != nil {
+					t.Fatal(err)
+				}
+				return path
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			backupPath := tt.backup(t, dir)
+
+			// The database being replaced has other data and a write-ahead
+			// log that must not be applied to the restored one
+			target := filepath.Join(dir, "live", "app.db")
+			if err := os.Mkdir(filepath.Dir(target), 0755); err != nil {
+				t.Fatal(err)
+			}
+			run(t, target, "CREATE TABLE customers (id INTEGER);")
+			for _, suffix := range []string{"-wal", "-shm"} {
+				if err := os.WriteFile(target+suffix, []byte("stale"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := Restore(backupPath, target); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := run(t, target, "SELECT count(*) FROM orders;"); got != "7" {
+				t.Errorf("the restored database has %s orders, want 7", got)
+			}
+			if got := run(t, target, "SELECT name FROM sqlite_master WHERE name = 'customers';"); got != "" {
+				t.Error("the old database's tables are still there")
+			}
+			for _, suffix := range []string{"-wal", "-shm", ".restoring"} {
+				if _, err := os.Stat(target + suffix); !os.IsNotExist(err) {
+					t.Errorf("%s was left behind", filepath.Base(target+suffix))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// Package tlsconf holds the TLS settings of a database connection, shared by
+// the MySQL and PostgreSQL engines, and builds the Go TLS configuration for
+// their drivers.
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLS modes, named after libpq's sslmode.
+const (
+	// ModeDisable never uses TLS.
+	ModeDisable = "disable"
+	// ModePrefer uses TLS when the server supports it, without checking
+	// its certificate.
+	ModePrefer = "prefer"
+	// ModeRequire always uses TLS, without checking the certificate.
+	ModeRequire = "require"
+	// ModeVerifyCA checks that the server certificate is signed by the CA.
+	ModeVerifyCA = "verify-ca"
+	// ModeVerifyFull also checks that the certificate is issued for the
+	// server name.
+	ModeVerifyFull = "verify-full"
+)
+
+// ValidMode reports whether mode is a known TLS mode. An empty mode keeps
+// the engine's default.
+func ValidMode(mode string) bool {
+	switch mode {
+	case "", ModeDisable, ModePrefer, ModeRequire, ModeVerifyCA, ModeVerifyFull:
+		return true
+	}
+	return false
+}
+
+// Config is the TLS setup of a connection.
+type Config struct {
+	Mode string
+	// CA is the PEM file of the certificate authority that signed the
+	// server certificate. Without it the system roots are used.
+	CA string
+	// Cert and Key are the PEM files of a client certificate, for servers
+	// that authenticate clients by certificate.
+	Cert string
+	Key  string
+	// ServerName is the name the server certificate is checked against
+	// when it isn't the host connected to, e.g. through a tunnel.
+	ServerName string
+}
+
+// Verify reports whether the server certificate is checked.
+func (c Config) Verify() bool {
+	return c.Mode == ModeVerifyCA || c.Mode == ModeVerifyFull
+}
+
+// Client builds the TLS configuration for a connection to host.
+func (c Config) Client(host string) (*tls.Config, error) {
+	tlsCfg := &tls.Config{ServerName: host}
+	if c.ServerName != "" {
+		tlsCfg.ServerName = c.ServerName
+	}
+
+	if c.CA != "" {
+		pem, err := os.ReadFile(c.CA)
+		if err != nil {
+			return nil, fmt.Errorf("TLS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("TLS CA: no certificates in %s", c.CA)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if c.Cert != "" || c.Key != "" {
+		cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("TLS client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	switch c.Mode {
+	case ModeVerifyFull:
+		// Go checks the chain and the name by default
+	case ModeVerifyCA:
+		// Go can't check the chain without the name, so that is done here
+		tlsCfg.InsecureSkipVerify = true
+		tlsCfg.VerifyPeerCertificate = verifyChain(tlsCfg.RootCAs)
+	default:
+		tlsCfg.InsecureSkipVerify = true
+	}
+	return tlsCfg, nil
+}
+
+// verifyChain checks the server certificate against roots (the system
+// roots when nil), ignoring the name it was issued for.
+func verifyChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server sent no certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
@@ -0,0 +1,151 @@
+// Package tool runs the external database tools (pg_dump, mysqldump,
+// mongodump, ...) and turns their failures into errors that carry what the
+// tool printed on stderr, with a hint for the common causes.
+package tool
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// maxStderr bounds how much of a tool's stderr is kept. Errors are usually
+// at the end, so the tail is kept.
+const maxStderr = 8 << 10
+
+// Error is a failed tool run.
+type Error struct {
+	Tool   string
+	Err    error
+	Stderr string
+	// Hint explains a recognized failure, e.g. a wrong password.
+	Hint string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s failed: %v", e.Tool, e.Err)
+	if e.Stderr != "" {
+		msg += "\n   " + strings.ReplaceAll(e.Stderr, "\n", "\n   ")
+	}
+	if e.Hint != "" {
+		msg += "\n💡 " + e.Hint
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Output keeps the tail of a tool's stderr.
+type Output struct {
+	tool string
+	mu   sync.Mutex
+	buf  []byte
+}
+
+func (o *Output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.buf = append(o.buf, p...)
+	if len(o.buf) > maxStderr {
+		o.buf = o.buf[len(o.buf)-maxStderr:]
+	}
+	return len(p), nil
+}
+
+// String returns the captured stderr, trimmed.
+func (o *Output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return string(bytes.TrimSpace(o.buf))
+}
+
+// Check turns the result of running the command into an *Error carrying
+// its stderr. It returns nil if err is nil.
+func (o *Output) Check(err error) error {
+	if err == nil {
+		return nil
+	}
+	stderr := o.String()
+	return &Error{Tool: o.tool, Err: err, Stderr: stderr, Hint: Classify(stderr)}
+}
+
+// Capture attaches a bounded stderr capture to cmd. If cmd already has a
+// Stderr (e.g. os.Stderr for live output), the output still goes there too.
+// Use it with cmd.Start and cmd.Wait; for a plain run, use Run.
+func Capture(cmd *exec.Cmd) *Output {
+	out := &Output{tool: filepath.Base(cmd.Path)}
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, out)
+	} else {
+		cmd.Stderr = out
+	}
+	return out
+}
+
+// Run runs cmd and returns an *Error with its stderr if it fails.
+func Run(cmd *exec.Cmd) error {
+	out := Capture(cmd)
+	return out.Check(cmd.Run())
+}
+
+// RunOutput runs cmd and returns its stdout, or an *Error with its stderr.
+func RunOutput(cmd *exec.Cmd) ([]byte, error) {
+	out := &Output{tool: filepath.Base(cmd.Path)}
+	cmd.Stderr = out
+	stdout, err := cmd.Output()
+	return stdout, out.Check(err)
+}
+
+// failure is a recognizable error message and what to do about it.
+type failure struct {
+	patterns []string
+	hint     string
+}
+
+var failures = []failure{
+	{
+		[]string{"password authentication failed", "access denied for user", "authentication failed", "no password supplied", "role \""},
+		"Authentication failed: check the user and password in config.yaml.",
+	},
+	{
+		[]string{"does not exist", "unknown database", "ns not found"},
+		"The database or object doesn't exist on the server: check the database name in config.yaml.",
+	},
+	{
+		[]string{"permission denied", "command denied", "you need (at least one of)", "not authorized", "must be superuser", "must be owner"},
+		"The user lacks privileges: grant it read access to every table (MySQL dumps also need LOCK TABLES, RELOAD or PROCESS).",
+	},
+	{
+		[]string{"server version mismatch", "version mismatch", "unsupported version", "unknown table 'column_statistics'"},
+		"The client tool doesn't match the server version: install client tools at least as new as the server.",
+	},
+	{
+		[]string{"connection refused", "could not connect", "can't connect", "no such host", "server selection error", "timed out"},
+		"Can't reach the server: check the host and port in config.yaml and that the server is running.",
+	},
+	{
+		[]string{"no space left on device"},
+		"The disk is full: free up space, e.g. with ./BackItUp cleanup.",
+	},
+}
+
+// Classify returns a hint for common failures recognized in a tool's
+// stderr, or "" if none matches.
+func Classify(stderr string) string {
+	lower := strings.ToLower(stderr)
+	for _, f := range failures {
+		for _, pattern := range f.patterns {
+			if strings.Contains(lower, pattern) {
+				return f.hint
+			}
+		}
+	}
+	return ""
+}
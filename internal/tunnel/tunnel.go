@@ -0,0 +1,275 @@
+// Package tunnel reaches databases behind bastion hosts by forwarding a
+// local port over SSH, optionally through a chain of jump hosts.
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Config is the SSH route to a database server.
+type Config struct {
+	// Host is the bastion, as host or host:port (port 22 by default).
+	Host string
+	// User is the SSH user on the bastion and on jump hosts that don't
+	// name their own.
+	User string
+	// Key is the private key file. Without it the keys of the SSH agent
+	// (SSH_AUTH_SOCK) are used.
+	Key string
+	// KnownHosts is the known_hosts file host keys are checked against,
+	// ~/.ssh/known_hosts by default.
+	KnownHosts string
+	// Jump are the hosts to go through before Host, in order, as
+	// [user@]host[:port].
+	Jump []string
+}
+
+// Enabled reports whether a tunnel is configured.
+func (c Config) Enabled() bool {
+	return c.Host != ""
+}
+
+// Tunnel is a local port forwarded to a remote address over SSH.
+type Tunnel struct {
+	// Host and Port are the local end of the tunnel.
+	Host string
+	Port string
+
+	listener net.Listener
+	clients  []*ssh.Client
+	remote   string
+	wg       sync.WaitGroup
+}
+
+// Open connects to the bastion through the jump hosts and starts
+// forwarding a local port to remote (host:port, as seen from the bastion).
+func Open(cfg Config, remote string) (*Tunnel, error) {
+	auth, err := authMethod(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := hostKeyCallback(cfg.KnownHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tunnel{remote: remote}
+
+	hops := append(append([]string{}, cfg.Jump...), cfg.Host)
+	for _, hop := range hops {
+		user, addr := splitHop(hop, cfg.User)
+		client, err := t.dial(addr, &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: hostKeys,
+			Timeout:         15 * time.Second,
+		})
+		if err != nil {
+			t.Close()
+			return nil, fmt.Errorf("SSH to %s: %w", addr, err)
+		}
+		t.clients = append(t.clients, client)
+	}
+
+	// Fail early if the bastion can't reach the database
+	conn, err := t.bastion().Dial("tcp", remote)
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("bastion %s can't reach %s: %w", cfg.Host, remote, err)
+	}
+	conn.Close()
+
+	t.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.Host, t.Port, _ = net.SplitHostPort(t.listener.Addr().String())
+
+	go t.serve()
+	return t, nil
+}
+
+// Addr returns the local end of the tunnel as host:port.
+func (t *Tunnel) Addr() string {
+	return net.JoinHostPort(t.Host, t.Port)
+}
+
+// Close stops forwarding and disconnects from the SSH hosts.
+func (t *Tunnel) Close() error {
+	if t.listener != nil {
+		t.listener.Close()
+	}
+	for i := len(t.clients) - 1; i >= 0; i-- {
+		t.clients[i].Close()
+	}
+	t.wg.Wait()
+	return nil
+}
+
+// dial opens an SSH connection to addr, directly for the first hop and
+// through the previous hop after that.
+func (t *Tunnel) dial(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	client, err := t.handshake(addr, config)
+
+	// Go prefers other host key types than OpenSSH, so known_hosts may
+	// only hold a key of a type Go didn't ask for. Ask for that one.
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) && len(keyErr.Want) > 0 && config.HostKeyAlgorithms == nil {
+		for _, known := range keyErr.Want {
+			config.HostKeyAlgorithms = append(config.HostKeyAlgorithms, keyAlgorithms(known.Key.Type())...)
+		}
+		client, err = t.handshake(addr, config)
+	}
+
+	if errors.As(err, &keyErr) {
+		if len(keyErr.Want) == 0 {
+			host, port, _ := net.SplitHostPort(addr)
+			return nil, fmt.Errorf("host key is not in known_hosts, check it and add it with: ssh-keyscan -p %s %s >> ~/.ssh/known_hosts", port, host)
+		}
+		return nil, errors.New("host key doesn't match known_hosts, the host may be impersonated")
+	}
+	return client, err
+}
+
+func (t *Tunnel) handshake(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	var conn net.Conn
+	var err error
+	if len(t.clients) == 0 {
+		conn, err = net.DialTimeout("tcp", addr, config.Timeout)
+	} else {
+		conn, err = t.bastion().Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// bastion returns the last SSH hop, which the forwarded connections leave
+// from.
+func (t *Tunnel) bastion() *ssh.Client {
+	return t.clients[len(t.clients)-1]
+}
+
+// serve forwards every local connection until the tunnel is closed.
+func (t *Tunnel) serve() {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			defer local.Close()
+
+			remote, err := t.bastion().Dial("tcp", t.remote)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: tunnel can't reach %s: %v\n", t.remote, err)
+				return
+			}
+			defer remote.Close()
+
+			done := make(chan struct{}, 2)
+			go func() {
+				io.Copy(remote, local)
+				done <- struct{}{}
+			}()
+			go func() {
+				io.Copy(local, remote)
+				done <- struct{}{}
+			}()
+			<-done
+		}()
+	}
+}
+
+// authMethod authenticates with the key file, or the SSH agent when there
+// is none.
+func authMethod(keyPath string) (ssh.AuthMethod, error) {
+	if keyPath != "" {
+		pem, err := os.ReadFile(expandHome(keyPath))
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("%s is protected by a passphrase, load it into ssh-agent and leave the key unset", keyPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keyPath, err)
+		}
+		return ssh.PublicKeys(signer), nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("no SSH key configured and no SSH agent running (SSH_AUTH_SOCK is unset)")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("SSH agent: %w", err)
+	}
+	return ssh.PublicKeysCallback(agent.NewClient(conn).Signers), nil
+}
+
+// hostKeyCallback checks host keys against the known_hosts file.
+func hostKeyCallback(path string) (ssh.HostKeyCallback, error) {
+	if path == "" {
+		path = "~/.ssh/known_hosts"
+	}
+	callback, err := knownhosts.New(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// keyAlgorithms returns the host key algorithms that produce keys of type.
+func keyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// splitHop parses [user@]host[:port], defaulting to user and port 22.
+func splitHop(hop, user string) (string, string) {
+	if at := strings.LastIndex(hop, "@"); at >= 0 {
+		user, hop = hop[:at], hop[at+1:]
+	}
+	if _, _, err := net.SplitHostPort(hop); err != nil {
+		hop = net.JoinHostPort(hop, "22")
+	}
+	return user, hop
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
@@ -0,0 +1,427 @@
+package tunnel
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer is an in-process SSH server that only forwards direct-tcpip
+// channels, like a bastion with AllowTcpForwarding.
+type sshServer struct {
+	addr string
+
+	mu      sync.Mutex
+	dialled []string
+}
+
+// newSSHServer starts an SSH server with the given host keys that accepts
+// clientKey.
+func newSSHServer(t *testing.T, clientKey ssh.PublicKey, hostKeys ...ssh.Signer) *sshServer {
+	t.Helper()
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	for _, key := range hostKeys {
+		config.AddHostKey(key)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &sshServer{addr: listener.Addr().String()}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *sshServer) handle(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "only port forwarding")
+			continue
+		}
+
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		addr := net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port)))
+
+		s.mu.Lock()
+		s.dialled = append(s.dialled, addr)
+		s.mu.Unlock()
+
+		remote, err := net.Dial("tcp", addr)
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			remote.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			defer channel.Close()
+			defer remote.Close()
+			go io.Copy(remote, channel)
+			io.Copy(channel, remote)
+		}()
+	}
+}
+
+// dialledAddrs returns the addresses the server forwarded connections to.
+func (s *sshServer) dialledAddrs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.dialled...)
+}
+
+// echoServer starts a TCP server standing in for a database, which echoes
+// what it reads.
+func echoServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func newEd25519Signer(t *testing.T) (ssh.Signer, ed25519.PrivateKey) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, key
+}
+
+// writeClientKey writes key as an OpenSSH private key file.
+func writeClientKey(t *testing.T, dir string, key ed25519.PrivateKey) string {
+	t.Helper()
+
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeKnownHosts writes a known_hosts file with a key for each host.
+func writeKnownHosts(t *testing.T, dir string, entries map[string]ssh.PublicKey) string {
+	t.Helper()
+
+	var lines []string
+	for addr, key := range entries {
+		lines = append(lines, knownhosts.Line([]string{addr}, key))
+	}
+	path := filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// roundTrip sends a message through the tunnel and checks it comes back.
+func roundTrip(t *testing.T, tun *Tunnel) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", tun.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("PING")); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != "PING" {
+		t.Errorf("reply through the tunnel = %q, want %q", reply, "PING")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	clientSigner, clientKey := newEd25519Signer(t)
+	hostKey, _ := newEd25519Signer(t)
+
+	bastion := newSSHServer(t, clientSigner.PublicKey(), hostKey)
+	database := echoServer(t)
+
+	tun, err := Open(Config{
+		Host:       bastion.addr,
+		User:       "deploy",
+		Key:        writeClientKey(t, dir, clientKey),
+		KnownHosts: writeKnownHosts(t, dir, map[string]ssh.PublicKey{bastion.addr: hostKey.PublicKey()}),
+	}, database)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer tun.Close()
+
+	if tun.Host != "127.0.0.1" {
+		t.Errorf("Host = %q, want 127.0.0.1", tun.Host)
+	}
+	roundTrip(t, tun)
+}
+
+func TestOpenJumpChain(t *testing.T) {
+	dir := t.TempDir()
+	clientSigner, clientKey := newEd25519Signer(t)
+	jump1Key, _ := newEd25519Signer(t)
+	jump2Key, _ := newEd25519Signer(t)
+	bastionKey, _ := newEd25519Signer(t)
+
+	jump1 := newSSHServer(t, clientSigner.PublicKey(), jump1Key)
+	jump2 := newSSHServer(t, clientSigner.PublicKey(), jump2Key)
+	bastion := newSSHServer(t, clientSigner.PublicKey(), bastionKey)
+	database := echoServer(t)
+
+	tun, err := Open(Config{
+		Host: bastion.addr,
+		User: "deploy",
+		Key:  writeClientKey(t, dir, clientKey),
+		KnownHosts: writeKnownHosts(t, dir, map[string]ssh.PublicKey{
+			jump1.addr:   jump1Key.PublicKey(),
+			jump2.addr:   jump2Key.PublicKey(),
+			bastion.addr: bastionKey.PublicKey(),
+		}),
+		Jump: []string{"ops@" + jump1.addr, jump2.addr},
+	}, database)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer tun.Close()
+
+	roundTrip(t, tun)
+
+	// Each hop is reached through the one before it, and only the bastion
+	// connects to the database
+	tests := []struct {
+		name   string
+		server *sshServer
+		want   string
+	}{
+		{"first jump host", jump1, jump2.addr},
+		{"second jump host", jump2, bastion.addr},
+		{"bastion", bastion, database},
+	}
+	for _, tt := range tests {
+		dialled := tt.server.dialledAddrs()
+		if len(dialled) == 0 || dialled[0] != tt.want {
+			t.Errorf("%s forwarded to %v, want %s", tt.name, dialled, tt.want)
+		}
+		for _, addr := range dialled {
+			if addr != tt.want {
+				t.Errorf("%s forwarded to %s, want only %s", tt.name, addr, tt.want)
+			}
+		}
+	}
+}
+
+func TestOpenHostKeys(t *testing.T) {
+	clientSigner, clientKey := newEd25519Signer(t)
+	hostKey, _ := newEd25519Signer(t)
+	otherKey, _ := newEd25519Signer(t)
+	jumpKey, _ := newEd25519Signer(t)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaHostKey, err := ssh.NewSignerFromKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bastion := newSSHServer(t, clientSigner.PublicKey(), hostKey)
+	twoKeys := newSSHServer(t, clientSigner.PublicKey(), hostKey, rsaHostKey)
+	jump := newSSHServer(t, clientSigner.PublicKey(), jumpKey)
+	database := echoServer(t)
+
+	tests := []struct {
+		name    string
+		host    string
+		jump    []string
+		known   map[string]ssh.PublicKey
+		wantErr string
+	}{
+		{
+			name:    "mismatch",
+			host:    bastion.addr,
+			known:   map[string]ssh.PublicKey{bastion.addr: otherKey.PublicKey()},
+			wantErr: "host key doesn't match known_hosts",
+		},
+		{
+			name:    "unknown host",
+			host:    bastion.addr,
+			known:   map[string]ssh.PublicKey{jump.addr: jumpKey.PublicKey()},
+			wantErr: "host key is not in known_hosts",
+		},
+		{
+			name:    "mismatch behind a jump host",
+			host:    bastion.addr,
+			jump:    []string{jump.addr},
+			known:   map[string]ssh.PublicKey{jump.addr: jumpKey.PublicKey(), bastion.addr: otherKey.PublicKey()},
+			wantErr: "SSH to " + bastion.addr + ": host key doesn't match known_hosts",
+		},
+		{
+			name:    "mismatch on a jump host",
+			host:    bastion.addr,
+			jump:    []string{jump.addr},
+			known:   map[string]ssh.PublicKey{jump.addr: otherKey.PublicKey(), bastion.addr: hostKey.PublicKey()},
+			wantErr: "SSH to " + jump.addr + ": host key doesn't match known_hosts",
+		},
+		{
+			name:  "only a key of another type is known",
+			host:  twoKeys.addr,
+			known: map[string]ssh.PublicKey{twoKeys.addr: rsaHostKey.PublicKey()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tun, err := Open(Config{
+				Host:       tt.host,
+				User:       "deploy",
+				Key:        writeClientKey(t, dir, clientKey),
+				KnownHosts: writeKnownHosts(t, dir, tt.known),
+				Jump:       tt.jump,
+			}, database)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Open() error = %v", err)
+				}
+				defer tun.Close()
+				roundTrip(t, tun)
+				return
+			}
+			if err == nil {
+				tun.Close()
+				t.Fatalf("Open() succeeded, want %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Open() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenUnreachableDatabase(t *testing.T) {
+	dir := t.TempDir()
+	clientSigner, clientKey := newEd25519Signer(t)
+	hostKey, _ := newEd25519Signer(t)
+	bastion := newSSHServer(t, clientSigner.PublicKey(), hostKey)
+
+	// A port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := listener.Addr().String()
+	listener.Close()
+
+	_, err = Open(Config{
+		Host:       bastion.addr,
+		User:       "deploy",
+		Key:        writeClientKey(t, dir, clientKey),
+		KnownHosts: writeKnownHosts(t, dir, map[string]ssh.PublicKey{bastion.addr: hostKey.PublicKey()}),
+	}, database)
+	if err == nil || !strings.Contains(err.Error(), "can't reach "+database) {
+		t.Errorf("Open() error = %v, want the bastion to report %s unreachable", err, database)
+	}
+}
+
+func TestSplitHop(t *testing.T) {
+	tests := []struct {
+		hop, user          string
+		wantUser, wantAddr string
+	}{
+		{"bastion.example.com", "deploy", "deploy", "bastion.example.com:22"},
+		{"ops@jump.example.com:2222", "deploy", "ops", "jump.example.com:2222"},
+		{"10.0.0.5", "", "", "10.0.0.5:22"},
+		{"[2001:db8::1]:2200", "deploy", "deploy", "[2001:db8::1]:2200"},
+	}
+
+	for _, tt := range tests {
+		user, addr := splitHop(tt.hop, tt.user)
+		if user != tt.wantUser || addr != tt.wantAddr {
+			t.Errorf("splitHop(%q, %q) = %q, %q, want %q, %q", tt.hop, tt.user, user, addr, tt.wantUser, tt.wantAddr)
+		}
+	}
+}